@@ -1,38 +1,184 @@
 // Package appconf provides application-specific configuration management by extending the base configuration provided by econf.
+// Application settings are loaded from defaults, then from the configuration file on the mount, then from env variables.
 package appconf
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"enclave/elog"
+	"enclave/tonnet"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/tonteeton/golib/econf"
 	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	APP_VERSION    = "get-random-int-v1r1"
 	TESTNET_CONFIG = "https://ton.org/testnet-global.config.json"
 	MAINNET_CONFIG = "https://ton.org/global.config.json"
+	CONFIG_PATH    = "mount/config.json"
+	DRY_RUN_PATH   = "mount/dry-run"
+	REVEALED_PATH  = "mount/revealed.enc"
+	PROJECTS_PATH  = "mount/projects.enc"
+	PENDING_PATH   = "mount/pending.enc"
+	WATCH_PATH     = "mount/watch.enc"
 )
 
+// walletVersions maps supported wallet.version values to wallet versions.
+var walletVersions = map[string]wallet.Version{
+	"V3R1": wallet.V3R1,
+	"V3R2": wallet.V3R2,
+	"V4R2": wallet.V4R2,
+}
+
 // Config extends the econf.Config to include additional application-specific configurations.
 type Config struct {
 	econf.Config // Embedding main config from econf
 
 	Network struct {
 		TestNet         bool
-		GlobalConfigURL string
 		ContractAddress *address.Address
+		Connection      tonnet.Config
 	}
 
 	Wallet struct {
-		Mnemonic []string
+		Mnemonic  []string
+		Version   wallet.Version
+		Resubmits int // Resubmissions of an unconfirmed wallet transaction, see tonnet.SendQueue.
+	}
+
+	// Fees holds values attached to the messages sent by the enclave.
+	// The value is estimated from the fees of the previous responses, see efees.
+	Fees struct {
+		ResponseValue    tlb.Coins // Value of the responses of a kind not sent yet.
+		MaxResponseValue tlb.Coins // Cap of the estimated value.
+		MarginPercent    uint64    // Safety margin added to the estimated value.
+	}
+
+	// Balance holds thresholds of the sender wallet and contract balances, see ebalance.
+	Balance struct {
+		WalletWarning   tlb.Coins // The wallet balance logged as low.
+		WalletCritical  tlb.Coins // Responses are not sent below this wallet balance.
+		ContractWarning tlb.Coins // The contract balance logged as low.
+	}
+
+	// Intervals holds timings of the contract event protocol.
+	Intervals struct {
+		ConfirmTimeout time.Duration // Wait for the wallet transaction before resubmitting it.
+		StallTimeout   time.Duration // The transaction subscription lagging behind the chain this long is restarted.
+	}
+
+	// Watch holds the state of the watch command.
+	Watch struct {
+		StatePath string // Sealed last handled contract transaction, the watch resumes after it on restart.
+	}
+
+	// Draw holds settings of the winner selection.
+	Draw struct {
+		Winners      int    // Number of distinct winners drawn in one commit.
+		RevealedPath string // Sealed set of already revealed projects, excluded from later draws.
+		PendingPath  string // Sealed commitments waiting for the reveal.
+	}
+
+	// Projects holds settings of the project list imported at runtime.
+	Projects struct {
+		Path string // Sealed project list, the built-in list is used if it doesn't exist.
 	}
+
+	// Metrics holds settings of the Prometheus metrics endpoint, see emetrics.
+	Metrics struct {
+		Port int // Port serving /metrics of the watch command, disabled if zero.
+	}
+
+	// Log holds the log handler settings, see elog.
+	Log elog.Config
+}
+
+// fileConfig represents the configuration file.
+// JSON keys are used to name the settings in validation errors.
+type fileConfig struct {
+	Network struct {
+		TestNet         *bool                   `json:"testnet"`
+		API             string                  `json:"api"`
+		TonCenterURL    string                  `json:"toncenterUrl"`
+		GlobalConfig    string                  `json:"globalConfig"`
+		LiteServer      string                  `json:"liteServer"`
+		LiteServerKey   string                  `json:"liteServerKey"`
+		TrustedBlock    *liteclient.ConfigBlock `json:"trustedBlock"`
+		ContractAddress string                  `json:"contractAddress"`
+	} `json:"network"`
+	Wallet struct {
+		Version   string `json:"version"`
+		Resubmits int    `json:"resubmits"`
+	} `json:"wallet"`
+	Fees struct {
+		ResponseValue    string `json:"responseValue"`
+		MaxResponseValue string `json:"maxResponseValue"`
+		MarginPercent    int64  `json:"marginPercent"`
+	} `json:"fees"`
+	Balance struct {
+		WalletWarning   string `json:"walletWarning"`
+		WalletCritical  string `json:"walletCritical"`
+		ContractWarning string `json:"contractWarning"`
+	} `json:"balance"`
+	Intervals struct {
+		ConfirmTimeoutSec int64 `json:"confirmTimeoutSec"`
+		StallTimeoutSec   int64 `json:"stallTimeoutSec"`
+	} `json:"intervals"`
+	Draw struct {
+		Winners int `json:"winners"`
+	} `json:"draw"`
+	Projects struct {
+		Path string `json:"path"`
+	} `json:"projects"`
+	Metrics struct {
+		Port int `json:"port"`
+	} `json:"metrics"`
+	Log struct {
+		Format string `json:"format"`
+		Level  string `json:"level"`
+	} `json:"log"`
+}
+
+// defaultFileConfig returns settings used when they are set neither in the file nor in env.
+func defaultFileConfig() fileConfig {
+	var fc fileConfig
+	fc.Network.API = tonnet.APILiteServer
+	fc.Wallet.Version = "V3R2"
+	fc.Wallet.Resubmits = 2
+	fc.Fees.ResponseValue = "0.025"
+	fc.Fees.MaxResponseValue = "0.1"
+	fc.Fees.MarginPercent = 20
+	fc.Balance.WalletWarning = "1"
+	fc.Balance.WalletCritical = "0.1"
+	fc.Balance.ContractWarning = "0.5"
+	fc.Intervals.ConfirmTimeoutSec = 60
+	fc.Intervals.StallTimeoutSec = 120
+	fc.Draw.Winners = 1
+	fc.Projects.Path = PROJECTS_PATH
+	fc.Log.Format = elog.FormatText
+	fc.Log.Level = "info"
+	return fc
 }
 
 // LoadConfig loads the application configuration.
 func LoadConfig() (*Config, error) {
+	return loadConfig(CONFIG_PATH)
+}
+
+func loadConfig(path string) (*Config, error) {
 	cfg := Config{}
 
 	// Load econf.Config sections
@@ -42,38 +188,280 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.Config = *econfConfig
 
-	testNetEnv := os.Getenv("TON_TESTNET")
-	if testNetEnv == "" {
-		return nil, errors.New("TON_TESTNET env is not set")
+	fc := defaultFileConfig()
+	if err := fc.loadFile(path); err != nil {
+		return nil, err
 	}
-	testNet, err := strconv.ParseBool(testNetEnv)
-	if err != nil {
+	if err := fc.loadEnv(); err != nil {
 		return nil, err
 	}
-	cfg.Network.TestNet = testNet
-	if testNet {
-		cfg.Network.GlobalConfigURL = TESTNET_CONFIG
-	} else {
-		cfg.Network.GlobalConfigURL = MAINNET_CONFIG
+	if err := fc.apply(&cfg); err != nil {
+		return nil, err
 	}
 
-	contractAddress := os.Getenv("TON_CONTRACT_ADDRESS")
-	if contractAddress == "" {
-		return nil, errors.New("TON_CONTRACT_ADDRESS env is not set")
+	mnemonic := os.Getenv("TON_WALLET_MNEMONIC")
+	if mnemonic == "" {
+		return nil, errors.New("TON_WALLET_MNEMONIC env is not set")
 	}
-	parsedAddress, err := address.ParseAddr(contractAddress)
+	cfg.Wallet.Mnemonic = strings.Split(mnemonic, " ")
+	cfg.Network.Connection.TonCenterKey = os.Getenv("TONCENTER_API_KEY")
+
+	return &cfg, nil
+}
+
+// loadFile overrides the settings with values from the configuration file, if it exists.
+func (fc *fileConfig) loadFile(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(fc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return keyError(typeErr.Field, fmt.Errorf("expected %s value", typeErr.Type))
+		}
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the settings with values from env variables, every key of the file has one.
+func (fc *fileConfig) loadEnv() error {
+	if testNetEnv := os.Getenv("TON_TESTNET"); testNetEnv != "" {
+		testNet, err := strconv.ParseBool(testNetEnv)
+		if err != nil {
+			return keyError("network.testnet", fmt.Errorf("TON_TESTNET env: %w", err))
+		}
+		fc.Network.TestNet = &testNet
+	}
+	envString("TON_API", &fc.Network.API)
+	envString("TONCENTER_URL", &fc.Network.TonCenterURL)
+	envString("TON_GLOBAL_CONFIG", &fc.Network.GlobalConfig)
+	envString("TON_LITESERVER", &fc.Network.LiteServer)
+	envString("TON_LITESERVER_KEY", &fc.Network.LiteServerKey)
+	if trustedBlock := os.Getenv("TON_TRUSTED_BLOCK"); trustedBlock != "" {
+		fc.Network.TrustedBlock = &liteclient.ConfigBlock{}
+		if err := json.Unmarshal([]byte(trustedBlock), fc.Network.TrustedBlock); err != nil {
+			return keyError("network.trustedBlock", fmt.Errorf("TON_TRUSTED_BLOCK env: %w", err))
+		}
+	}
+	envString("TON_CONTRACT_ADDRESS", &fc.Network.ContractAddress)
+	envString("TON_WALLET_VERSION", &fc.Wallet.Version)
+	if err := envInt("wallet.resubmits", "TON_WALLET_RESUBMITS", &fc.Wallet.Resubmits); err != nil {
+		return err
+	}
+	envString("FEES_RESPONSE_VALUE", &fc.Fees.ResponseValue)
+	envString("FEES_MAX_RESPONSE_VALUE", &fc.Fees.MaxResponseValue)
+	if err := envInt("fees.marginPercent", "FEES_MARGIN_PERCENT", &fc.Fees.MarginPercent); err != nil {
+		return err
+	}
+	envString("BALANCE_WALLET_WARNING", &fc.Balance.WalletWarning)
+	envString("BALANCE_WALLET_CRITICAL", &fc.Balance.WalletCritical)
+	envString("BALANCE_CONTRACT_WARNING", &fc.Balance.ContractWarning)
+	if err := envInt("intervals.confirmTimeoutSec", "CONFIRM_TIMEOUT_SEC", &fc.Intervals.ConfirmTimeoutSec); err != nil {
+		return err
+	}
+	if err := envInt("intervals.stallTimeoutSec", "STALL_TIMEOUT_SEC", &fc.Intervals.StallTimeoutSec); err != nil {
+		return err
+	}
+	if err := envInt("draw.winners", "DRAW_WINNERS", &fc.Draw.Winners); err != nil {
+		return err
+	}
+	envString("PROJECTS_PATH", &fc.Projects.Path)
+	if err := envInt("metrics.port", "METRICS_PORT", &fc.Metrics.Port); err != nil {
+		return err
+	}
+	envString("LOG_FORMAT", &fc.Log.Format)
+	envString("LOG_LEVEL", &fc.Log.Level)
+	return nil
+}
+
+// envString overrides the setting with the env variable, if it is set.
+func envString(name string, value *string) {
+	if env := os.Getenv(name); env != "" {
+		*value = env
+	}
+}
+
+// envInt overrides the integer setting of the key with the env variable, if it is set.
+func envInt[T int | int64](key, name string, value *T) error {
+	env := os.Getenv(name)
+	if env == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return keyError(key, fmt.Errorf("%s env: %w", name, err))
+	}
+	*value = T(n)
+	return nil
+}
+
+// apply validates the settings and stores them in cfg.
+func (fc fileConfig) apply(cfg *Config) error {
+	if fc.Network.TestNet == nil {
+		return keyError("network.testnet", errors.New("is not set (TON_TESTNET env)"))
+	}
+	cfg.Network.TestNet = *fc.Network.TestNet
+	connection := &cfg.Network.Connection
+	connection.API = fc.Network.API
+	switch fc.Network.API {
+	case tonnet.APILiteServer:
+	case tonnet.APITonCenter, tonnet.APITonCenterV3:
+		switch {
+		case fc.Network.TonCenterURL != "":
+			if _, err := url.ParseRequestURI(fc.Network.TonCenterURL); err != nil {
+				return keyError("network.toncenterUrl", err)
+			}
+			connection.TonCenterURL = fc.Network.TonCenterURL
+		case cfg.Network.TestNet:
+			connection.TonCenterURL = tonnet.TONCENTER_TESTNET
+		default:
+			connection.TonCenterURL = tonnet.TONCENTER_MAINNET
+		}
+	default:
+		return keyError("network.api", fmt.Errorf("unsupported API %q", fc.Network.API))
+	}
+	switch {
+	case fc.Network.API == tonnet.APITonCenter || fc.Network.API == tonnet.APITonCenterV3:
+		// Liteserver settings are not used with the HTTP API.
+	case fc.Network.GlobalConfig != "":
+		connection.GlobalConfig = fc.Network.GlobalConfig
+	case fc.Network.LiteServer != "":
+		// The custom liteserver is used without the global config.
+	case cfg.Network.TestNet:
+		connection.GlobalConfig = TESTNET_CONFIG
+	default:
+		connection.GlobalConfig = MAINNET_CONFIG
+	}
+	if fc.Network.LiteServer != "" {
+		if _, _, err := net.SplitHostPort(fc.Network.LiteServer); err != nil {
+			return keyError("network.liteServer", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(fc.Network.LiteServerKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return keyError("network.liteServerKey", errors.New("base64-encoded ed25519 public key expected"))
+		}
+	}
+	connection.LiteServer = fc.Network.LiteServer
+	connection.LiteServerKey = fc.Network.LiteServerKey
+	if block := fc.Network.TrustedBlock; block != nil {
+		if len(block.RootHash) != 32 || len(block.FileHash) != 32 {
+			return keyError("network.trustedBlock", errors.New("root_hash and file_hash must be 32 bytes"))
+		}
+		connection.TrustedBlock = block
+	}
+
+	if fc.Network.ContractAddress == "" {
+		return keyError("network.contractAddress", errors.New("is not set (TON_CONTRACT_ADDRESS env)"))
+	}
+	parsedAddress, err := address.ParseAddr(fc.Network.ContractAddress)
+	if err != nil {
+		return keyError("network.contractAddress", err)
 	}
 	parsedAddress.SetTestnetOnly(false)
 	parsedAddress.SetBounce(false)
 	cfg.Network.ContractAddress = parsedAddress
 
-	mnemonic := os.Getenv("TON_WALLET_MNEMONIC")
-	if mnemonic == "" {
-		return nil, errors.New("TON_WALLET_MNEMONIC env is not set")
+	version, ok := walletVersions[fc.Wallet.Version]
+	if !ok {
+		return keyError("wallet.version", fmt.Errorf("unsupported version %q", fc.Wallet.Version))
 	}
-	cfg.Wallet.Mnemonic = strings.Split(mnemonic, " ")
+	cfg.Wallet.Version = version
+	if fc.Wallet.Resubmits < 0 {
+		return keyError("wallet.resubmits", errors.New("must not be negative"))
+	}
+	cfg.Wallet.Resubmits = fc.Wallet.Resubmits
 
-	return &cfg, nil
+	responseValue, err := tlb.FromTON(fc.Fees.ResponseValue)
+	if err != nil {
+		return keyError("fees.responseValue", err)
+	}
+	if responseValue.Nano().Sign() <= 0 {
+		return keyError("fees.responseValue", errors.New("must be positive"))
+	}
+	cfg.Fees.ResponseValue = responseValue
+	maxResponseValue, err := tlb.FromTON(fc.Fees.MaxResponseValue)
+	if err != nil {
+		return keyError("fees.maxResponseValue", err)
+	}
+	if maxResponseValue.Nano().Cmp(responseValue.Nano()) < 0 {
+		return keyError("fees.maxResponseValue", errors.New("must not be less than fees.responseValue"))
+	}
+	cfg.Fees.MaxResponseValue = maxResponseValue
+	if fc.Fees.MarginPercent < 0 {
+		return keyError("fees.marginPercent", errors.New("must not be negative"))
+	}
+	cfg.Fees.MarginPercent = uint64(fc.Fees.MarginPercent)
+
+	if cfg.Balance.WalletWarning, err = parseThreshold("balance.walletWarning", fc.Balance.WalletWarning); err != nil {
+		return err
+	}
+	if cfg.Balance.WalletCritical, err = parseThreshold("balance.walletCritical", fc.Balance.WalletCritical); err != nil {
+		return err
+	}
+	if cfg.Balance.WalletCritical.Nano().Cmp(cfg.Balance.WalletWarning.Nano()) > 0 {
+		return keyError("balance.walletCritical", errors.New("must not be greater than balance.walletWarning"))
+	}
+	if cfg.Balance.ContractWarning, err = parseThreshold("balance.contractWarning", fc.Balance.ContractWarning); err != nil {
+		return err
+	}
+
+	if fc.Intervals.ConfirmTimeoutSec <= 0 {
+		return keyError("intervals.confirmTimeoutSec", errors.New("must be positive"))
+	}
+	cfg.Intervals.ConfirmTimeout = time.Duration(fc.Intervals.ConfirmTimeoutSec) * time.Second
+	if fc.Intervals.StallTimeoutSec <= 0 {
+		return keyError("intervals.stallTimeoutSec", errors.New("must be positive"))
+	}
+	cfg.Intervals.StallTimeout = time.Duration(fc.Intervals.StallTimeoutSec) * time.Second
+
+	if fc.Draw.Winners < 1 {
+		return keyError("draw.winners", errors.New("must be positive"))
+	}
+	cfg.Draw.Winners = fc.Draw.Winners
+	cfg.Draw.RevealedPath = REVEALED_PATH
+	cfg.Draw.PendingPath = PENDING_PATH
+	cfg.Watch.StatePath = WATCH_PATH
+
+	if fc.Projects.Path == "" {
+		return keyError("projects.path", errors.New("is empty"))
+	}
+	cfg.Projects.Path = fc.Projects.Path
+
+	if fc.Metrics.Port < 0 || fc.Metrics.Port > 65535 {
+		return keyError("metrics.port", errors.New("must be from 0 to 65535"))
+	}
+	cfg.Metrics.Port = fc.Metrics.Port
+
+	if cfg.Log.Format, err = elog.ParseFormat(fc.Log.Format); err != nil {
+		return keyError("log.format", err)
+	}
+	if err := cfg.Log.Level.UnmarshalText([]byte(fc.Log.Level)); err != nil {
+		return keyError("log.level", err)
+	}
+
+	return nil
+}
+
+// parseThreshold parses the balance threshold in TON, zero disables it.
+func parseThreshold(key, value string) (tlb.Coins, error) {
+	coins, err := tlb.FromTON(value)
+	if err != nil {
+		return tlb.Coins{}, keyError(key, err)
+	}
+	if coins.Nano().Sign() < 0 {
+		return tlb.Coins{}, keyError(key, errors.New("must not be negative"))
+	}
+	return coins, nil
+}
+
+// keyError returns a validation error naming the configuration key.
+func keyError(key string, err error) error {
+	return fmt.Errorf("config key %q: %w", key, err)
 }
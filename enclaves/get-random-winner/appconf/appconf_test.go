@@ -1,7 +1,15 @@
 package appconf
 
 import (
+	"enclave/elog"
+	"enclave/tonnet"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -17,3 +25,191 @@ func TestLoadConfig(t *testing.T) {
 		}
 	})
 }
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("File overrides defaults, env overrides file", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		t.Setenv("TON_WALLET_VERSION", "V4R2")
+		path := writeConfigFile(t, `{
+			"network": {"testnet": false, "globalConfig": "mount/global.config.json",
+				"contractAddress": "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2"},
+			"wallet": {"version": "V3R1"},
+			"fees": {"responseValue": "0.05"},
+			"draw": {"winners": 3}
+		}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !cfg.Network.TestNet {
+			t.Errorf("Env value is not applied: %+v", cfg.Network)
+		}
+		if cfg.Network.Connection.GlobalConfig != "mount/global.config.json" {
+			t.Errorf("File value is not applied: %+v", cfg.Network)
+		}
+		if cfg.Wallet.Version != wallet.V4R2 {
+			t.Errorf("Unexpected wallet version: %v", cfg.Wallet.Version)
+		}
+		if cfg.Fees.ResponseValue.String() != "0.05" || cfg.Fees.MaxResponseValue.String() != "0.1" || cfg.Fees.MarginPercent != 20 {
+			t.Errorf("Unexpected fees: %+v", cfg.Fees)
+		}
+		if cfg.Balance.WalletWarning.String() != "1" || cfg.Balance.WalletCritical.String() != "0.1" {
+			t.Errorf("Unexpected default balance thresholds: %+v", cfg.Balance)
+		}
+		if cfg.Draw.Winners != 3 {
+			t.Errorf("Unexpected winners count: %v", cfg.Draw.Winners)
+		}
+		if cfg.Projects.Path != PROJECTS_PATH {
+			t.Errorf("Unexpected default projects path: %v", cfg.Projects.Path)
+		}
+		if cfg.Metrics.Port != 0 {
+			t.Errorf("Metrics endpoint is enabled by default: %v", cfg.Metrics.Port)
+		}
+		if cfg.Log.Format != elog.FormatText || cfg.Log.Level != slog.LevelInfo {
+			t.Errorf("Unexpected default log settings: %+v", cfg.Log)
+		}
+	})
+
+	cases := []struct {
+		content     string
+		expectedErr string
+	}{
+		{`{"network": {"testnet": "yes"}}`, `"network.testnet"`},
+		{`{"wallet": {"version": "V9"}}`, `"wallet.version"`},
+		{`{"fees": {"responseValue": "free"}}`, `"fees.responseValue"`},
+		{`{"fees": {"responseValue": "0.2"}}`, `"fees.maxResponseValue"`},
+		{`{"fees": {"marginPercent": -5}}`, `"fees.marginPercent"`},
+		{`{"balance": {"walletWarning": "low"}}`, `"balance.walletWarning"`},
+		{`{"balance": {"walletCritical": "2"}}`, `"balance.walletCritical"`},
+		{`{"balance": {"contractWarning": "-1"}}`, `"balance.contractWarning"`},
+		{`{"intervals": {"confirmTimeoutSec": 0}}`, `"intervals.confirmTimeoutSec"`},
+		{`{"intervals": {"stallTimeoutSec": -10}}`, `"intervals.stallTimeoutSec"`},
+		{`{"wallet": {"resubmits": -1}}`, `"wallet.resubmits"`},
+		{`{"wallet": {"mnemonic": "test"}}`, `"mnemonic"`},
+		{`{"network": {"liteServer": "127.0.0.1"}}`, `"network.liteServer"`},
+		{`{"network": {"liteServer": "127.0.0.1:4443", "liteServerKey": "a2V5"}}`, `"network.liteServerKey"`},
+		{`{"network": {"trustedBlock": {"workchain": -1, "seqno": 1}}}`, `"network.trustedBlock"`},
+		{`{"network": {"api": "rest"}}`, `"network.api"`},
+		{`{"draw": {"winners": 0}}`, `"draw.winners"`},
+		{`{"projects": {"path": ""}}`, `"projects.path"`},
+		{`{"metrics": {"port": 70000}}`, `"metrics.port"`},
+		{`{"log": {"format": "xml"}}`, `"log.format"`},
+		{`{"log": {"level": "loud"}}`, `"log.level"`},
+		{`{"network": {"api": "toncenter", "toncenterUrl": "toncenter"}}`, `"network.toncenterUrl"`},
+	}
+	for _, tcase := range cases {
+		t.Run(tcase.content, func(t *testing.T) {
+			t.Setenv("TON_TESTNET", "1")
+			t.Setenv("TON_CONTRACT_ADDRESS", "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+			t.Setenv("TON_WALLET_MNEMONIC", "test")
+			_, err := loadConfig(writeConfigFile(t, tcase.content))
+			if err == nil {
+				t.Errorf("Expected error not raised: %v", tcase.expectedErr)
+			} else if !strings.Contains(err.Error(), tcase.expectedErr) {
+				t.Errorf("Unexpected error: %v,\n expected key: %v", err, tcase.expectedErr)
+			}
+		})
+	}
+
+	t.Run("Env overrides file keys", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_CONTRACT_ADDRESS", "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		t.Setenv("TON_WALLET_RESUBMITS", "5")
+		t.Setenv("FEES_MAX_RESPONSE_VALUE", "0.2")
+		t.Setenv("FEES_MARGIN_PERCENT", "50")
+		t.Setenv("BALANCE_WALLET_CRITICAL", "0.5")
+		t.Setenv("STALL_TIMEOUT_SEC", "30")
+		t.Setenv("DRAW_WINNERS", "2")
+		t.Setenv("PROJECTS_PATH", "mount/other.enc")
+		path := writeConfigFile(t, `{
+			"wallet": {"resubmits": 1},
+			"fees": {"maxResponseValue": "0.1", "marginPercent": 10},
+			"intervals": {"stallTimeoutSec": 60},
+			"draw": {"winners": 3}
+		}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Wallet.Resubmits != 5 || cfg.Fees.MaxResponseValue.String() != "0.2" || cfg.Fees.MarginPercent != 50 {
+			t.Errorf("Env values are not applied: %+v, %+v", cfg.Wallet, cfg.Fees)
+		}
+		if cfg.Balance.WalletCritical.String() != "0.5" || cfg.Intervals.StallTimeout != 30*time.Second {
+			t.Errorf("Env values are not applied: %+v, %+v", cfg.Balance, cfg.Intervals)
+		}
+		if cfg.Draw.Winners != 2 || cfg.Projects.Path != "mount/other.enc" {
+			t.Errorf("Env values are not applied: %+v, %+v", cfg.Draw, cfg.Projects)
+		}
+	})
+
+	t.Run("Invalid env value", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_CONTRACT_ADDRESS", "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		t.Setenv("CONFIRM_TIMEOUT_SEC", "1m")
+		_, err := loadConfig(writeConfigFile(t, `{}`))
+		if err == nil || !strings.Contains(err.Error(), `"intervals.confirmTimeoutSec"`) || !strings.Contains(err.Error(), "CONFIRM_TIMEOUT_SEC") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Invalid address in file", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_CONTRACT_ADDRESS", "")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		_, err := loadConfig(writeConfigFile(t, `{"network": {"contractAddress": "abc"}}`))
+		if err == nil || !strings.Contains(err.Error(), `"network.contractAddress"`) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Custom liteserver without global config", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_CONTRACT_ADDRESS", "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		t.Setenv("TON_TRUSTED_BLOCK", `{"workchain": -1, "shard": -9223372036854775808, "seqno": 1,
+			"root_hash": "F6OpKZKqvqeFp6CQmFomXNMfMj2EnaUSOXN+Mh+wVWk=",
+			"file_hash": "XplPz01CXAps5qeSWUtxcyBfdAo5zVb1N979KLSKD24="}`)
+		path := writeConfigFile(t, `{"network": {"liteServer": "127.0.0.1:4443",
+			"liteServerKey": "F6OpKZKqvqeFp6CQmFomXNMfMj2EnaUSOXN+Mh+wVWk="}}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		connection := cfg.Network.Connection
+		if connection.GlobalConfig != "" || connection.LiteServer != "127.0.0.1:4443" {
+			t.Errorf("Unexpected connection config: %+v", connection)
+		}
+		if connection.TrustedBlock == nil || connection.TrustedBlock.SeqNo != 1 {
+			t.Errorf("Unexpected trusted block: %+v", connection.TrustedBlock)
+		}
+	})
+
+	t.Run("Toncenter API", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "1")
+		t.Setenv("TON_CONTRACT_ADDRESS", "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+		t.Setenv("TON_WALLET_MNEMONIC", "test")
+		t.Setenv("TONCENTER_API_KEY", "secret")
+		cfg, err := loadConfig(writeConfigFile(t, `{"network": {"api": "toncenter"}}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		connection := cfg.Network.Connection
+		if connection.API != tonnet.APITonCenter || connection.GlobalConfig != "" {
+			t.Errorf("Unexpected connection config: %+v", connection)
+		}
+		if connection.TonCenterURL != tonnet.TONCENTER_TESTNET || connection.TonCenterKey != "secret" {
+			t.Errorf("Unexpected toncenter config: %+v", connection)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Command project-proof prints the Merkle inclusion proof of a project in the project list,
+// or verifies a printed proof against the projects root committed by the enclave.
+//
+// Usage:
+//
+//	project-proof [-projects buidls.json] <project ID>
+//	project-proof -verify proof.json [-root <hex>]
+package main
+
+import (
+	"enclave/eprojects"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// proofOutput is the proof in the JSON form, hashes are hex encoded.
+type proofOutput struct {
+	Root     string            `json:"root"`
+	Project  eprojects.Project `json:"project"`
+	Index    int               `json:"index"`
+	Count    int               `json:"count"`
+	Leaf     string            `json:"leaf"`
+	Siblings []string          `json:"siblings"`
+}
+
+func printProof(path string, idArg string) error {
+	id, err := strconv.Atoi(idArg)
+	if err != nil {
+		return fmt.Errorf("invalid project ID: %w", err)
+	}
+	projects, err := eprojects.LoadProjects(path)
+	if err != nil {
+		return err
+	}
+	index := projects.IndexOf(id)
+	if index < 0 {
+		return fmt.Errorf("project %d is not found", id)
+	}
+	proof, err := projects.Proof(index)
+	if err != nil {
+		return err
+	}
+
+	output := proofOutput{
+		Root:     hex.EncodeToString(projects.MerkleRoot()),
+		Project:  projects[index],
+		Index:    proof.Index,
+		Count:    proof.Count,
+		Leaf:     hex.EncodeToString(projects[index].LeafHash()),
+		Siblings: make([]string, len(proof.Siblings)),
+	}
+	for i, sibling := range proof.Siblings {
+		output.Siblings[i] = hex.EncodeToString(sibling)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// verifyProof checks the printed proof, against the given root if set, e.g. the root from RandomCommit.
+func verifyProof(path string, rootArg string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var input proofOutput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	if rootArg == "" {
+		rootArg = input.Root
+	}
+	root, err := hex.DecodeString(rootArg)
+	if err != nil {
+		return fmt.Errorf("invalid root: %w", err)
+	}
+	proof := eprojects.Proof{Index: input.Index, Count: input.Count}
+	for _, sibling := range input.Siblings {
+		hash, err := hex.DecodeString(sibling)
+		if err != nil {
+			return fmt.Errorf("invalid sibling: %w", err)
+		}
+		proof.Siblings = append(proof.Siblings, hash)
+	}
+	if !eprojects.VerifyProof(root, input.Project, proof) {
+		return errors.New("proof is invalid")
+	}
+	fmt.Printf("Project %d %q is included at index %d in the list with root %x\n",
+		input.Project.ID, input.Project.Name, input.Index, root)
+	return nil
+}
+
+func main() {
+	projectsPath := flag.String("projects", "buidls.json", "Project list file")
+	verifyPath := flag.String("verify", "", "Verify the proof file instead of printing a proof")
+	root := flag.String("root", "", "Expected projects root (hex) for -verify, the root in the proof file if empty")
+	flag.Parse()
+
+	var err error
+	switch {
+	case *verifyPath != "":
+		err = verifyProof(*verifyPath, *root)
+	case flag.NArg() == 1:
+		err = printProof(*projectsPath, flag.Arg(0))
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+// Command sign-projects signs the project list with the contract owner wallet key,
+// for import into the enclave with the import-projects command.
+// The printed Merkle root must be announced in the contract with the AnnounceProjects message.
+//
+// Usage:
+//
+//	OWNER_MNEMONIC="word1 word2 ..." sign-projects buidls.json > signed-projects.json
+package main
+
+import (
+	"enclave/eprojects"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"os"
+	"strings"
+)
+
+func signProjects(path string) error {
+	mnemonic := os.Getenv("OWNER_MNEMONIC")
+	if mnemonic == "" {
+		return errors.New("OWNER_MNEMONIC env is not set")
+	}
+	// The key doesn't depend on the wallet version.
+	owner, err := wallet.FromSeed(nil, strings.Split(mnemonic, " "), wallet.V3R2)
+	if err != nil {
+		return err
+	}
+	projects, err := eprojects.LoadProjects(path)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(eprojects.Sign(projects, owner.PrivateKey())); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Projects root to announce: 0x%x\n", projects.MerkleRoot())
+	return nil
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: OWNER_MNEMONIC=... sign-projects <projects file>")
+		os.Exit(1)
+	}
+	if err := signProjects(os.Args[1]); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
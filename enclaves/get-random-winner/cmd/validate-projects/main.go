@@ -0,0 +1,57 @@
+// Command validate-projects checks the project list file before it is built into the enclave or signed.
+// Every invalid project is reported with its line in the file.
+//
+// Usage:
+//
+//	validate-projects [-signed] buidls.json
+package main
+
+import (
+	"enclave/eprojects"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func validateProjects(path string, signed bool) error {
+	var projects eprojects.Projects
+	var err error
+	if signed {
+		var signedProjects eprojects.SignedProjects
+		signedProjects, err = eprojects.LoadSignedProjects(path)
+		if err == nil {
+			projects = signedProjects.Projects
+			err = projects.Validate()
+		}
+	} else {
+		projects, err = eprojects.LoadProjects(path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("OK: %d projects, root %x\n", len(projects), projects.MerkleRoot())
+	return nil
+}
+
+func main() {
+	signed := flag.Bool("signed", false, "Validate the signed project list made by sign-projects")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: validate-projects [-signed] <projects file>")
+		os.Exit(1)
+	}
+
+	if err := validateProjects(flag.Arg(0), *signed); err != nil {
+		// Joined validation errors are printed one per line.
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, e := range joined.Unwrap() {
+				fmt.Println("Error:", e)
+			}
+		} else {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+// Command verify-vrf checks the VRF proof published with a reveal and reproduces the random value,
+// so the randomness can be checked without trusting the operator.
+// The key is the enclave public key (the enclavePublicKey contract getter), the input is
+// the hash of the request transaction and the proof is the VRF proof of the reveal message.
+//
+// Usage:
+//
+//	verify-vrf -key <hex> -alpha <hex> -proof <hex> [-range <value>]
+//	verify-vrf -key <hex> -alpha <hex> -proof <hex> -projects buidls.json [-exclude 1,2] [-winners 1]
+package main
+
+import (
+	"crypto/ed25519"
+	"enclave/eprojects"
+	"enclave/erand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type options struct {
+	key      string
+	alpha    string
+	proof    string
+	valueMax string
+	projects string
+	exclude  string
+	winners  int
+}
+
+func decodeHex(name string, value string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func verify(opts options) error {
+	key, err := decodeHex("key", opts.key)
+	if err != nil {
+		return err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New("key must be 32 bytes")
+	}
+	alpha, err := decodeHex("alpha", opts.alpha)
+	if err != nil {
+		return err
+	}
+	proof, err := decodeHex("proof", opts.proof)
+	if err != nil {
+		return err
+	}
+	output, err := erand.VRFVerify(key, alpha, proof)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("VRF output: %x\n", output)
+
+	if opts.valueMax != "" {
+		maxValue, ok := new(big.Int).SetString(opts.valueMax, 10)
+		if !ok {
+			return errors.New("invalid range")
+		}
+		value, err := erand.BigIntFrom(erand.NewVRFStream(output), maxValue)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Random value: %s\n", value)
+	}
+	if opts.projects != "" {
+		return printDraw(opts, output)
+	}
+	return nil
+}
+
+// printDraw reproduces the draw from the remaining projects, the list must have the committed weights hash.
+func printDraw(opts options, output []byte) error {
+	projects, err := eprojects.LoadProjects(opts.projects)
+	if err != nil {
+		return err
+	}
+	excluded := map[int]bool{}
+	for _, field := range strings.FieldsFunc(opts.exclude, func(r rune) bool { return r == ',' }) {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return fmt.Errorf("invalid excluded ID: %w", err)
+		}
+		excluded[id] = true
+	}
+	remaining := projects.Exclude(excluded)
+	indexes, err := erand.WeightedSampleFrom(erand.NewVRFStream(output), remaining.Weights(), max(opts.winners, 1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Weights hash: %x\n", remaining.WeightsHash())
+	for _, index := range indexes {
+		project := remaining[index]
+		fmt.Printf("Winner: #%d %q (index %d)\n", project.ID, project.Name, projects.IndexOf(project.ID))
+	}
+	return nil
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.key, "key", "", "Enclave public key (hex)")
+	flag.StringVar(&opts.alpha, "alpha", "", "VRF input: the request transaction hash (hex)")
+	flag.StringVar(&opts.proof, "proof", "", "VRF proof (hex)")
+	flag.StringVar(&opts.valueMax, "range", "", "Range of the requested random value")
+	flag.StringVar(&opts.projects, "projects", "", "Project list to reproduce the draw")
+	flag.StringVar(&opts.exclude, "exclude", "", "Comma separated IDs of projects revealed before the draw")
+	flag.IntVar(&opts.winners, "winners", 1, "Number of winners drawn")
+	flag.Parse()
+
+	if opts.key == "" || opts.alpha == "" || opts.proof == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := verify(opts); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
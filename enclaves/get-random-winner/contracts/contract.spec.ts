@@ -15,7 +15,12 @@ import {
     storeTransaction,
     toNano,
 } from "@ton/core";
-import { OracleContract, UpdateCommit, RandomHash, RandomValue, RevealedValue, storeUpdateCommit, storeRevealedValue, storeRandomHash, storeRandomValue } from "./output/oracle_OracleContract";
+import {
+    OracleContract, UpdateCommit, RandomHash, RandomValue, RevealedValue, RandomWinners, RevealedWinners,
+    RandomRequestHash, RandomRequestValue, RevealedRandom,
+    storeUpdateCommit, storeRevealedValue, storeRandomHash, storeRandomValue, storeRandomWinners, storeRevealedWinners,
+    storeRandomRequestHash, storeRandomRequestValue, storeRevealedRandom, storeRandomRequested, storeRandomCommitted, storeRandomResponse,
+} from "./output/oracle_OracleContract";
 
 
 function findOp(contract: SandboxContract<OracleContract>, name: string) {
@@ -53,6 +58,10 @@ describe("contract", () => {
     const now = Math.floor(Date.now() / 1000);
     const attestationReport = "test".repeat(257);
     const validNonce = BigInt(0xabcd);
+    const validWeightsHash = BigInt("0x" + "dd".repeat(32));
+    const validProjectsRoot = BigInt("0x" + "ee".repeat(32));
+    // The VRF proof is signed with the payload and checked off-chain, the contract only passes it through.
+    const validVrf = beginCell().storeUint(0xcc, 256).storeBuffer(Buffer.alloc(80, 0xdd)).endCell();
 
     const stateInit = 0;
     const stateRock = 1;
@@ -109,6 +118,9 @@ describe("contract", () => {
             revealTimestamp: BigInt(now),
             nonce: validNonce,
             txHash: beginCell().endCell(),
+            weightsHash: validWeightsHash,
+            index: 3n,
+            vrf: validVrf,
         };
 
         let revealed: RevealedValue = {
@@ -118,6 +130,9 @@ describe("contract", () => {
             nonce: validNonce,
             doraId: BigInt(0xaaaaaa),
             name: "Test project",
+            weightsHash: validWeightsHash,
+            projectsRoot: validProjectsRoot,
+            index: 3n,
         }
 
         let hash = beginCell().store(storeRevealedValue(revealed)).endCell().hash();
@@ -126,6 +141,7 @@ describe("contract", () => {
             timestamp: BigInt(now),
             recipient: contract.address,
             valueHash: beginCell().storeBuffer(hash).endCell(),
+            projectsRoot: validProjectsRoot,
         };
 
     });
@@ -290,4 +306,137 @@ describe("contract", () => {
         expect(estate.changed).toEqual(BigInt(stateRock));
     });
 
+    it("should serve random value requests", async() => {
+        const queryId = 7n;
+        const range = 1000n;
+        let res = await contract.send(clientSender, { value: toNano("0.5") }, { $$type: "RandomRequest", queryId, range });
+        expect(res.externals.length).toBe(1);
+        expect(res.externals[0].body).toEqualCell(
+            beginCell().store(storeRandomRequested({ $$type: "RandomRequested", requester: client.address, queryId, range })).endCell()
+        );
+
+        let revealed: RevealedRandom = {
+            $$type: "RevealedRandom",
+            timestamp: BigInt(now),
+            recipient: contract.address,
+            requester: client.address,
+            queryId,
+            value: 123n,
+            nonce: validNonce,
+            txHash: beginCell().storeUint(0xcc, 256).endCell(),
+        };
+        let valueHash = beginCell().store(storeRevealedRandom(revealed)).endCell().hash();
+        let commit: RandomRequestHash = {
+            $$type: "RandomRequestHash",
+            timestamp: BigInt(now),
+            recipient: contract.address,
+            requester: client.address,
+            queryId,
+            valueHash: beginCell().storeBuffer(valueHash).endCell(),
+        };
+        let signature = sign(beginCell().store(storeRandomRequestHash(commit)).endCell().hash(), enclaveKeyPair.secretKey);
+        res = await contract.send(sender, { value: toNano("0.5") }, {
+            $$type: "UpdateRandomCommit",
+            signature: beginCell().storeBuffer(signature).endCell(),
+            payload: commit,
+        });
+        expect(res.externals.length).toBe(1);
+        expect(res.externals[0].body).toEqualCell(
+            beginCell().store(storeRandomCommitted({ $$type: "RandomCommitted", requester: client.address, queryId })).endCell()
+        );
+
+        let reveal: RandomRequestValue = {
+            $$type: "RandomRequestValue",
+            requester: client.address,
+            queryId,
+            value: revealed.value,
+            nonce: validNonce,
+            txHash: revealed.txHash,
+            vrf: validVrf,
+        };
+        signature = sign(beginCell().store(storeRandomRequestValue(reveal)).endCell().hash(), enclaveKeyPair.secretKey);
+        res = await contract.send(sender, { value: toNano("0.5") }, {
+            $$type: "UpdateRandomReveal",
+            signature: beginCell().storeBuffer(signature).endCell(),
+            payload: reveal,
+        });
+        expect(res.transactions).toHaveTransaction({
+            from: contract.address,
+            to: client.address,
+            body: beginCell().store(storeRandomResponse({ $$type: "RandomResponse", queryId, value: revealed.value })).endCell(),
+        });
+    });
+
+    it("should reject commits from not announced project list", async() => {
+        await contract.send(sender, { value: toNano("0.05") }, { $$type: "AnnounceProjects", projectsRoot: validProjectsRoot });
+        expect(await contract.getProjectsRoot()).toEqual(validProjectsRoot);
+
+        await contract.send(clientSender, { value: toNano("0.5") }, "roll");
+        await sendUpdateCommit(
+            { ...validRandomHashPayload, projectsRoot: validProjectsRoot + 1n },
+            errorCode(contract, "Draw from not announced project list")
+        );
+        await sendUpdateCommit(validRandomHashPayload);
+    });
+
+    it("should announce projects by owner only", async() => {
+        let res = await contract.send(clientSender, { value: toNano("0.05") }, { $$type: "AnnounceProjects", projectsRoot: validProjectsRoot });
+        expect(res.transactions).toHaveTransaction({ from: client.address, to: contract.address, success: false });
+        expect(await contract.getProjectsRoot()).toEqual(0n);
+    });
+
+    it("should handle winners reveal", async() => {
+        await contract.send(clientSender, { value: toNano("0.5") }, "roll");
+
+        let second = beginCell()
+            .storeUint(0xbbbbbb, 64)
+            .storeUint(4, 32)
+            .storeRef(beginCell().storeStringTail("Second project").endCell())
+            .endCell();
+        let winners = beginCell()
+            .storeUint(0xaaaaaa, 64)
+            .storeUint(3, 32)
+            .storeRef(beginCell().storeStringTail("Test project").endCell())
+            .storeRef(second)
+            .endCell();
+        let revealed: RevealedWinners = {
+            $$type: "RevealedWinners",
+            timestamp: BigInt(now),
+            recipient: contract.address,
+            nonce: validNonce,
+            winners: winners,
+            weightsHash: validWeightsHash,
+            projectsRoot: validProjectsRoot,
+        };
+        let valueHash = beginCell().store(storeRevealedWinners(revealed)).endCell().hash();
+        await sendUpdateCommit({ ...validRandomHashPayload, valueHash: beginCell().storeBuffer(valueHash).endCell() });
+
+        let payload: RandomWinners = {
+            $$type: "RandomWinners",
+            winners: winners,
+            revealTimestamp: BigInt(now),
+            nonce: validNonce,
+            txHash: beginCell().endCell(),
+            weightsHash: validWeightsHash,
+            vrf: validVrf,
+        };
+        let hash = beginCell().store(storeRandomWinners(payload)).endCell().hash();
+        let signature = sign(hash, enclaveKeyPair.secretKey);
+        let res = await contract.send(
+            sender,
+            { value: toNano("0.5") },
+            {
+                $$type: "UpdateRevealWinners",
+                signature: beginCell().storeBuffer(signature).endCell(),
+                payload: payload,
+            }
+        );
+        expect(res.externals.length).toBe(1);
+        expect(res.externals[0].body).toEqualCell(beginCell().storeUint(0,32).storeStringTail("#11184810 #12303291").endCell());
+
+        let estate = await contract.getEventState();
+        expect(estate.changed).toEqual(BigInt(stateRock));
+        expect(estate.doraId).toEqual(BigInt(0xaaaaaa));
+    });
+
 });
@@ -0,0 +1,106 @@
+// Package ebalance provides the balance monitoring of the sender wallet and the contract.
+// Balances below the warning thresholds are logged with the projected number of updates left,
+// responses are not sent when the wallet balance is below the critical floor, so the wallet keeps enough to be topped up
+// and the watcher doesn't fail on transactions the wallet can't pay for.
+package ebalance
+
+import (
+	"context"
+	"enclave/efees"
+	"enclave/emetrics"
+	"enclave/tonnet"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"log/slog"
+	"math/big"
+)
+
+// ErrCriticalBalance is returned instead of sending when the wallet balance is below the critical floor.
+var ErrCriticalBalance = errors.New("wallet balance is below the critical floor")
+
+// Config holds the balance thresholds, a zero threshold is disabled.
+type Config struct {
+	WalletWarning   tlb.Coins // The wallet balance logged as low.
+	WalletCritical  tlb.Coins // The wallet balance responses are not sent below.
+	ContractWarning tlb.Coins // The contract balance logged as low.
+}
+
+// ContractBalance reads the contract balance, e.g. econtract.Contract.
+type ContractBalance interface {
+	Balance(ctx context.Context) (tlb.Coins, error)
+}
+
+// Monitor checks the balances before sending responses.
+type Monitor struct {
+	Chain    tonnet.Chain
+	Wallet   *address.Address
+	Contract ContractBalance
+	// Fees projects the number of updates left from the observed costs, not reported if nil.
+	Fees   *efees.Estimator
+	Config Config
+	// Metrics records the checked balances, nothing is recorded if nil.
+	Metrics *emetrics.Watch
+}
+
+// Balances are the checked balances.
+type Balances struct {
+	Wallet   tlb.Coins
+	Contract *tlb.Coins // nil if the contract balance can't be read.
+	// UpdatesLeft is the projected number of responses the wallet can pay for above the critical floor, -1 if unknown.
+	UpdatesLeft int64
+}
+
+// Check reads the balances and logs the ones below the warning thresholds.
+// It returns ErrCriticalBalance if the wallet balance is below the critical floor.
+// The contract balance is informational: the error reading it is logged.
+func (m Monitor) Check(ctx context.Context) (Balances, error) {
+	account, err := m.Chain.GetAccount(ctx, m.Wallet)
+	if err != nil {
+		return Balances{}, fmt.Errorf("wallet balance: %w", err)
+	}
+	balances := Balances{Wallet: account.Balance, UpdatesLeft: m.updatesLeft(account.Balance)}
+
+	if m.Contract != nil {
+		if balance, err := m.Contract.Balance(ctx); err == nil {
+			balances.Contract = &balance
+			if below(balance, m.Config.ContractWarning) {
+				slog.Warn("contract balance is low", "balance", balance.String(), "warning", m.Config.ContractWarning.String())
+			}
+		} else {
+			slog.Warn("can't check the contract balance", "error", err)
+		}
+	}
+
+	m.Metrics.Balances(balances.Wallet, balances.Contract)
+
+	switch {
+	case below(balances.Wallet, m.Config.WalletCritical):
+		return balances, fmt.Errorf("%w: %s TON, critical %s TON", ErrCriticalBalance, balances.Wallet, m.Config.WalletCritical)
+	case below(balances.Wallet, m.Config.WalletWarning):
+		slog.Warn("wallet balance is low", "balance", balances.Wallet.String(), "warning", m.Config.WalletWarning.String(),
+			"updates_left", balances.UpdatesLeft)
+	}
+	return balances, nil
+}
+
+// updatesLeft returns the number of updates the wallet balance above the critical floor pays for.
+func (m Monitor) updatesLeft(balance tlb.Coins) int64 {
+	if m.Fees == nil {
+		return -1
+	}
+	cost := m.Fees.UpdateCost().Nano()
+	if cost.Sign() <= 0 {
+		return -1
+	}
+	spendable := new(big.Int).Sub(balance.Nano(), m.Config.WalletCritical.Nano())
+	if spendable.Sign() <= 0 {
+		return 0
+	}
+	return spendable.Div(spendable, cost).Int64()
+}
+
+func below(balance, threshold tlb.Coins) bool {
+	return threshold.Nano().Sign() > 0 && balance.Nano().Cmp(threshold.Nano()) < 0
+}
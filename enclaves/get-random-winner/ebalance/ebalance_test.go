@@ -0,0 +1,72 @@
+package ebalance
+
+import (
+	"context"
+	"enclave/efees"
+	"enclave/tonnet/tonnettest"
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"testing"
+)
+
+var testWallet = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+
+type fakeContract struct {
+	balance tlb.Coins
+	err     error
+}
+
+func (c fakeContract) Balance(ctx context.Context) (tlb.Coins, error) {
+	return c.balance, c.err
+}
+
+func TestCheck(t *testing.T) {
+	config := Config{
+		WalletWarning:   tlb.MustFromTON("1"),
+		WalletCritical:  tlb.MustFromTON("0.1"),
+		ContractWarning: tlb.MustFromTON("0.5"),
+	}
+	fees := efees.NewEstimator(efees.Config{Initial: tlb.MustFromTON("0.05"), Max: tlb.MustFromTON("0.1")})
+
+	tests := []struct {
+		name        string
+		wallet      string
+		contract    fakeContract
+		config      Config
+		err         error
+		updatesLeft int64
+	}{
+		{"Enough", "5", fakeContract{balance: tlb.MustFromTON("1")}, config, nil, 98},
+		{"Low wallet", "0.6", fakeContract{balance: tlb.MustFromTON("0.1")}, config, nil, 10},
+		{"Critical wallet", "0.09", fakeContract{balance: tlb.MustFromTON("1")}, config, ErrCriticalBalance, 0},
+		{"Contract error", "5", fakeContract{err: errors.New("liteserver error")}, config, nil, 98},
+		{"Disabled thresholds", "0", fakeContract{}, Config{}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := tonnettest.NewFakeChain()
+			chain.SetBalance(testWallet, tlb.MustFromTON(tt.wallet))
+			monitor := Monitor{Chain: chain, Wallet: testWallet, Contract: tt.contract, Fees: fees, Config: tt.config}
+			balances, err := monitor.Check(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if balances.Wallet.String() != tlb.MustFromTON(tt.wallet).String() || balances.UpdatesLeft != tt.updatesLeft {
+				t.Errorf("Unexpected balances: %+v", balances)
+			}
+			if (balances.Contract == nil) != (tt.contract.err != nil) {
+				t.Errorf("Unexpected contract balance: %v", balances.Contract)
+			}
+		})
+	}
+
+	t.Run("Unknown updates left", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		chain.SetBalance(testWallet, tlb.MustFromTON("5"))
+		balances, err := Monitor{Chain: chain, Wallet: testWallet, Config: config}.Check(context.Background())
+		if err != nil || balances.UpdatesLeft != -1 || balances.Contract != nil {
+			t.Errorf("Unexpected balances: %+v, %v", balances, err)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Package econtract provides access to the get-methods of the oracle contract.
+package econtract
+
+import (
+	"context"
+	"crypto/ed25519"
+	"enclave/tonnet"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math"
+	"math/big"
+)
+
+// Contract reads the oracle contract state with get-methods.
+type Contract struct {
+	Chain   tonnet.Chain
+	Address *address.Address
+}
+
+// Owner returns the address of the contract owner.
+func (c Contract) Owner(ctx context.Context) (*address.Address, error) {
+	res, err := c.Chain.RunGetMethod(ctx, c.Address, "owner")
+	if err != nil {
+		return nil, fmt.Errorf("owner(): %w", err)
+	}
+	slice, err := res.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("owner(): %w", err)
+	}
+	return slice.LoadAddr()
+}
+
+// ProjectsRoot returns the Merkle root of the project list announced by the owner, nil if none is announced.
+func (c Contract) ProjectsRoot(ctx context.Context) ([]byte, error) {
+	res, err := c.Chain.RunGetMethod(ctx, c.Address, "projectsRoot")
+	if err != nil {
+		return nil, fmt.Errorf("projectsRoot(): %w", err)
+	}
+	root, err := res.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("projectsRoot(): %w", err)
+	}
+	if root.Sign() == 0 {
+		return nil, nil
+	}
+	return root.FillBytes(make([]byte, 32)), nil
+}
+
+// OwnerPublicKey returns the public key of the owner wallet.
+func (c Contract) OwnerPublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	owner, err := c.Owner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return WalletPublicKey(ctx, c.Chain, owner)
+}
+
+// EnclavePublicKey returns the public key the contract checks enclave signatures with.
+func (c Contract) EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	return getUint256(ctx, c.Chain, c.Address, "enclavePublicKey")
+}
+
+// EnclaveMeasurement returns the measurement (UniqueID) of the enclave attested in the contract.
+func (c Contract) EnclaveMeasurement(ctx context.Context) ([]byte, error) {
+	return getUint256(ctx, c.Chain, c.Address, "enclaveMeasurment")
+}
+
+// WalletPublicKey returns the public key of the wallet with the get_public_key get-method.
+func WalletPublicKey(ctx context.Context, chain tonnet.Chain, addr *address.Address) (ed25519.PublicKey, error) {
+	return getUint256(ctx, chain, addr, "get_public_key")
+}
+
+// getUint256 returns the uint256 result of the get-method as 32 big-endian bytes.
+func getUint256(ctx context.Context, chain tonnet.Chain, addr *address.Address, method string) ([]byte, error) {
+	res, err := chain.RunGetMethod(ctx, addr, method)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", method, err)
+	}
+	value, err := res.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", method, err)
+	}
+	if value.Sign() < 0 || value.BitLen() > 256 {
+		return nil, fmt.Errorf("%s(): unexpected value", method)
+	}
+	return value.FillBytes(make([]byte, 32)), nil
+}
+
+// OracleState is the state() report of the contract.
+type OracleState struct {
+	Name    string
+	Version uint32 // major << 24 | minor << 16 | patch
+	Owner   *address.Address
+}
+
+// VersionString returns the version as major.minor.patch.
+func (s OracleState) VersionString() string {
+	return fmt.Sprintf("%d.%d.%d", s.Version>>24, s.Version>>16&0xff, s.Version&0xffff)
+}
+
+// State returns the contract state() report.
+func (c Contract) State(ctx context.Context) (OracleState, error) {
+	res, err := c.Chain.RunGetMethod(ctx, c.Address, "state")
+	if err != nil {
+		return OracleState{}, fmt.Errorf("state(): %w", err)
+	}
+	tuple, err := res.Tuple(0)
+	if err != nil {
+		return OracleState{}, fmt.Errorf("state(): %w", err)
+	}
+	if len(tuple) != 3 {
+		return OracleState{}, errors.New("state(): unexpected result")
+	}
+	var state OracleState
+	if state.Name, err = loadString(tuple[0]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): name: %w", err)
+	}
+	version, ok := tuple[1].(*big.Int)
+	if !ok || !version.IsUint64() || version.Uint64() > math.MaxUint32 {
+		return OracleState{}, errors.New("state(): unexpected version")
+	}
+	state.Version = uint32(version.Uint64())
+	owner, ok := tuple[2].(*cell.Slice)
+	if !ok {
+		return OracleState{}, errors.New("state(): unexpected owner")
+	}
+	if state.Owner, err = owner.Copy().LoadAddr(); err != nil {
+		return OracleState{}, fmt.Errorf("state(): owner: %w", err)
+	}
+	return state, nil
+}
+
+// Balance returns the contract balance with the balance get-method.
+func (c Contract) Balance(ctx context.Context) (tlb.Coins, error) {
+	res, err := c.Chain.RunGetMethod(ctx, c.Address, "balance")
+	if err != nil {
+		return tlb.Coins{}, fmt.Errorf("balance(): %w", err)
+	}
+	balance, err := res.Int(0)
+	if err != nil {
+		return tlb.Coins{}, fmt.Errorf("balance(): %w", err)
+	}
+	return tlb.FromNanoTON(balance), nil
+}
@@ -0,0 +1,83 @@
+package econtract
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"enclave/tonnet/tonnettest"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+)
+
+var (
+	testContract = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+	testOwner    = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+)
+
+func TestContract(t *testing.T) {
+	ctx := context.Background()
+	chain := tonnettest.NewFakeChain()
+	contract := Contract{Chain: chain, Address: testContract}
+	ownerKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.SetGetMethod(testContract, "owner", cell.BeginCell().MustStoreAddr(testOwner).EndCell().BeginParse())
+	chain.SetGetMethod(testOwner, "get_public_key", new(big.Int).SetBytes(ownerKey))
+
+	t.Run("Owner public key", func(t *testing.T) {
+		key, err := contract.OwnerPublicKey(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !key.Equal(ownerKey) {
+			t.Errorf("Unexpected key: %x", key)
+		}
+	})
+
+	t.Run("Projects root is not announced", func(t *testing.T) {
+		chain.SetGetMethod(testContract, "projectsRoot", big.NewInt(0))
+		root, err := contract.ProjectsRoot(ctx)
+		if err != nil || root != nil {
+			t.Errorf("Unexpected root: %x, %v", root, err)
+		}
+	})
+
+	t.Run("Projects root", func(t *testing.T) {
+		expected := bytes.Repeat([]byte{0x01}, 32)
+		chain.SetGetMethod(testContract, "projectsRoot", new(big.Int).SetBytes(expected))
+		root, err := contract.ProjectsRoot(ctx)
+		if err != nil || !bytes.Equal(root, expected) {
+			t.Errorf("Unexpected root: %x, %v", root, err)
+		}
+	})
+
+	t.Run("State", func(t *testing.T) {
+		name := cell.BeginCell().MustStoreStringSnake("get-random-winner").EndCell().BeginParse()
+		owner := cell.BeginCell().MustStoreAddr(testOwner).EndCell().BeginParse()
+		chain.SetGetMethod(testContract, "state", []any{name, big.NewInt(1<<24 | 2<<16 | 3), owner})
+		state, err := contract.State(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state.Name != "get-random-winner" || state.VersionString() != "1.2.3" || state.Owner.String() != testOwner.String() {
+			t.Errorf("Unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("Balance", func(t *testing.T) {
+		chain.SetGetMethod(testContract, "balance", big.NewInt(1_500_000_000))
+		balance, err := contract.Balance(ctx)
+		if err != nil || balance.String() != "1.5" {
+			t.Errorf("Unexpected balance: %s, %v", balance, err)
+		}
+	})
+
+	t.Run("Unknown method", func(t *testing.T) {
+		if _, err := (Contract{Chain: chain, Address: testOwner}).Owner(ctx); err == nil {
+			t.Errorf("Expected error not raised")
+		}
+	})
+}
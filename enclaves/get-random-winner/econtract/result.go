@@ -0,0 +1,167 @@
+package econtract
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// ErrRejected is wrapped by the errors of contract transactions which didn't accept the enclave response.
+var ErrRejected = errors.New("rejected by the contract")
+
+// Errors thrown by the require() checks of the contract, the message is the Tact error message.
+var (
+	ErrInvalidSignature        = errors.New("Invalid signature")
+	ErrWrongRecipient          = errors.New("Received an update intended for another contract.")
+	ErrProjectsNotAnnounced    = errors.New("Draw from not announced project list")
+	ErrInvalidRevealHash       = errors.New("Invalid hash for revealed value")
+	ErrUnknownRandomRequest    = errors.New("Unknown random request")
+	ErrRequestCommitted        = errors.New("Random request is already committed")
+	ErrRequestNotCommitted     = errors.New("Random request is not committed")
+	ErrRandomValueOutOfRange   = errors.New("Random value is out of range")
+	ErrTimestampOutdated       = errors.New("Timestamp is outdated.")
+	ErrEventNotCompleted       = errors.New("Event is not completed.")
+	errUnexpectedDescription   = errors.New("unexpected transaction description")
+	errComputePhaseSkipped     = errors.New("compute phase is skipped")
+	errRejectedWithUnknownText = errors.New("unknown rejection")
+)
+
+// tactErrors are the require() errors by their exit codes.
+var tactErrors = map[int32]error{}
+
+// stateRejections are the rejections replied by the contract when the command doesn't match the event state.
+var stateRejections = []error{
+	ErrEventCompleted, ErrRockValue, ErrRollValue, ErrRockRejected, ErrRollRejected, ErrNotRollState, ErrNotWaitRevealState,
+}
+
+func init() {
+	for _, err := range []error{
+		ErrInvalidSignature, ErrWrongRecipient, ErrProjectsNotAnnounced, ErrInvalidRevealHash,
+		ErrUnknownRandomRequest, ErrRequestCommitted, ErrRequestNotCommitted, ErrRandomValueOutOfRange,
+		ErrTimestampOutdated, ErrEventNotCompleted,
+	} {
+		tactErrors[TactExitCode(err.Error())] = err
+	}
+}
+
+// TactExitCode returns the exit code Tact assigns to the require() error message:
+// the first 4 bytes of its SHA-256 hash as a big-endian number, modulo 63000, plus 1000.
+func TactExitCode(message string) int32 {
+	hash := sha256.Sum256([]byte(message))
+	return int32(binary.BigEndian.Uint32(hash[:4])%63000 + 1000)
+}
+
+// ExitError is the failed compute phase of the contract transaction.
+// Err is the require() error of the exit code, nil if the code is unknown, e.g. a TVM error.
+type ExitError struct {
+	Code int32
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: exit code %d: %v", ErrRejected, e.Code, e.Err)
+	}
+	return fmt.Sprintf("%v: exit code %d", ErrRejected, e.Code)
+}
+
+func (e *ExitError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrRejected}
+	}
+	return []error{ErrRejected, e.Err}
+}
+
+// ActionError is the failed action phase of the contract transaction, e.g. 37 if the balance is not enough to send messages.
+type ActionError struct {
+	Code int32
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("%v: action phase result code %d", ErrRejected, e.Code)
+}
+
+func (e *ActionError) Unwrap() error {
+	return ErrRejected
+}
+
+// RejectionError is the rejection replied by the contract to the sender as a text comment.
+// Err is the state rejection error with the replied text.
+type RejectionError struct {
+	Reply string
+	Err   error
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrRejected, e.Reply)
+}
+
+func (e *RejectionError) Unwrap() []error {
+	return []error{ErrRejected, e.Err}
+}
+
+// CheckTransaction returns the error of the contract transaction processing the message of the sender:
+// ExitError if the compute phase failed, ActionError if the action phase failed,
+// or RejectionError if the contract replied with a rejection comment to the sender.
+func CheckTransaction(tx *tlb.Transaction, sender *address.Address) error {
+	var description tlb.TransactionDescriptionOrdinary
+	switch value := tx.Description.Description.(type) {
+	case tlb.TransactionDescriptionOrdinary:
+		description = value
+	case *tlb.TransactionDescriptionOrdinary:
+		description = *value
+	default:
+		return errUnexpectedDescription
+	}
+
+	var compute tlb.ComputePhaseVM
+	switch value := description.ComputePhase.Phase.(type) {
+	case tlb.ComputePhaseVM:
+		compute = value
+	case *tlb.ComputePhaseVM:
+		compute = *value
+	default:
+		return fmt.Errorf("%w: %w", ErrRejected, errComputePhaseSkipped)
+	}
+	if !compute.Success {
+		return &ExitError{Code: compute.Details.ExitCode, Err: tactErrors[compute.Details.ExitCode]}
+	}
+	if action := description.ActionPhase; action != nil && !action.Success {
+		return &ActionError{Code: action.ResultCode}
+	}
+
+	if tx.IO.Out == nil {
+		return nil
+	}
+	messages, err := tx.IO.Out.ToSlice()
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if msg.MsgType != tlb.MsgTypeInternal || !sameAddress(msg.AsInternal().DstAddr, sender) {
+			continue
+		}
+		if reply := msg.AsInternal().Comment(); reply != "" {
+			return &RejectionError{Reply: reply, Err: rejection(reply)}
+		}
+	}
+	return nil
+}
+
+// sameAddress compares the account addresses, ignoring the bounceable and testnet flags.
+func sameAddress(a, b *address.Address) bool {
+	return a.Workchain() == b.Workchain() && bytes.Equal(a.Data(), b.Data())
+}
+
+func rejection(reply string) error {
+	for _, err := range stateRejections {
+		if err.Error() == reply {
+			return err
+		}
+	}
+	return errRejectedWithUnknownText
+}
@@ -0,0 +1,86 @@
+package econtract
+
+import (
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+)
+
+func TestTactExitCode(t *testing.T) {
+	// The exit code of the Tact standard library "Invalid sender" error.
+	if code := TactExitCode("Invalid sender"); code != 4429 {
+		t.Errorf("Unexpected exit code: %d", code)
+	}
+}
+
+// testTransaction returns the contract transaction with the exit code, the action phase result and replies to the sender.
+func testTransaction(exitCode int32, actionSuccess bool, sender *address.Address, replies ...string) *tlb.Transaction {
+	compute := tlb.ComputePhaseVM{Success: exitCode == 0}
+	compute.Details.ExitCode = exitCode
+	tx := &tlb.Transaction{}
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: compute},
+		ActionPhase:  &tlb.ActionPhase{Success: actionSuccess, ResultCode: 37},
+	}
+	if len(replies) == 0 {
+		return tx
+	}
+	dict := cell.NewDict(15)
+	for i, reply := range replies {
+		msgCell, err := tlb.ToCell(&tlb.InternalMessage{
+			SrcAddr: testContract,
+			DstAddr: sender,
+			Amount:  tlb.ZeroCoins,
+			Body:    cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake(reply).EndCell(),
+		})
+		if err != nil {
+			panic(err)
+		}
+		dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreRef(msgCell).EndCell())
+	}
+	tx.IO.Out = &tlb.MessagesList{List: dict}
+	return tx
+}
+
+func TestCheckTransaction(t *testing.T) {
+	sender := testOwner
+	tests := []struct {
+		name     string
+		tx       *tlb.Transaction
+		expected []error
+	}{
+		{"Accepted", testTransaction(0, true, sender), nil},
+		{"Invalid signature", testTransaction(TactExitCode("Invalid signature"), false, sender), []error{ErrRejected, ErrInvalidSignature}},
+		{"Outdated timestamp", testTransaction(TactExitCode("Timestamp is outdated."), false, sender), []error{ErrRejected, ErrTimestampOutdated}},
+		{"Unknown exit code", testTransaction(9, false, sender), []error{ErrRejected}},
+		{"Action phase failed", testTransaction(0, false, sender), []error{ErrRejected}},
+		{"State rejection", testTransaction(0, true, sender, ErrNotRollState.Error()), []error{ErrRejected, ErrNotRollState}},
+		{"Reply to another address", testTransaction(0, true, testContract, ErrNotRollState.Error()), nil},
+		{"Unknown transaction", &tlb.Transaction{}, []error{errUnexpectedDescription}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTransaction(tt.tx, sender.Bounce(false))
+			if tt.expected == nil && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.expected != nil && err == nil {
+				t.Fatal("Expected error not raised")
+			}
+			for _, expected := range tt.expected {
+				if !errors.Is(err, expected) {
+					t.Errorf("Error %v is not %v", err, expected)
+				}
+			}
+		})
+	}
+
+	var exitErr *ExitError
+	err := CheckTransaction(testTransaction(TactExitCode("Invalid signature"), false, sender), sender)
+	if !errors.As(err, &exitErr) || exitErr.Code != TactExitCode("Invalid signature") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package econtract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+)
+
+// Event states, as in contracts/state.tact.
+const (
+	StateInit       = 0
+	StateRock       = 1
+	StateRoll       = 2
+	StateWaitReveal = 3
+	StateCompleted  = 15
+)
+
+const (
+	// StakeMinValue is the minimal stake on rock or roll, in nanotons.
+	StakeMinValue = 20_000_000
+	// WaitRevealMaxPeriod is the period in seconds after which a roll or a commit waiting for the reveal times out,
+	// waitRevealMaxPeriod of contracts/state.tact. The pending draw commitments expire after it.
+	WaitRevealMaxPeriod = 120
+)
+
+// Rejections replied by the contract when the command doesn't match the event state.
+var (
+	ErrEventCompleted       = errors.New("Event is completed.")
+	ErrRockValue            = errors.New("Value amount is not enough to Rock")
+	ErrRollValue            = errors.New("Value amount is not enough to Roll")
+	ErrRockRejected         = errors.New("Rejected. We're rolling to next BUIDl.")
+	ErrRollRejected         = errors.New("Rejected. We're already rolling.")
+	ErrNotRollState         = errors.New("Rejected. Not in a Roll state.")
+	ErrNotWaitRevealState   = errors.New("Rejected. Not in WaitReveal state.")
+	errUnexpectedEventState = errors.New("eventState(): unexpected result")
+)
+
+// EventState mirrors the EventState struct and the state machine of contracts/state.tact.
+// Methods take the block time now, as Unix seconds, and change the state as the contract does.
+type EventState struct {
+	State          uint8  `json:"state"`
+	Changed        uint8  `json:"changed"`
+	Updated        uint32 `json:"updated"`
+	CompletionTime uint64 `json:"completionTime"`
+	StakeOnRock    uint64 `json:"stakeOnRock"`
+	StakeOnRoll    uint64 `json:"stakeOnRoll"`
+	DoraID         uint64 `json:"doraId"`
+	Name           string `json:"name"`
+	Prize          uint64 `json:"prize"`
+}
+
+// EventState returns the event state with the eventState get-method.
+func (c Contract) EventState(ctx context.Context) (EventState, error) {
+	res, err := c.Chain.RunGetMethod(ctx, c.Address, "eventState")
+	if err != nil {
+		return EventState{}, fmt.Errorf("eventState(): %w", err)
+	}
+	tuple, err := res.Tuple(0)
+	if err != nil {
+		return EventState{}, fmt.Errorf("eventState(): %w", err)
+	}
+	return ParseEventState(tuple)
+}
+
+// ParseEventState parses the EventState struct returned by a get-method as a tuple of its fields.
+func ParseEventState(tuple []any) (EventState, error) {
+	var state EventState
+	if len(tuple) != 9 {
+		return state, errUnexpectedEventState
+	}
+	ints := make([]uint64, 0, 8)
+	for i, value := range tuple {
+		if i == 7 {
+			continue
+		}
+		number, ok := value.(*big.Int)
+		if !ok || !number.IsUint64() {
+			return state, errUnexpectedEventState
+		}
+		ints = append(ints, number.Uint64())
+	}
+	name, err := loadString(tuple[7])
+	if err != nil {
+		return state, fmt.Errorf("eventState(): name: %w", err)
+	}
+	state = EventState{
+		State:          uint8(ints[0]),
+		Changed:        uint8(ints[1]),
+		Updated:        uint32(ints[2]),
+		CompletionTime: ints[3],
+		StakeOnRock:    ints[4],
+		StakeOnRoll:    ints[5],
+		DoraID:         ints[6],
+		Name:           name,
+		Prize:          ints[7],
+	}
+	return state, nil
+}
+
+// loadString loads the Tact String, returned as a slice or a cell.
+func loadString(value any) (string, error) {
+	switch value := value.(type) {
+	case *cell.Slice:
+		return value.Copy().LoadStringSnake()
+	case *cell.Cell:
+		return value.BeginParse().LoadStringSnake()
+	}
+	return "", errUnexpectedEventState
+}
+
+// StateName returns the name of the event state.
+func StateName(state uint8) string {
+	switch state {
+	case StateInit:
+		return "init"
+	case StateRock:
+		return "rock"
+	case StateRoll:
+		return "roll"
+	case StateWaitReveal:
+		return "wait-reveal"
+	case StateCompleted:
+		return "completed"
+	}
+	return fmt.Sprintf("unknown (%d)", state)
+}
+
+// Completed reports whether the event completion time is passed or the event is completed.
+func (s EventState) Completed(now uint32) bool {
+	return s.State == StateCompleted || uint64(now) > s.CompletionTime
+}
+
+// TimedOut reports whether the roll or the commit waiting for the reveal has timed out.
+func (s EventState) TimedOut(now uint32) bool {
+	return int64(now)-int64(s.Updated) > WaitRevealMaxPeriod
+}
+
+// Rock stakes the value on the current winner.
+func (s *EventState) Rock(value uint64, now uint32) error {
+	if uint64(now) > s.CompletionTime {
+		s.State = StateCompleted
+		return ErrEventCompleted
+	}
+	if value < StakeMinValue {
+		return ErrRockValue
+	}
+	if s.State != StateRock {
+		return ErrRockRejected
+	}
+	s.StakeOnRock += value
+	s.update(StateRock, now)
+	return nil
+}
+
+// Roll stakes the value on the next draw, it starts the draw if the roll stake outweighs the rock stake.
+func (s *EventState) Roll(value uint64, now uint32) error {
+	if uint64(now) > s.CompletionTime {
+		s.State = StateCompleted
+		return ErrEventCompleted
+	}
+	timeout := s.TimedOut(now)
+	if value < StakeMinValue {
+		return ErrRollValue
+	}
+	if s.State != StateRock && s.State != StateInit && !timeout {
+		return ErrRollRejected
+	}
+	s.StakeOnRoll += value
+	if (s.State == StateRoll || s.State == StateWaitReveal) && timeout {
+		// The draw is reset to roll again.
+		s.update(StateInit, now)
+	}
+	next := uint8(StateRoll)
+	if s.State == StateRock && s.StakeOnRoll <= s.StakeOnRock {
+		next = StateRock
+	}
+	s.update(next, now)
+	return nil
+}
+
+// WaitReveal accepts the commit of the drawn value. The commit waiting for the reveal isn't replaced,
+// after the timeout the draw is rolled again instead.
+func (s *EventState) WaitReveal(now uint32) error {
+	if uint64(now) > s.CompletionTime {
+		s.State = StateCompleted
+		return ErrEventCompleted
+	}
+	if s.State != StateRoll {
+		return ErrNotRollState
+	}
+	s.update(StateWaitReveal, now)
+	return nil
+}
+
+// Reveal accepts the revealed winner.
+func (s *EventState) Reveal(doraID uint64, name string, now uint32) error {
+	if uint64(now) > s.CompletionTime {
+		s.State = StateCompleted
+		return ErrEventCompleted
+	}
+	if s.State != StateWaitReveal {
+		return ErrNotWaitRevealState
+	}
+	s.DoraID = doraID
+	s.Name = name
+	s.StakeOnRock = StakeMinValue
+	s.StakeOnRoll = 0
+	s.update(StateRock, now)
+	return nil
+}
+
+func (s *EventState) update(state uint8, now uint32) {
+	s.Updated = now
+	if s.State != state {
+		s.Changed = state
+	} else {
+		s.Changed = StateInit
+	}
+	s.State = state
+}
@@ -0,0 +1,87 @@
+package econtract
+
+import (
+	"context"
+	"enclave/tonnet/tonnettest"
+	"errors"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+)
+
+func TestEventStateGetter(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	contract := Contract{Chain: chain, Address: testContract}
+	name := cell.BeginCell().MustStoreStringSnake("Test project").EndCell().BeginParse()
+	chain.SetGetMethod(testContract, "eventState", []any{
+		big.NewInt(StateWaitReveal), big.NewInt(StateWaitReveal), big.NewInt(1000), big.NewInt(2000),
+		big.NewInt(1), big.NewInt(2), big.NewInt(3), name, big.NewInt(4),
+	})
+
+	state, err := contract.EventState(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := EventState{
+		State:          StateWaitReveal,
+		Changed:        StateWaitReveal,
+		Updated:        1000,
+		CompletionTime: 2000,
+		StakeOnRock:    1,
+		StakeOnRoll:    2,
+		DoraID:         3,
+		Name:           "Test project",
+		Prize:          4,
+	}
+	if state != expected {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+
+	chain.SetGetMethod(testContract, "eventState", []any{big.NewInt(1)})
+	if _, err := contract.EventState(context.Background()); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+}
+
+func TestEventStateMachine(t *testing.T) {
+	const updated = 1000
+	at := func(state uint8) EventState {
+		return EventState{State: state, Updated: updated, CompletionTime: 5000, StakeOnRock: StakeMinValue}
+	}
+	tests := []struct {
+		name     string
+		state    EventState
+		apply    func(s *EventState) error
+		err      error
+		expected uint8
+	}{
+		{"Roll outweighs rock", at(StateRock), func(s *EventState) error { return s.Roll(2*StakeMinValue, updated+1) }, nil, StateRoll},
+		{"Roll below rock", at(StateRock), func(s *EventState) error { return s.Roll(StakeMinValue, updated+1) }, nil, StateRock},
+		{"Roll value too low", at(StateRock), func(s *EventState) error { return s.Roll(1, updated+1) }, ErrRollValue, StateRock},
+		{"Already rolling", at(StateRoll), func(s *EventState) error { return s.Roll(StakeMinValue, updated+1) }, ErrRollRejected, StateRoll},
+		{"Reroll after timeout", at(StateWaitReveal), func(s *EventState) error {
+			return s.Roll(StakeMinValue, updated+WaitRevealMaxPeriod+1)
+		}, nil, StateRoll},
+		{"Rock while rolling", at(StateRoll), func(s *EventState) error { return s.Rock(StakeMinValue, updated+1) }, ErrRockRejected, StateRoll},
+		{"Commit on roll", at(StateRoll), func(s *EventState) error { return s.WaitReveal(updated + 1) }, nil, StateWaitReveal},
+		{"Repeated commit", at(StateWaitReveal), func(s *EventState) error { return s.WaitReveal(updated + 1) }, ErrNotRollState, StateWaitReveal},
+		{"Commit after reveal timeout", at(StateWaitReveal), func(s *EventState) error {
+			return s.WaitReveal(updated + WaitRevealMaxPeriod + 1)
+		}, ErrNotRollState, StateWaitReveal},
+		{"Commit without roll", at(StateRock), func(s *EventState) error { return s.WaitReveal(updated + 1) }, ErrNotRollState, StateRock},
+		{"Reveal", at(StateWaitReveal), func(s *EventState) error { return s.Reveal(7, "Winner", updated+1) }, nil, StateRock},
+		{"Reveal without commit", at(StateRoll), func(s *EventState) error { return s.Reveal(7, "Winner", updated+1) }, ErrNotWaitRevealState, StateRoll},
+		{"Event completed", at(StateRoll), func(s *EventState) error { return s.WaitReveal(5001) }, ErrEventCompleted, StateCompleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			if err := tt.apply(&state); !errors.Is(err, tt.err) {
+				t.Errorf("Unexpected error: %v, expected %v", err, tt.err)
+			}
+			if state.State != tt.expected {
+				t.Errorf("Unexpected state %d, expected %d", state.State, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package efees estimates the value attached to enclave responses.
+// The value is not emulated: the TVM emulator is a C++ library which doesn't build for the enclave,
+// so the response handler of the contract isn't run against the fetched account state.
+// Instead the value is estimated from the contract transactions which processed the previous responses
+// with the same opcode: their fees grow with the contract storage,
+// the value is the last observed cost with a safety margin, capped by the configured maximum.
+// Switching to the emulation needs an emulator running in the enclave.
+// The estimate is seeded from the latest contract transactions at startup, see Estimator.Seed,
+// the initial value is only used for the responses never sent to the contract.
+package efees
+
+import (
+	"bytes"
+	"context"
+	"enclave/econtract"
+	"enclave/tonnet"
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"sync"
+)
+
+// Codes of the contract transactions failed because the attached value was not enough.
+const (
+	exitCodeOutOfGas  = 13 // compute phase
+	resultCodeNoFunds = 37 // action phase, not enough value to send messages
+)
+
+// seedDepth is the number of the latest contract transactions the estimate is seeded from.
+const seedDepth = 16
+
+// Config holds the response value limits.
+type Config struct {
+	Initial tlb.Coins // Value of the responses with the opcode never observed.
+	Max     tlb.Coins // Cap of the estimated value.
+	Margin  uint64    // Percent added to the observed cost.
+}
+
+// Estimator estimates the response values, it's safe for concurrent use.
+type Estimator struct {
+	config Config
+
+	mu         sync.Mutex
+	cost       map[uint64]*big.Int // last observed cost by the response opcode, in nanotons
+	senderFees tlb.Coins           // last observed fees of the sender wallet transaction
+}
+
+// NewEstimator creates a new Estimator.
+func NewEstimator(config Config) *Estimator {
+	return &Estimator{config: config, cost: map[uint64]*big.Int{}}
+}
+
+// Value returns the value to attach to the response with the body.
+func (e *Estimator) Value(body *cell.Cell) tlb.Coins {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cost, ok := e.cost[opcode(body)]
+	if !ok {
+		return e.capped(e.config.Initial.Nano())
+	}
+	return e.value(cost)
+}
+
+// UpdateCost returns the wallet spending on one response: the largest estimated value and the wallet fees.
+func (e *Estimator) UpdateCost() tlb.Coins {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cost := new(big.Int)
+	if len(e.cost) == 0 {
+		cost.Set(e.capped(e.config.Initial.Nano()).Nano())
+	}
+	for _, observed := range e.cost {
+		if value := e.value(observed).Nano(); value.Cmp(cost) > 0 {
+			cost.Set(value)
+		}
+	}
+	return tlb.FromNanoTON(cost.Add(cost, e.senderFees.Nano()))
+}
+
+// ObserveSender records the fees of the sender wallet transaction.
+func (e *Estimator) ObserveSender(tx *tlb.Transaction) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.senderFees = tx.TotalFees.Coins
+}
+
+// value returns the observed cost with the margin, capped.
+func (e *Estimator) value(cost *big.Int) tlb.Coins {
+	value := new(big.Int).Mul(cost, new(big.Int).SetUint64(100+e.config.Margin))
+	value.Div(value, big.NewInt(100))
+	return e.capped(value)
+}
+
+// Observe records the cost of the contract transaction processing the response with the body sent with the value.
+// err is the result of econtract.CheckTransaction: the underpaid response doubles the value of the next ones,
+// other rejections are not recorded.
+func (e *Estimator) Observe(body *cell.Cell, sent tlb.Coins, tx *tlb.Transaction, sender *address.Address, err error) {
+	var cost *big.Int
+	switch {
+	case err == nil:
+		cost = Cost(tx, sender)
+	case underpaid(err):
+		cost = new(big.Int).Mul(sent.Nano(), big.NewInt(2))
+	default:
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cost[opcode(body)] = cost
+}
+
+// Seed observes the latest contract transactions processing the responses of the sender,
+// so after a restart the values are estimated from the chain history rather than Config.Initial.
+func (e *Estimator) Seed(ctx context.Context, chain tonnet.Chain, contract *address.Address, sender *address.Address) error {
+	account, err := chain.GetAccount(ctx, contract)
+	if err != nil || account.LastTxLT == 0 {
+		return err
+	}
+	transactions, err := chain.ListTransactions(ctx, contract, seedDepth, account.LastTxLT, account.LastTxHash)
+	if errors.Is(err, ton.ErrNoTransactionsWereFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// The transactions are listed from old to new, so the latest cost of the opcode is kept.
+	for _, tx := range transactions {
+		if tx.IO.In == nil || tx.IO.In.MsgType != tlb.MsgTypeInternal {
+			continue
+		}
+		in := tx.IO.In.AsInternal()
+		if !sameAddress(in.SrcAddr, sender) {
+			continue
+		}
+		e.Observe(in.Body, in.Amount, tx, sender, econtract.CheckTransaction(tx, sender))
+	}
+	return nil
+}
+
+// Cost returns the value spent by the contract transaction: the fees and the value of the messages it sent,
+// except the ones returned to the sender, e.g. the excess.
+func Cost(tx *tlb.Transaction, sender *address.Address) *big.Int {
+	cost := new(big.Int).Set(tx.TotalFees.Coins.Nano())
+	if tx.IO.Out == nil {
+		return cost
+	}
+	messages, err := tx.IO.Out.ToSlice()
+	if err != nil {
+		return cost
+	}
+	for _, msg := range messages {
+		if msg.MsgType != tlb.MsgTypeInternal {
+			continue
+		}
+		internal := msg.AsInternal()
+		if sameAddress(internal.DstAddr, sender) {
+			continue
+		}
+		cost.Add(cost, internal.Amount.Nano())
+	}
+	return cost
+}
+
+func sameAddress(a, b *address.Address) bool {
+	return a.Workchain() == b.Workchain() && bytes.Equal(a.Data(), b.Data())
+}
+
+func (e *Estimator) capped(value *big.Int) tlb.Coins {
+	if value.Cmp(e.config.Max.Nano()) > 0 {
+		return e.config.Max
+	}
+	return tlb.FromNanoTON(value)
+}
+
+// underpaid reports whether the contract transaction failed because the attached value was not enough.
+func underpaid(err error) bool {
+	var exitErr *econtract.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code == exitCodeOutOfGas
+	}
+	var actionErr *econtract.ActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Code == resultCodeNoFunds
+	}
+	return false
+}
+
+// opcode returns the response opcode, the values of responses are estimated by their kind.
+func opcode(body *cell.Cell) uint64 {
+	if body == nil {
+		return 0
+	}
+	value, err := body.BeginParse().LoadUInt(32)
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,175 @@
+package efees
+
+import (
+	"context"
+	"enclave/econtract"
+	"enclave/tonnet"
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+)
+
+var (
+	testSender = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	testOther  = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+)
+
+func testBody(opcode uint64) *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(opcode, 32).EndCell()
+}
+
+// testTransaction returns the transaction with the fees, sending the values to the addresses.
+func testTransaction(fees string, out map[*address.Address]string) *tlb.Transaction {
+	tx := &tlb.Transaction{}
+	tx.TotalFees.Coins = tlb.MustFromTON(fees)
+	dict := cell.NewDict(15)
+	i := int64(0)
+	for dst, amount := range out {
+		msgCell, err := tlb.ToCell(&tlb.InternalMessage{SrcAddr: testOther, DstAddr: dst, Amount: tlb.MustFromTON(amount)})
+		if err != nil {
+			panic(err)
+		}
+		dict.SetIntKey(big.NewInt(i), cell.BeginCell().MustStoreRef(msgCell).EndCell())
+		i++
+	}
+	tx.IO.Out = &tlb.MessagesList{List: dict}
+	return tx
+}
+
+func TestEstimator(t *testing.T) {
+	config := Config{Initial: tlb.MustFromTON("0.025"), Max: tlb.MustFromTON("0.1"), Margin: 20}
+	commit, reveal := testBody(1), testBody(2)
+
+	t.Run("Initial value", func(t *testing.T) {
+		fees := NewEstimator(config)
+		if value := fees.Value(commit); value.String() != "0.025" {
+			t.Errorf("Unexpected value: %s", value)
+		}
+	})
+
+	t.Run("Observed cost with margin", func(t *testing.T) {
+		fees := NewEstimator(config)
+		// The excess returned to the sender is not spent.
+		tx := testTransaction("0.005", map[*address.Address]string{testOther: "0.005", testSender: "0.01"})
+		fees.Observe(commit, config.Initial, tx, testSender, nil)
+		if value := fees.Value(commit); value.String() != "0.012" {
+			t.Errorf("Unexpected value: %s", value)
+		}
+		if value := fees.Value(reveal); value.String() != "0.025" {
+			t.Errorf("Unexpected value of another opcode: %s", value)
+		}
+	})
+
+	t.Run("Capped", func(t *testing.T) {
+		fees := NewEstimator(config)
+		fees.Observe(commit, config.Initial, testTransaction("0.2", nil), testSender, nil)
+		if value := fees.Value(commit); value.String() != "0.1" {
+			t.Errorf("Unexpected value: %s", value)
+		}
+	})
+
+	t.Run("Underpaid", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			err      error
+			expected string
+		}{
+			{"Out of gas", &econtract.ExitError{Code: exitCodeOutOfGas}, "0.06"},
+			{"Not enough funds", &econtract.ActionError{Code: resultCodeNoFunds}, "0.06"},
+			{"Other rejection", &econtract.ExitError{Code: 1000, Err: econtract.ErrInvalidSignature}, "0.025"},
+			{"Other error", errors.New("timeout"), "0.025"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fees := NewEstimator(config)
+				fees.Observe(commit, tlb.MustFromTON("0.025"), nil, testSender, tt.err)
+				if value := fees.Value(commit); value.String() != tt.expected {
+					t.Errorf("Unexpected value: %s", value)
+				}
+			})
+		}
+	})
+	t.Run("Update cost", func(t *testing.T) {
+		fees := NewEstimator(config)
+		if cost := fees.UpdateCost(); cost.String() != "0.025" {
+			t.Errorf("Unexpected initial cost: %s", cost)
+		}
+		fees.Observe(commit, config.Initial, testTransaction("0.01", nil), testSender, nil)
+		fees.Observe(reveal, config.Initial, testTransaction("0.05", nil), testSender, nil)
+		fees.ObserveSender(testTransaction("0.003", nil))
+		// The reveal value with the margin and the wallet fees.
+		if cost := fees.UpdateCost(); cost.String() != "0.063" {
+			t.Errorf("Unexpected cost: %s", cost)
+		}
+	})
+}
+
+// historyChain serves the contract transactions listed by Estimator.Seed.
+type historyChain struct {
+	tonnet.Chain
+	transactions []*tlb.Transaction
+}
+
+func (c historyChain) GetAccount(ctx context.Context, addr *address.Address) (tonnet.Account, error) {
+	if len(c.transactions) == 0 {
+		return tonnet.Account{}, nil
+	}
+	last := c.transactions[len(c.transactions)-1]
+	return tonnet.Account{IsActive: true, LastTxLT: last.LT, LastTxHash: last.Hash}, nil
+}
+
+func (c historyChain) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	return c.transactions[max(0, len(c.transactions)-int(num)):], nil
+}
+
+// testResponseTransaction returns the contract transaction processing the response with the body from the address.
+func testResponseTransaction(lt uint64, from *address.Address, body *cell.Cell, fees string, exitCode int32) *tlb.Transaction {
+	tx := testTransaction(fees, nil)
+	tx.LT = lt
+	tx.IO.In = &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: &tlb.InternalMessage{
+		SrcAddr: from, DstAddr: testOther, Amount: tlb.MustFromTON("0.025"), Body: body,
+	}}
+	compute := tlb.ComputePhaseVM{Success: exitCode == 0}
+	compute.Details.ExitCode = exitCode
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: compute},
+		ActionPhase:  &tlb.ActionPhase{Success: true},
+	}
+	return tx
+}
+
+func TestSeed(t *testing.T) {
+	config := Config{Initial: tlb.MustFromTON("0.025"), Max: tlb.MustFromTON("0.1"), Margin: 20}
+	commit, reveal, other := testBody(1), testBody(2), testBody(3)
+	chain := historyChain{transactions: []*tlb.Transaction{
+		testResponseTransaction(1, testSender, commit, "0.02", 0),
+		testResponseTransaction(2, testSender, commit, "0.01", 0),
+		testResponseTransaction(3, testSender, reveal, "0.02", exitCodeOutOfGas),
+		// Messages of other senders are not responses.
+		testResponseTransaction(4, testOther, other, "0.001", 0),
+	}}
+
+	fees := NewEstimator(config)
+	if err := fees.Seed(context.Background(), chain, testOther, testSender); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The latest cost of the commit, the underpaid reveal doubles the sent value.
+	for body, expected := range map[*cell.Cell]string{commit: "0.012", reveal: "0.06", other: "0.025"} {
+		if value := fees.Value(body); value.String() != expected {
+			t.Errorf("Unexpected value of the opcode %d: %s", opcode(body), value)
+		}
+	}
+
+	t.Run("No transactions", func(t *testing.T) {
+		fees := NewEstimator(config)
+		if err := fees.Seed(context.Background(), historyChain{}, testOther, testSender); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if value := fees.Value(commit); value.String() != "0.025" {
+			t.Errorf("Unexpected value: %s", value)
+		}
+	})
+}
@@ -2,105 +2,404 @@ package ehandlers
 
 import (
 	"context"
+	"enclave/econtract"
+	"enclave/efees"
+	"enclave/elog"
 	"enclave/emessages"
+	"enclave/emetrics"
 	"enclave/eprojects"
 	"enclave/erand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"github.com/tonteeton/golib/ekeys"
 	"github.com/tonteeton/golib/eresp"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton/wallet"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"math"
+	"log/slog"
+	"os"
+	"time"
 )
 
 // Config contains configuration parameters for generating an enclave response.
 type Config struct {
-	SenderWallet    *wallet.Wallet
+	Sender          Sender
 	ContractAddress *address.Address
+	ResponseValue   tlb.Coins
 	Response        eresp.Config
+
+	// Winners is the number of distinct winners drawn in one commit, 1 if not set.
+	Winners int
+
+	// ProjectsPath is the sealed project list imported with import-projects,
+	// the built-in ./buidls.json is used if the file doesn't exist.
+	ProjectsPath string
+	// RevealedPath is the sealed file of already revealed project IDs, kept in memory only if empty.
+	RevealedPath string
+	// PendingPath is the sealed file of commitments waiting for the reveal, kept in memory only if empty.
+	PendingPath string
+	// RevealTimeout is the period the contract waits for the reveal, pending commitments expire after it.
+	RevealTimeout time.Duration
+	// SealVersion is the additional data used for sealing.
+	SealVersion string
+	// Sealer and Unsealer override the default ekeys sealing, e.g. in tests.
+	Sealer   ekeys.DataSealer
+	Unsealer ekeys.DataSealer
+	// State is the contract event state, commands not matching it are skipped. It isn't checked if not set.
+	State EventStateReader
+	// Random is the source of commitment nonces, erand.CryptoSource if not set.
+	// Tests inject a seeded source to reproduce the messages.
+	Random erand.Source
+	// Fees estimates the value attached to responses, ResponseValue is attached if not set.
+	Fees *efees.Estimator
+	// Metrics records the response latencies and send failures, nothing is recorded if not set.
+	Metrics *emetrics.Watch
 }
 
 type Handlers struct {
-	config        Config
-	projects      eprojects.Projects
-	revealedValue emessages.RevealedValue
+	config   Config
+	projects eprojects.Projects
+	revealed *revealedSet
+	// projectsRoot is the Merkle root of the whole project list, committed with every draw.
+	projectsRoot []byte
+
+	// pending are committed values waiting for the reveal, by request ID.
+	pending *pendingSet
+	// lastDraw is the hash of the last draw request transaction, committed again after the reveal timeout.
+	lastDraw []byte
+	// deliveries are the results of the responses sent with an AsyncSender, see Deliveries.
+	deliveries chan func() error
+	// detected is when the command being handled was detected, the response latency is measured from it.
+	detected time.Time
 }
 
 func Init(config Config) (*Handlers, error) {
-	projects, err := eprojects.LoadProjects("./buidls.json")
+	projects, err := loadProjects(config)
 	if err != nil {
 		return nil, err
 	}
-	return &Handlers{config: config, projects: projects}, nil
+	return New(config, projects)
+}
+
+// loadProjects loads the imported project list, or the built-in one if nothing is imported.
+func loadProjects(config Config) (eprojects.Projects, error) {
+	if config.ProjectsPath != "" {
+		projects, err := eprojects.LoadSealedProjects(config.ProjectsPath, []byte(config.SealVersion), sealers(config.Unsealer)...)
+		if err == nil {
+			slog.Info("using imported project list", "projects", len(projects))
+			return projects, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return eprojects.LoadProjects("./buidls.json")
+}
+
+// New creates handlers drawing from the given projects, except already revealed ones.
+func New(config Config, projects eprojects.Projects) (*Handlers, error) {
+	revealed, err := loadRevealedSet(config)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := loadPendingSet(config)
+	if err != nil {
+		return nil, err
+	}
+	handlers := &Handlers{
+		config:       config,
+		projects:     projects,
+		revealed:     revealed,
+		projectsRoot: projects.MerkleRoot(),
+		pending:      pending,
+		deliveries:   make(chan func() error),
+	}
+	return handlers, nil
+}
+
+// random returns the configured source of random bytes.
+func (handlers *Handlers) random() erand.Source {
+	if handlers.config.Random == nil {
+		return erand.CryptoSource
+	}
+	return handlers.config.Random
 }
 
 func (handlers *Handlers) RandomCommit(tx *tlb.Transaction) error {
+	// The draw is requested by the transaction, its hash is the request ID.
+	id := hex.EncodeToString(tx.Hash)
+	logger := txLogger(tx, "random()").With(elog.RequestID(id))
+	if !handlers.acceptedByState(logger, (*econtract.EventState).WaitReveal) {
+		return nil
+	}
 	cfg := handlers.config
-	randIndex, err := erand.RandUInt64(uint64(handlers.projects.Len()))
+	// The draw is made without replacement: the weight table contains remaining projects only.
+	projects := handlers.projects.Exclude(handlers.revealed.ids)
+	count := max(cfg.Winners, 1)
+	if len(projects) < count {
+		// Every project weight is positive, so each remaining project is eligible.
+		logger.Warn("not enough projects left, the draw is skipped", "projects", len(projects), "winners", count)
+		return fmt.Errorf("%w: %d projects for %d winners", eprojects.ErrNotEnoughProjects, len(projects), count)
+	}
+	random, proof, err := handlers.vrfRandom(tx.Hash)
 	if err != nil {
 		return err
 	}
-	project := handlers.projects.GetByIndex(int(randIndex))
-	if project == nil {
-		return errors.New("can't get project by index")
+	indexes, err := erand.WeightedSampleFrom(random, projects.Weights(), count)
+	if err != nil {
+		return err
 	}
 
-	randNonce, err := erand.RandUInt64(math.MaxUint64)
+	randNonce, err := erand.FullUInt64From(handlers.random())
 	if err != nil {
 		return err
 	}
 
-	handlers.revealedValue = emessages.RevealedValue{
-		Timestamp: tx.Now,
-		Recipient: handlers.config.ContractAddress,
-		Nonce:     randNonce,
-		DoraID:    uint64(project.ID),
-		Name:      project.Name,
+	commit := pendingCommit{VRF: proof}
+	if count == 1 {
+		project := projects.GetByIndex(indexes[0])
+		if project == nil {
+			return errors.New("can't get project by index")
+		}
+		commit.Value = &emessages.RevealedValue{
+			Timestamp:    tx.Now,
+			Recipient:    cfg.ContractAddress,
+			Nonce:        randNonce,
+			DoraID:       uint64(project.ID),
+			Name:         project.Name,
+			WeightsHash:  projects.WeightsHash(),
+			ProjectsRoot: handlers.projectsRoot,
+			Index:        uint32(handlers.projects.IndexOf(project.ID)),
+		}
+	} else {
+		winners := make([]emessages.Winner, 0, count)
+		for _, index := range indexes {
+			project := projects.GetByIndex(index)
+			if project == nil {
+				return errors.New("can't get project by index")
+			}
+			winners = append(winners, emessages.Winner{
+				DoraID: uint64(project.ID),
+				Index:  uint32(handlers.projects.IndexOf(project.ID)),
+				Name:   project.Name,
+			})
+		}
+		commit.Winners = &emessages.RevealedWinners{
+			Timestamp:    tx.Now,
+			Recipient:    cfg.ContractAddress,
+			Nonce:        randNonce,
+			Winners:      winners,
+			WeightsHash:  projects.WeightsHash(),
+			ProjectsRoot: handlers.projectsRoot,
+		}
 	}
 
 	resp := emessages.RandomCommit{
-		Timestamp: handlers.revealedValue.Timestamp,
-		Recipient: handlers.revealedValue.Recipient,
-		ValueHash: handlers.revealedValue.Hash(),
+		Timestamp:    tx.Now,
+		Recipient:    cfg.ContractAddress,
+		ValueHash:    commit.valueHash(),
+		ProjectsRoot: handlers.projectsRoot,
 	}
-	responseCell, err := eresp.PackResponseToCell(cfg.Response, resp.ToCell(), resp.GetOpcode())
+	responseCell, logger, err := handlers.packResponse(logger, resp)
 	if err != nil {
 		return err
 	}
 
-	return sendResponse(context.Background(), cfg.SenderWallet, cfg.ContractAddress, responseCell)
+	// The commitment is saved before sending, the contract emits reveal() in the transaction accepting it.
+	if err := handlers.pending.add(id, commit); err != nil {
+		return err
+	}
+	return handlers.sendResponse(logger, "commit", responseCell, func(err error) error {
+		if notCommitted(err) {
+			return errors.Join(err, handlers.pending.remove(id))
+		}
+		// The draw may be committed even if the delivery outcome is unknown: it's revealed,
+		// or committed again by Recommit after the reveal timeout.
+		handlers.lastDraw = tx.Hash
+		if err != nil {
+			return err
+		}
+		return handlers.pending.confirm(id)
+	})
 }
 
 func (handlers *Handlers) RandomReveal(tx *tlb.Transaction) error {
 	if len(tx.Hash) != 32 {
 		return errors.New("unexpected transaction hash size")
 	}
-	cfg := handlers.config
+	logger := txLogger(tx, "reveal()")
+	id, commit, ok := handlers.committedDraw(tx)
+	if !ok {
+		// E.g. the commitment is expired, the contract will accept a new roll after the timeout.
+		logger.Info("no committed value, reveal is skipped")
+		return nil
+	}
+	logger = logger.With(elog.RequestID(id))
+	if !handlers.acceptedByState(logger, func(state *econtract.EventState, now uint32) error {
+		return state.Reveal(0, "", now)
+	}) {
+		return nil
+	}
+	if commit.Winners != nil {
+		return handlers.revealWinners(logger, tx, id, commit)
+	}
+	if commit.Value == nil {
+		logger.Info("commitment is not a draw, reveal is skipped")
+		return nil
+	}
+
+	committed := commit.Value
 	resp := emessages.RandomReveal{
-		//DoraID: uint64(project.ID),
-		//Name: project.Name,
-		DoraID:          handlers.revealedValue.DoraID,
-		Name:            handlers.revealedValue.Name,
+		DoraID:          committed.DoraID,
+		Name:            committed.Name,
 		RevealTimestamp: tx.Now,
-		Nonce:           handlers.revealedValue.Nonce,
+		Nonce:           committed.Nonce,
 		TxHash:          tx.Hash,
+		WeightsHash:     committed.WeightsHash,
+		Index:           committed.Index,
+		VRF:             commit.vrfProof(),
 	}
 
-	responseCell, err := eresp.PackResponseToCell(cfg.Response, resp.ToCell(), resp.GetOpcode())
+	responseCell, logger, err := handlers.packResponse(logger, resp)
 	if err != nil {
 		return err
 	}
-	return sendResponse(context.Background(), cfg.SenderWallet, cfg.ContractAddress, responseCell)
+	return handlers.sendResponse(logger, "reveal", responseCell, func(err error) error {
+		if err != nil {
+			return err
+		}
+		if err := handlers.revealedDraw(id); err != nil {
+			return err
+		}
+		return handlers.revealed.add(int(committed.DoraID))
+	})
 }
 
-func sendResponse(ctx context.Context, senderWallet *wallet.Wallet, address *address.Address, payload *cell.Cell) error {
-	msg := wallet.SimpleMessage(address, tlb.MustFromTON("0.025"), payload)
+// committedDraw returns the draw commitment accepted in the reveal() transaction.
+// The transaction handles the RandomCommit message, its value hash identifies the commitment.
+func (handlers *Handlers) committedDraw(tx *tlb.Transaction) (string, pendingCommit, bool) {
+	if tx.IO.In != nil && tx.IO.In.MsgType == tlb.MsgTypeInternal {
+		if commit, err := emessages.ParseRandomCommit(tx.IO.In.AsInternal().Body); err == nil {
+			return handlers.pending.find(commit.ValueHash)
+		}
+	}
+	// The commit message is unknown: the latest draw commitment is the one accepted by the contract.
+	return handlers.pending.latest(func(commit pendingCommit) bool { return commit.Random == nil })
+}
+
+// revealWinners reveals the committed draw of several distinct winners.
+func (handlers *Handlers) revealWinners(logger *slog.Logger, tx *tlb.Transaction, id string, commit pendingCommit) error {
+	committed := commit.Winners
+	resp := emessages.RandomRevealWinners{
+		Winners:         committed.Winners,
+		RevealTimestamp: tx.Now,
+		Nonce:           committed.Nonce,
+		TxHash:          tx.Hash,
+		WeightsHash:     committed.WeightsHash,
+		VRF:             commit.vrfProof(),
+	}
 
-	tx, _, err := senderWallet.SendWaitTransaction(ctx, msg)
+	responseCell, logger, err := handlers.packResponse(logger, resp)
 	if err != nil {
 		return err
 	}
-	_ = tx
+	return handlers.sendResponse(logger, "reveal-winners", responseCell, func(err error) error {
+		if err != nil {
+			return err
+		}
+		if err := handlers.revealedDraw(id); err != nil {
+			return err
+		}
+		ids := make([]int, 0, len(committed.Winners))
+		for _, winner := range committed.Winners {
+			ids = append(ids, int(winner.DoraID))
+		}
+		return handlers.revealed.add(ids...)
+	})
+}
+
+// revealedDraw removes the revealed draw commitment, it isn't committed again.
+func (handlers *Handlers) revealedDraw(id string) error {
+	if hex.EncodeToString(handlers.lastDraw) == id {
+		handlers.lastDraw = nil
+	}
+	return handlers.pending.remove(id)
+}
+
+// txLogger returns the logger tracing the command emitted in the contract transaction.
+func txLogger(tx *tlb.Transaction, command string) *slog.Logger {
+	return slog.With(elog.TxHash(tx.Hash), elog.LT(tx.LT), elog.Command(command))
+}
+
+// response is the enclave response message.
+type response interface {
+	ToCell() *cell.Cell
+	GetOpcode() uint32
+}
+
+// packResponse signs the response, the returned logger traces the response opcode.
+func (handlers *Handlers) packResponse(logger *slog.Logger, resp response) (*cell.Cell, *slog.Logger, error) {
+	responseCell, err := eresp.PackResponseToCell(handlers.config.Response, resp.ToCell(), resp.GetOpcode())
+	if err != nil {
+		return nil, logger, err
+	}
+	logger = logger.With(elog.Opcode(resp.GetOpcode()))
+	logger.Info("response signed")
+	return responseCell, logger, nil
+}
+
+// sendResponse sends the response, delivered applies the delivery result to the handlers state.
+// It's called at once with a synchronous sender. With an AsyncSender the command handler returns without waiting,
+// and delivered is called through Deliveries. The name labels the response metrics.
+// The delivery is logged with the logger tracing the command, failures are logged as warnings.
+func (handlers *Handlers) sendResponse(logger *slog.Logger, name string, payload *cell.Cell, delivered func(err error) error) error {
+	cfg := handlers.config
+	start := handlers.detected
+	if start.IsZero() {
+		start = time.Now()
+	}
+	record := func(err error) error {
+		if err != nil {
+			reason := failureReason(err)
+			logger.Warn("response is not delivered", "reason", reason, "error", err)
+			cfg.Metrics.SendFailed(reason)
+		} else {
+			logger.Info("response confirmed", "latency", time.Since(start))
+			cfg.Metrics.Delivered(name, start)
+		}
+		return delivered(err)
+	}
+	value := cfg.ResponseValue
+	if cfg.Fees != nil {
+		value = cfg.Fees.Value(payload)
+	}
+	msg := wallet.SimpleMessage(cfg.ContractAddress, value, payload)
+	logger.Info("response sent", "value", value.String())
+
+	async, ok := cfg.Sender.(AsyncSender)
+	if !ok {
+		return record(cfg.Sender.Send(context.Background(), msg))
+	}
+	result := async.Submit(context.Background(), msg)
+	go func() {
+		err := <-result
+		handlers.deliveries <- func() error { return record(err) }
+	}()
 	return nil
 }
+
+// Detected sets when the commands of the transaction handled next were detected, e.g. when the watch loop
+// parsed it. The latency of their responses is measured from it, or from sending if it's not set.
+func (handlers *Handlers) Detected(at time.Time) {
+	handlers.detected = at
+}
+
+// Deliveries returns the results of the responses sent with an AsyncSender.
+// Each result updates the handlers state when it's called, so it must be called on the goroutine
+// calling the command handlers, e.g. the watch loop. The error is the one the command handler returns
+// with a synchronous sender.
+func (handlers *Handlers) Deliveries() <-chan func() error {
+	return handlers.deliveries
+}
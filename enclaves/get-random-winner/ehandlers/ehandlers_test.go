@@ -1 +1,547 @@
 package ehandlers
+
+import (
+	"bytes"
+	"context"
+	"enclave/ebalance"
+	"enclave/econtract"
+	"enclave/emessages"
+	"enclave/emetrics"
+	"enclave/eprojects"
+	"enclave/erand"
+	"enclave/tonnet"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/eresp"
+	"github.com/tonteeton/golib/esign"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testContract = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+
+func testConfig(t *testing.T, sender Sender) Config {
+	dir := t.TempDir()
+	return Config{
+		Sender:          sender,
+		ContractAddress: testContract,
+		ResponseValue:   tlb.MustFromTON("0.025"),
+		Response: eresp.Config{
+			SignatureKeys: econf.KeysConfig{
+				PublicKeyPath:  filepath.Join(dir, "public.key"),
+				PrivateKeyPath: filepath.Join(dir, "private.key"),
+				SealedDatePath: filepath.Join(dir, "date.enc"),
+				Version:        "test",
+			},
+		},
+	}
+}
+
+func testProjects() eprojects.Projects {
+	return eprojects.Projects{{ID: 1, Name: "First"}, {ID: 2, Name: "Second"}}
+}
+
+func newTestHandlers(t *testing.T, config Config) *Handlers {
+	handlers, err := New(config, testProjects())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers
+}
+
+// responseOpcode returns the opcode of the enclave response in the message body.
+func responseOpcode(t *testing.T, body *cell.Cell) uint32 {
+	opcode, err := body.BeginParse().LoadUInt(32)
+	if err != nil {
+		t.Fatalf("Unexpected response: %v", err)
+	}
+	return uint32(opcode)
+}
+
+func TestHandlers(t *testing.T) {
+	t.Run("Commit and reveal are sent", func(t *testing.T) {
+		sender := &RecordingSender{}
+		handlers := newTestHandlers(t, testConfig(t, sender))
+
+		if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := handlers.RandomReveal(&tlb.Transaction{Now: 200, Hash: make([]byte, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		messages := sender.Messages()
+		if len(messages) != 2 {
+			t.Fatalf("Unexpected messages count: %d", len(messages))
+		}
+		expected := []uint32{0xbb15fe7d, 0x6b91a49a}
+		for i, msg := range messages {
+			if msg.InternalMessage.DstAddr.String() != testContract.String() {
+				t.Errorf("Unexpected destination: %v", msg.InternalMessage.DstAddr)
+			}
+			if opcode := responseOpcode(t, msg.InternalMessage.Body); opcode != expected[i] {
+				t.Errorf("Unexpected opcode: %x, expected %x", opcode, expected[i])
+			}
+		}
+	})
+
+	t.Run("Dry run saves messages", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "dry-run")
+		handlers := newTestHandlers(t, testConfig(t, &DryRunSender{Dir: dir}))
+
+		if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.boc"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("Unexpected files: %v, %v", files, err)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgCell, err := cell.FromBOC(data)
+		if err != nil {
+			t.Fatalf("Unexpected BOC: %v", err)
+		}
+		var msg tlb.InternalMessage
+		if err := tlb.LoadFromCell(&msg, msgCell.BeginParse()); err != nil {
+			t.Fatalf("Unexpected message: %v", err)
+		}
+		if opcode := responseOpcode(t, msg.Body); opcode != 0xbb15fe7d {
+			t.Errorf("Unexpected opcode: %x", opcode)
+		}
+	})
+}
+
+func noSeal(data []byte, additionalData []byte) ([]byte, error) {
+	return data, nil
+}
+
+// revealedID returns DoraID of the sent RandomReveal message.
+func revealedID(t *testing.T, msg *wallet.Message) uint64 {
+	body := msg.InternalMessage.Body.BeginParse()
+	body.MustLoadUInt(32)
+	body.MustLoadRef()
+	return body.MustLoadUInt(64)
+}
+
+func TestDrawWithoutReplacement(t *testing.T) {
+	sender := &RecordingSender{}
+	config := testConfig(t, sender)
+	config.RevealedPath = filepath.Join(t.TempDir(), "revealed.enc")
+	config.Sealer, config.Unsealer = noSeal, noSeal
+
+	// Every project is drawn once, the revealed set survives the restart.
+	drawn := map[uint64]bool{}
+	for i := 0; i < len(testProjects()); i++ {
+		handlers := newTestHandlers(t, config)
+		if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{byte(i + 1)}, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := handlers.RandomReveal(&tlb.Transaction{Now: 200, Hash: make([]byte, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		messages := sender.Messages()
+		id := revealedID(t, messages[len(messages)-1])
+		if drawn[id] {
+			t.Fatalf("Project %d is drawn twice", id)
+		}
+		drawn[id] = true
+	}
+
+	handlers := newTestHandlers(t, config)
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 300, Hash: bytes.Repeat([]byte{3}, 32)}); !errors.Is(err, eprojects.ErrNotEnoughProjects) {
+		t.Errorf("Unexpected error when all projects are drawn: %v", err)
+	}
+	if len(handlers.pending.commits) != 0 {
+		t.Errorf("Skipped draw is committed: %v", handlers.pending.commits)
+	}
+}
+
+func TestDrawWinners(t *testing.T) {
+	sender := &RecordingSender{}
+	config := testConfig(t, sender)
+	config.Winners = 2
+	handlers := newTestHandlers(t, config)
+
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := handlers.RandomReveal(&tlb.Transaction{Now: 200, Hash: make([]byte, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Unexpected messages count: %d", len(messages))
+	}
+	reveal := messages[1].InternalMessage.Body.BeginParse()
+	if opcode := reveal.MustLoadUInt(32); opcode != uint64(emessages.RandomRevealWinners{}.GetOpcode()) {
+		t.Fatalf("Unexpected opcode: %x", opcode)
+	}
+	reveal.MustLoadRef()
+	// Winners list: DoraID, index, name reference, next winner reference.
+	ids := map[uint64]bool{}
+	for node := reveal.MustLoadRef(); ; {
+		id := node.MustLoadUInt(64)
+		if index := node.MustLoadUInt(32); testProjects()[index].ID != int(id) {
+			t.Errorf("Unexpected index %d of winner %d", index, id)
+		}
+		ids[id] = true
+		node.MustLoadRef()
+		if node.RefsNum() == 0 {
+			break
+		}
+		node = node.MustLoadRef()
+	}
+	if len(ids) != 2 || !ids[1] || !ids[2] {
+		t.Errorf("Unexpected winners: %v", ids)
+	}
+}
+
+func TestVRFReveal(t *testing.T) {
+	sender := &RecordingSender{}
+	config := testConfig(t, sender)
+	handlers := newTestHandlers(t, config)
+
+	requestHash := bytes.Repeat([]byte{0x42}, 32)
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: requestHash}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100}); err == nil {
+		t.Errorf("Expected error not raised for the request without transaction hash")
+	}
+	if err := handlers.RandomReveal(&tlb.Transaction{Now: 200, Hash: make([]byte, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// RandomReveal: DoraID, name, timestamp, nonce, tx hash, weights hash, index and the VRF proof.
+	messages := sender.Messages()
+	reveal := messages[len(messages)-1].InternalMessage.Body.BeginParse()
+	reveal.MustLoadUInt(32)
+	reveal.MustLoadRef()
+	id := reveal.MustLoadUInt(64)
+	reveal.MustLoadRef()
+	reveal.MustLoadUInt(32 + 64)
+	reveal.MustLoadRef()
+	reveal.MustLoadSlice(256 + 32)
+	proof, err := emessages.ParseVRFProof(reveal.MustLoadRef().MustToCell())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(proof.Alpha, requestHash) {
+		t.Errorf("Unexpected VRF input: %x", proof.Alpha)
+	}
+
+	// The draw is reproduced from the verified VRF output.
+	key, err := esign.GetSignatureKey(config.Response.SignatureKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := erand.VRFVerify(key.GetPublicKey(), proof.Alpha, proof.Proof)
+	if err != nil {
+		t.Fatalf("Invalid VRF proof: %v", err)
+	}
+	indexes, err := erand.WeightedSampleFrom(erand.NewVRFStream(output), testProjects().Weights(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := testProjects()[indexes[0]].ID; int(id) != expected {
+		t.Errorf("Revealed project %d, expected %d by the VRF output", id, expected)
+	}
+}
+
+func TestReproducibleCommit(t *testing.T) {
+	config := testConfig(t, nil)
+	commit := func(seed uint64) *cell.Cell {
+		sender := &RecordingSender{}
+		config.Sender = sender
+		config.Random = erand.NewSeededSource(seed)
+		handlers := newTestHandlers(t, config)
+		if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return sender.Messages()[0].InternalMessage.Body
+	}
+
+	if first, second := commit(7), commit(7); !bytes.Equal(first.Hash(), second.Hash()) {
+		t.Error("Commitments with the same seed differ")
+	}
+	if first, other := commit(7), commit(8); bytes.Equal(first.Hash(), other.Hash()) {
+		t.Error("Commitments with different seeds are equal")
+	}
+}
+
+// fakeState is the event state returned by the contract getter.
+type fakeState struct {
+	state econtract.EventState
+}
+
+func (f *fakeState) EventState(ctx context.Context) (econtract.EventState, error) {
+	return f.state, nil
+}
+
+func TestEventStateChecks(t *testing.T) {
+	sender := &RecordingSender{}
+	config := testConfig(t, sender)
+	state := &fakeState{}
+	config.State = state
+	handlers := newTestHandlers(t, config)
+	now := time.Unix(10000, 0)
+	handlers.pending.now = func() time.Time { return now }
+	setState := func(value uint8, updated int64) {
+		state.state = econtract.EventState{State: value, Updated: uint32(updated), CompletionTime: 20000}
+	}
+	requestHash := bytes.Repeat([]byte{1}, 32)
+
+	setState(econtract.StateRock, now.Unix())
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: requestHash}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sender.Messages()) != 0 {
+		t.Fatal("Commit is sent without roll")
+	}
+
+	setState(econtract.StateRoll, now.Unix())
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: requestHash}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := handlers.RandomReveal(&tlb.Transaction{Now: 200, Hash: make([]byte, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sender.Messages()) != 1 {
+		t.Fatal("Reveal is sent before the commit is accepted")
+	}
+
+	// The commit is lost: it is sent again after the timeout only.
+	if err := handlers.Recommit(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sender.Messages()) != 1 {
+		t.Fatal("Commit is sent again before the timeout")
+	}
+	now = now.Add((econtract.WaitRevealMaxPeriod + 1) * time.Second)
+	if err := handlers.Recommit(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	messages := sender.Messages()
+	if len(messages) != 2 || responseOpcode(t, messages[1].InternalMessage.Body) != (emessages.RandomCommit{}).GetOpcode() {
+		t.Fatal("Commit is not sent again after the timeout")
+	}
+	// The contract doesn't replace the commit waiting for the reveal.
+	setState(econtract.StateWaitReveal, now.Add(-(econtract.WaitRevealMaxPeriod+1)*time.Second).Unix())
+	if err := handlers.Recommit(context.Background()); err != nil || len(sender.Messages()) != 2 {
+		t.Fatalf("Commit waiting for the reveal is committed again: %v", err)
+	}
+
+	setState(econtract.StateWaitReveal, now.Unix())
+	if err := handlers.RandomReveal(&tlb.Transaction{Now: 300, Hash: make([]byte, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if messages := sender.Messages(); len(messages) != 3 || responseOpcode(t, messages[2].InternalMessage.Body) != (emessages.RandomReveal{}).GetOpcode() {
+		t.Fatal("Reveal is not sent")
+	}
+	// The revealed draw isn't committed again.
+	now = now.Add((econtract.WaitRevealMaxPeriod + 1) * time.Second)
+	if err := handlers.Recommit(context.Background()); err != nil || len(sender.Messages()) != 3 {
+		t.Fatalf("Revealed draw is committed again: %v", err)
+	}
+}
+
+func TestImportedProjects(t *testing.T) {
+	config := testConfig(t, &RecordingSender{})
+	config.ProjectsPath = filepath.Join(t.TempDir(), "projects.enc")
+	config.Sealer, config.Unsealer = noSeal, noSeal
+
+	imported := eprojects.Projects{{ID: 7, Name: "Imported"}}
+	if err := imported.Seal(config.ProjectsPath, []byte(config.SealVersion), noSeal); err != nil {
+		t.Fatal(err)
+	}
+	projects, err := loadProjects(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(projects) != 1 || projects[0].ID != 7 {
+		t.Errorf("Imported projects are not used: %+v", projects)
+	}
+}
+
+// commitTx returns the transaction of the enclave RandomCommit message, as handled by the contract.
+func commitTx(msg *wallet.Message, now uint32, hash byte) *tlb.Transaction {
+	tx := &tlb.Transaction{Now: now, Hash: bytes.Repeat([]byte{hash}, 32)}
+	tx.IO.In = &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: msg.InternalMessage}
+	return tx
+}
+
+func TestPendingCommits(t *testing.T) {
+	sender := &RecordingSender{}
+	config := testConfig(t, sender)
+	config.PendingPath = filepath.Join(t.TempDir(), "pending.enc")
+	config.RevealTimeout = time.Minute
+	config.Sealer, config.Unsealer = noSeal, noSeal
+
+	// Two random() requests are committed before the reveal.
+	handlers := newTestHandlers(t, config)
+	for i := byte(1); i <= 2; i++ {
+		if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{i}, 32)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	commits := sender.Messages()
+
+	// The first commitment is revealed after the restart.
+	handlers = newTestHandlers(t, config)
+	if len(handlers.pending.commits) != 2 {
+		t.Fatalf("Unexpected pending commits: %v", handlers.pending.commits)
+	}
+	if err := handlers.RandomReveal(commitTx(commits[0], 200, 0xaa)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	messages := sender.Messages()
+	if len(messages) != 3 {
+		t.Fatalf("Unexpected messages count: %d", len(messages))
+	}
+	first, err := emessages.ParseRandomCommit(commits[0].InternalMessage.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reveal := messages[2].InternalMessage.Body.BeginParse()
+	reveal.MustLoadUInt(32)
+	reveal.MustLoadRef()
+	revealed := emessages.RevealedValue{
+		Timestamp:    100,
+		Recipient:    testContract,
+		DoraID:       reveal.MustLoadUInt(64),
+		Name:         reveal.MustLoadRef().MustLoadStringSnake(),
+		ProjectsRoot: first.ProjectsRoot,
+	}
+	reveal.MustLoadUInt(32)
+	revealed.Nonce = reveal.MustLoadUInt(64)
+	reveal.MustLoadRef()
+	revealed.WeightsHash = reveal.MustLoadSlice(256)
+	revealed.Index = uint32(reveal.MustLoadUInt(32))
+	if !bytes.Equal(revealed.Hash(), first.ValueHash) {
+		t.Errorf("Revealed value %+v does not match the first commitment", revealed)
+	}
+	if len(handlers.pending.commits) != 1 {
+		t.Errorf("Revealed commitment is not removed: %v", handlers.pending.commits)
+	}
+
+	t.Run("Expired commitments are not revealed", func(t *testing.T) {
+		handlers.pending.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		if err := handlers.RandomReveal(commitTx(commits[1], 300, 0xbb)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if count := len(sender.Messages()); count != 3 {
+			t.Errorf("Unexpected messages count: %d", count)
+		}
+	})
+
+	t.Run("Random value requests don't expire", func(t *testing.T) {
+		handlers := newTestHandlers(t, config)
+		handlers.pending.now = time.Now
+		request := emessages.RandomRequested{Requester: testContract, QueryID: 42, Range: big.NewInt(100)}
+		if err := handlers.RandomRequestCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{3}, 32)}, request); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		key := emessages.RequestID(testContract, 42)
+		if commit, ok := handlers.pending.get(key); !ok || commit.Committed.IsZero() {
+			t.Fatalf("Delivered commitment is not confirmed: %+v", commit)
+		}
+		handlers.pending.now = func() time.Time { return time.Now().Add(time.Hour) }
+		sent := len(sender.Messages())
+		committed := emessages.RandomCommitted{Requester: testContract, QueryID: 42}
+		if err := handlers.RandomRequestReveal(&tlb.Transaction{Now: 4000}, committed); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if count := len(sender.Messages()); count != sent+1 {
+			t.Errorf("Request is not revealed after the reveal timeout: %d messages", count)
+		}
+	})
+}
+
+// failingSender fails every response with the error.
+type failingSender struct {
+	err error
+}
+
+func (sender failingSender) Send(ctx context.Context, msg *wallet.Message) error {
+	return sender.err
+}
+
+func TestUndeliveredCommits(t *testing.T) {
+	request := emessages.RandomRequested{Requester: testContract, QueryID: 42, Range: big.NewInt(100)}
+	key := emessages.RequestID(testContract, 42)
+	drawTx := &tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}
+	requestTx := &tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{2}, 32)}
+
+	tests := []struct {
+		name string
+		err  error
+		kept bool
+	}{
+		{"Rejected", fmt.Errorf("%w: exit code 100", econtract.ErrRejected), false},
+		{"Critical balance", fmt.Errorf("%w: 0.1 TON", ebalance.ErrCriticalBalance), false},
+		{"Not confirmed", tonnet.ErrNotConfirmed, true},
+		{"Trace timeout", context.DeadlineExceeded, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := newTestHandlers(t, testConfig(t, failingSender{tt.err}))
+			if err := handlers.RandomCommit(drawTx); !errors.Is(err, tt.err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if err := handlers.RandomRequestCommit(requestTx, request); !errors.Is(err, tt.err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if _, _, ok := handlers.pending.latest(func(commit pendingCommit) bool { return commit.Random == nil }); ok != tt.kept {
+				t.Errorf("Draw commitment kept: %v, expected %v", ok, tt.kept)
+			}
+			if _, ok := handlers.pending.get(key); ok != tt.kept {
+				t.Errorf("Request commitment kept: %v, expected %v", ok, tt.kept)
+			}
+			// A kept draw is committed again after the reveal timeout.
+			if kept := handlers.lastDraw != nil; kept != tt.kept {
+				t.Errorf("Draw kept for the recommit: %v, expected %v", kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestResponseLatency(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	config := testConfig(t, &RecordingSender{})
+	config.Metrics = emetrics.NewWatch(registry)
+	handlers := newTestHandlers(t, config)
+
+	// The latency is measured from the command detection, not from sending the response.
+	handlers.Detected(time.Now().Add(-time.Minute))
+	if err := handlers.RandomCommit(&tlb.Transaction{Now: 100, Hash: bytes.Repeat([]byte{1}, 32)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "enclave_response_latency_seconds" {
+			continue
+		}
+		if histogram := family.GetMetric()[0].GetHistogram(); histogram.GetSampleCount() != 1 || histogram.GetSampleSum() < 60 {
+			t.Errorf("Unexpected latency: %v", histogram)
+		}
+		return
+	}
+	t.Error("Latency is not recorded")
+}
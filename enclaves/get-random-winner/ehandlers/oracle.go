@@ -0,0 +1,96 @@
+package ehandlers
+
+import (
+	"enclave/elog"
+	"enclave/emessages"
+	"enclave/erand"
+	"errors"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// RandomRequestCommit commits to a random value for the request made in the transaction.
+func (handlers *Handlers) RandomRequestCommit(tx *tlb.Transaction, request emessages.RandomRequested) error {
+	// The query ID identifies the request.
+	key := emessages.RequestID(request.Requester, request.QueryID)
+	logger := txLogger(tx, "RandomRequested").With(elog.RequestID(key))
+	random, proof, err := handlers.vrfRandom(tx.Hash)
+	if err != nil {
+		return err
+	}
+	value, err := erand.BigIntFrom(random, request.Range)
+	if err != nil {
+		return err
+	}
+	randNonce, err := erand.FullUInt64From(handlers.random())
+	if err != nil {
+		return err
+	}
+
+	cfg := handlers.config
+	revealed := emessages.RevealedRandom{
+		Timestamp: tx.Now,
+		Recipient: cfg.ContractAddress,
+		Requester: request.Requester,
+		QueryID:   request.QueryID,
+		Value:     value,
+		Nonce:     randNonce,
+		TxHash:    tx.Hash,
+	}
+	resp := emessages.RandomRequestCommit{
+		Timestamp: tx.Now,
+		Recipient: cfg.ContractAddress,
+		Requester: request.Requester,
+		QueryID:   request.QueryID,
+		ValueHash: revealed.Hash(),
+	}
+	responseCell, logger, err := handlers.packResponse(logger, resp)
+	if err != nil {
+		return err
+	}
+	// The commitment is saved before sending, the contract emits the commit event in the transaction accepting it.
+	if err := handlers.pending.add(key, pendingCommit{Random: &revealed, VRF: proof}); err != nil {
+		return err
+	}
+	return handlers.sendResponse(logger, "request-commit", responseCell, func(err error) error {
+		// The contract doesn't accept a second commit of the request, so the commitment
+		// is removed only if it's certainly not accepted.
+		if notCommitted(err) {
+			return errors.Join(err, handlers.pending.remove(key))
+		}
+		if err != nil {
+			return err
+		}
+		return handlers.pending.confirm(key)
+	})
+}
+
+// RandomRequestReveal reveals the value committed for the request.
+func (handlers *Handlers) RandomRequestReveal(tx *tlb.Transaction, committed emessages.RandomCommitted) error {
+	key := emessages.RequestID(committed.Requester, committed.QueryID)
+	logger := txLogger(tx, "RandomCommitted").With(elog.RequestID(key))
+	commit, ok := handlers.pending.get(key)
+	if !ok || commit.Random == nil {
+		logger.Info("no committed value, reveal is skipped")
+		return nil
+	}
+
+	revealed := commit.Random
+	resp := emessages.RandomRequestReveal{
+		Requester: revealed.Requester,
+		QueryID:   revealed.QueryID,
+		Value:     revealed.Value,
+		Nonce:     revealed.Nonce,
+		TxHash:    revealed.TxHash,
+		VRF:       commit.vrfProof(),
+	}
+	responseCell, logger, err := handlers.packResponse(logger, resp)
+	if err != nil {
+		return err
+	}
+	return handlers.sendResponse(logger, "request-reveal", responseCell, func(err error) error {
+		if err != nil {
+			return err
+		}
+		return handlers.pending.remove(key)
+	})
+}
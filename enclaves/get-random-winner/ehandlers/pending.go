@@ -0,0 +1,167 @@
+package ehandlers
+
+import (
+	"bytes"
+	"enclave/ebalance"
+	"enclave/econtract"
+	"enclave/emessages"
+	"encoding/json"
+	"errors"
+	"github.com/tonteeton/golib/ekeys"
+	"os"
+	"time"
+)
+
+// pendingCommit is a committed value waiting for the reveal, exactly one of the values is set.
+type pendingCommit struct {
+	// Sent is when the commitment is sent, Committed is when the contract accepts it, zero until then.
+	Sent      time.Time                  `json:"sent"`
+	Committed time.Time                  `json:"committed"`
+	Value     *emessages.RevealedValue   `json:"value,omitempty"`
+	Winners   *emessages.RevealedWinners `json:"winners,omitempty"`
+	Random    *emessages.RevealedRandom  `json:"random,omitempty"`
+	// VRF is the proof of the random value, published with the reveal.
+	VRF *emessages.VRFProof `json:"vrf,omitempty"`
+}
+
+// valueHash returns the committed hash of the value.
+func (commit pendingCommit) valueHash() []byte {
+	switch {
+	case commit.Value != nil:
+		return commit.Value.Hash()
+	case commit.Winners != nil:
+		return commit.Winners.Hash()
+	case commit.Random != nil:
+		return commit.Random.Hash()
+	}
+	return nil
+}
+
+// pendingSet is the set of pending commitments by request ID: the request transaction hash or the query ID.
+// Draw commitments expire after the reveal timeout, when the contract doesn't accept the reveal anymore.
+// Random value requests have no timeout in the contract, their commitments are kept until revealed.
+// It is sealed to the file, or kept in memory only if the path is empty.
+type pendingSet struct {
+	config  Config
+	commits map[string]pendingCommit
+	now     func() time.Time
+}
+
+// loadPendingSet loads the sealed set, it is empty if the file doesn't exist.
+func loadPendingSet(config Config) (*pendingSet, error) {
+	set := &pendingSet{config: config, commits: map[string]pendingCommit{}, now: time.Now}
+	if config.PendingPath == "" {
+		return set, nil
+	}
+
+	data, err := ekeys.ReadEncryptedFile(config.PendingPath, []byte(config.SealVersion), sealers(config.Unsealer)...)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return set, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &set.commits); err != nil {
+		return nil, err
+	}
+	set.expire()
+	return set, nil
+}
+
+// add adds the commitment of the request before it's sent and seals the set.
+func (set *pendingSet) add(id string, commit pendingCommit) error {
+	commit.Sent = set.now()
+	commit.Committed = time.Time{}
+	set.expire()
+	set.commits[id] = commit
+	return set.save()
+}
+
+// get returns the pending commitment of the request.
+func (set *pendingSet) get(id string) (pendingCommit, bool) {
+	set.expire()
+	commit, ok := set.commits[id]
+	return commit, ok
+}
+
+// find returns the pending commitment with the value hash.
+func (set *pendingSet) find(valueHash []byte) (string, pendingCommit, bool) {
+	set.expire()
+	for id, commit := range set.commits {
+		if bytes.Equal(commit.valueHash(), valueHash) {
+			return id, commit, true
+		}
+	}
+	return "", pendingCommit{}, false
+}
+
+// latest returns the latest pending commitment matching the filter.
+func (set *pendingSet) latest(match func(pendingCommit) bool) (string, pendingCommit, bool) {
+	set.expire()
+	var latestID string
+	var latest pendingCommit
+	found := false
+	for id, commit := range set.commits {
+		if match(commit) && (!found || commit.since().After(latest.since())) {
+			latestID, latest, found = id, commit, true
+		}
+	}
+	return latestID, latest, found
+}
+
+// confirm records the commitment accepted by the contract and seals the set.
+func (set *pendingSet) confirm(id string) error {
+	commit, ok := set.commits[id]
+	if !ok {
+		return nil
+	}
+	commit.Committed = set.now()
+	set.commits[id] = commit
+	return set.save()
+}
+
+// remove removes the revealed commitment and seals the set.
+func (set *pendingSet) remove(id string) error {
+	delete(set.commits, id)
+	return set.save()
+}
+
+// notCommitted reports whether the delivery error means the contract didn't accept the commitment:
+// it rejected the response, or the response wasn't sent below the critical wallet balance.
+// The outcome of other errors is unknown, e.g. the trace can time out after the contract accepted it,
+// so the commitment is kept for the reveal.
+func notCommitted(err error) bool {
+	return errors.Is(err, econtract.ErrRejected) || errors.Is(err, ebalance.ErrCriticalBalance)
+}
+
+// since returns when the contract accepted the commitment, or when it was sent if it's not confirmed.
+func (commit pendingCommit) since() time.Time {
+	if commit.Committed.IsZero() {
+		return commit.Sent
+	}
+	return commit.Committed
+}
+
+// expire removes draw commitments older than the reveal timeout, if it is set.
+// Commitments of random value requests don't expire.
+func (set *pendingSet) expire() {
+	if set.config.RevealTimeout <= 0 {
+		return
+	}
+	for id, commit := range set.commits {
+		if commit.Random == nil && set.now().Sub(commit.since()) > set.config.RevealTimeout {
+			delete(set.commits, id)
+		}
+	}
+}
+
+func (set *pendingSet) save() error {
+	if set.config.PendingPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(set.commits)
+	if err != nil {
+		return err
+	}
+	return ekeys.WriteEncryptedFile(set.config.PendingPath, data, []byte(set.config.SealVersion), sealers(set.config.Sealer)...)
+}
@@ -0,0 +1,69 @@
+package ehandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tonteeton/golib/ekeys"
+	"os"
+	"slices"
+)
+
+// revealedSet is the set of already revealed project IDs, excluded from later draws.
+// It is sealed to the file, or kept in memory only if the path is empty.
+type revealedSet struct {
+	config Config
+	ids    map[int]bool
+}
+
+// loadRevealedSet loads the sealed set, it is empty if the file doesn't exist.
+func loadRevealedSet(config Config) (*revealedSet, error) {
+	set := &revealedSet{config: config, ids: map[int]bool{}}
+	if config.RevealedPath == "" {
+		return set, nil
+	}
+
+	data, err := ekeys.ReadEncryptedFile(config.RevealedPath, []byte(config.SealVersion), sealers(config.Unsealer)...)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return set, nil
+		}
+		return nil, err
+	}
+	var ids []int
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		set.ids[id] = true
+	}
+	return set, nil
+}
+
+// add adds the IDs to the set and seals it.
+func (set *revealedSet) add(ids ...int) error {
+	for _, id := range ids {
+		set.ids[id] = true
+	}
+	if set.config.RevealedPath == "" {
+		return nil
+	}
+
+	list := make([]int, 0, len(set.ids))
+	for id := range set.ids {
+		list = append(list, id)
+	}
+	slices.Sort(list)
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return ekeys.WriteEncryptedFile(set.config.RevealedPath, data, []byte(set.config.SealVersion), sealers(set.config.Sealer)...)
+}
+
+// sealers returns the sealer to use, or none to use the ekeys default.
+func sealers(sealer ekeys.DataSealer) []ekeys.DataSealer {
+	if sealer == nil {
+		return nil
+	}
+	return []ekeys.DataSealer{sealer}
+}
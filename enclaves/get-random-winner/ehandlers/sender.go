@@ -0,0 +1,146 @@
+package ehandlers
+
+import (
+	"context"
+	"enclave/ebalance"
+	"enclave/econtract"
+	"enclave/efees"
+	"enclave/tonnet"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sender delivers enclave responses to the contract.
+type Sender interface {
+	Send(ctx context.Context, msg *wallet.Message) error
+}
+
+// AsyncSender queues responses without waiting for the delivery, its result is received from the returned channel.
+type AsyncSender interface {
+	Submit(ctx context.Context, msg *wallet.Message) <-chan error
+}
+
+// QueueSender sends messages through the wallet send queue and checks the contract transactions processing them.
+// The contract rejection is returned as an error wrapping econtract.ErrRejected.
+// Submit returns at once, Send waits for the delivery.
+type QueueSender struct {
+	Queue *tonnet.SendQueue
+	// Fees records the cost of the contract transactions to estimate the value of the next responses, if set.
+	Fees *efees.Estimator
+	// Balance checks the wallet and contract balances before sending, if set.
+	// Responses are not sent below the critical wallet balance, the error wraps ebalance.ErrCriticalBalance.
+	Balance *ebalance.Monitor
+}
+
+func (sender QueueSender) Send(ctx context.Context, msg *wallet.Message) error {
+	select {
+	case err := <-sender.Submit(ctx, msg):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (sender QueueSender) Submit(ctx context.Context, msg *wallet.Message) <-chan error {
+	result := make(chan error, 1)
+	if sender.Balance != nil {
+		if _, err := sender.Balance.Check(ctx); err != nil {
+			result <- err
+			return result
+		}
+	}
+	sent := sender.Queue.Submit(msg)
+	go func() {
+		r := <-sent
+		result <- checkDelivery(sender.Fees, sender.Queue.Wallet().Address(), msg, r.WalletTx, r.Tx, r.Err)
+	}()
+	return result
+}
+
+// checkDelivery records the fees of the sent message and returns the contract rejection, if any.
+func checkDelivery(fees *efees.Estimator, from *address.Address, msg *wallet.Message, walletTx, tx *tlb.Transaction, err error) error {
+	if walletTx != nil && fees != nil {
+		fees.ObserveSender(walletTx)
+	}
+	if err != nil {
+		return err
+	}
+	err = econtract.CheckTransaction(tx, from)
+	if fees != nil {
+		internal := msg.InternalMessage
+		fees.Observe(internal.Body, internal.Amount, tx, from, err)
+	}
+	return err
+}
+
+// DryRunSender logs messages and saves them as BOC files to the directory instead of sending.
+type DryRunSender struct {
+	Dir string
+
+	mu    sync.Mutex
+	count int
+}
+
+func (sender *DryRunSender) Send(ctx context.Context, msg *wallet.Message) error {
+	msgCell, err := tlb.ToCell(msg.InternalMessage)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sender.Dir, 0700); err != nil {
+		return err
+	}
+
+	sender.mu.Lock()
+	sender.count++
+	name := fmt.Sprintf("response-%03d-%x.boc", sender.count, msgCell.Hash()[:8])
+	sender.mu.Unlock()
+
+	path := filepath.Join(sender.Dir, name)
+	if err := os.WriteFile(path, msgCell.ToBOC(), 0600); err != nil {
+		return err
+	}
+	slog.Info("dry run: message is saved", "to", msg.InternalMessage.DstAddr.String(), "value", msg.InternalMessage.Amount.String(), "path", path)
+	return nil
+}
+
+// RecordingSender keeps messages in memory instead of sending, e.g. for tests.
+type RecordingSender struct {
+	mu       sync.Mutex
+	messages []*wallet.Message
+}
+
+func (sender *RecordingSender) Send(ctx context.Context, msg *wallet.Message) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	sender.messages = append(sender.messages, msg)
+	return nil
+}
+
+// Messages returns the recorded messages.
+func (sender *RecordingSender) Messages() []*wallet.Message {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	return append([]*wallet.Message(nil), sender.messages...)
+}
+
+// failureReason labels the send failure metric.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, econtract.ErrRejected):
+		return "rejected"
+	case errors.Is(err, ebalance.ErrCriticalBalance):
+		return "low_balance"
+	case errors.Is(err, tonnet.ErrNotConfirmed):
+		return "not_confirmed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "error"
+}
@@ -0,0 +1,137 @@
+package ehandlers
+
+import (
+	"context"
+	"enclave/econtract"
+	"enclave/tonnet"
+	"enclave/tonnet/tonnettest"
+	"errors"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"testing"
+	"time"
+)
+
+// delayedChain processes the external messages after the delay, as a congested network does.
+type delayedChain struct {
+	*tonnettest.FakeChain
+	delay time.Duration
+}
+
+func (c delayedChain) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	time.AfterFunc(c.delay, func() {
+		c.FakeChain.SendExternalMessage(context.Background(), msg)
+	})
+	return nil
+}
+
+func TestQueueSender(t *testing.T) {
+	newQueue := func(t *testing.T, chain tonnet.Chain, resubmits int) *tonnet.SendQueue {
+		senderWallet, err := tonnet.NewWallet(chain, wallet.NewSeed(), wallet.V3R2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		senderWallet.SetMessageTTL(time.Second)
+		return tonnet.NewSendQueue(senderWallet, tonnet.QueueConfig{ConfirmTimeout: 100 * time.Millisecond, Resubmits: resubmits})
+	}
+	message := func(i uint64) *wallet.Message {
+		return wallet.SimpleMessage(testContract, tlb.MustFromTON("0.025"), cell.BeginCell().MustStoreUInt(i, 32).EndCell())
+	}
+	run := func(t *testing.T, queue *tonnet.SendQueue) context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		t.Cleanup(cancel)
+		go queue.Run(ctx)
+		return ctx
+	}
+
+	t.Run("Batches", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		queue := newQueue(t, chain, 0)
+		sender := QueueSender{Queue: queue}
+		// Messages queued before the start are sent in batches of the wallet limit.
+		var results []<-chan error
+		for i := uint64(0); i < 6; i++ {
+			results = append(results, sender.Submit(context.Background(), message(i)))
+		}
+		ctx := run(t, queue)
+		for _, result := range results {
+			select {
+			case err := <-result:
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			case <-ctx.Done():
+				t.Fatal(ctx.Err())
+			}
+		}
+		if sent := len(chain.SentMessages()); sent != 2 {
+			t.Errorf("Unexpected external messages count: %d", sent)
+		}
+		if delivered := len(chain.InternalMessages()); delivered != 6 {
+			t.Errorf("Unexpected internal messages count: %d", delivered)
+		}
+		// The next message is sent with the seqno after the confirmed batches.
+		if err := sender.Send(ctx, message(6)); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Resubmitted", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		chain.DropExternalMessages(1)
+		queue := newQueue(t, chain, 1)
+		ctx := run(t, queue)
+		if err := (QueueSender{Queue: queue}).Send(ctx, message(1)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// The lost message and the resubmitted one, the contract processes it once.
+		if sent := len(chain.SentMessages()); sent != 2 {
+			t.Errorf("Unexpected external messages count: %d", sent)
+		}
+		acc, err := chain.GetAccount(ctx, testContract)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions, err := chain.ListTransactions(ctx, testContract, 10, acc.LastTxLT, acc.LastTxHash)
+		if err != nil || len(transactions) != 1 {
+			t.Errorf("Unexpected contract transactions: %d, %v", len(transactions), err)
+		}
+	})
+
+	t.Run("Not confirmed", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		chain.DropExternalMessages(3)
+		queue := newQueue(t, chain, 1)
+		ctx := run(t, queue)
+		if err := (QueueSender{Queue: queue}).Send(ctx, message(1)); !errors.Is(err, tonnet.ErrNotConfirmed) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if sent := len(chain.SentMessages()); sent != 2 {
+			t.Errorf("Unexpected external messages count: %d", sent)
+		}
+	})
+
+	t.Run("Processed after the resubmissions", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		queue := newQueue(t, delayedChain{FakeChain: chain, delay: 500 * time.Millisecond}, 0)
+		ctx := run(t, queue)
+		// The message is processed before it expires, the batch is confirmed then.
+		if err := (QueueSender{Queue: queue}).Send(ctx, message(1)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if delivered := len(chain.InternalMessages()); delivered != 1 {
+			t.Errorf("Unexpected internal messages count: %d", delivered)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		chain := tonnettest.NewFakeChain()
+		chain.SetResult(testContract, 0, econtract.ErrNotRollState.Error())
+		queue := newQueue(t, chain, 0)
+		ctx := run(t, queue)
+		if err := (QueueSender{Queue: queue}).Send(ctx, message(1)); !errors.Is(err, econtract.ErrNotRollState) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
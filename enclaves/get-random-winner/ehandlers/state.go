@@ -0,0 +1,62 @@
+package ehandlers
+
+import (
+	"context"
+	"enclave/econtract"
+	"enclave/elog"
+	"encoding/hex"
+	"github.com/xssnick/tonutils-go/tlb"
+	"log/slog"
+	"time"
+)
+
+// EventStateReader reads the event state of the contract, e.g. econtract.Contract.
+type EventStateReader interface {
+	EventState(ctx context.Context) (econtract.EventState, error)
+}
+
+// now returns the current time as the contract block time.
+func (handlers *Handlers) now() uint32 {
+	return uint32(handlers.pending.now().Unix())
+}
+
+// acceptedByState applies the command to the mirror of the current event state and reports
+// whether the contract accepts it. Commands the contract would reject, e.g. a repeated or
+// a stale random(), are skipped. The state isn't checked if the reader isn't configured or fails.
+// The logger traces the command.
+func (handlers *Handlers) acceptedByState(logger *slog.Logger, apply func(state *econtract.EventState, now uint32) error) bool {
+	if handlers.config.State == nil {
+		return true
+	}
+	state, err := handlers.config.State.EventState(context.Background())
+	if err != nil {
+		logger.Warn("can't read event state, the command is not checked", "error", err)
+		return true
+	}
+	if err := apply(&state, handlers.now()); err != nil {
+		logger.Info("command is skipped in the event state", "state", state.State, "error", err)
+		return false
+	}
+	return true
+}
+
+// Recommit commits the last draw again when the contract still waits for the commit after the reveal timeout,
+// e.g. the commit is lost. The VRF input is the same request transaction, so the same winners are drawn.
+// The contract doesn't replace a commit waiting for the reveal, the draw is rolled again then.
+func (handlers *Handlers) Recommit(ctx context.Context) error {
+	if handlers.config.State == nil || handlers.lastDraw == nil {
+		return nil
+	}
+	state, err := handlers.config.State.EventState(ctx)
+	if err != nil {
+		slog.Warn("can't read event state", "error", err)
+		return nil
+	}
+	now := handlers.now()
+	if state.State != econtract.StateRoll || !state.TimedOut(now) || state.Completed(now) {
+		return nil
+	}
+	slog.Info("commit is not accepted in time, committing the draw again", elog.RequestID(hex.EncodeToString(handlers.lastDraw)))
+	handlers.Detected(time.Now())
+	return handlers.RandomCommit(&tlb.Transaction{Now: now, Hash: handlers.lastDraw})
+}
@@ -0,0 +1,35 @@
+package ehandlers
+
+import (
+	"enclave/emessages"
+	"enclave/erand"
+	"errors"
+	"github.com/tonteeton/golib/esign"
+	"io"
+)
+
+// vrfRandom returns the stream of random bytes derived from the VRF output of the enclave signature key
+// for the request transaction hash, and the proof to publish with the reveal.
+// The random values don't depend on the operator: anyone can check the proof with the enclave public key.
+func (handlers *Handlers) vrfRandom(txHash []byte) (io.Reader, *emessages.VRFProof, error) {
+	if len(txHash) != 32 {
+		return nil, nil, errors.New("unexpected transaction hash size")
+	}
+	key, err := esign.GetSignatureKey(handlers.config.Response.SignatureKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, output, err := erand.VRFProve(key.GetPrivateKey(), txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return erand.NewVRFStream(output), &emessages.VRFProof{Alpha: txHash, Proof: proof}, nil
+}
+
+// vrfProof returns the published proof of the commitment, empty if the commitment has no proof.
+func (commit pendingCommit) vrfProof() emessages.VRFProof {
+	if commit.VRF == nil {
+		return emessages.VRFProof{Alpha: make([]byte, 32), Proof: make([]byte, erand.VRFProofSize)}
+	}
+	return *commit.VRF
+}
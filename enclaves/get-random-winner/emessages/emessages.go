@@ -2,6 +2,7 @@
 package emessages
 
 import (
+	"errors"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tvm/cell"
 )
@@ -10,6 +11,8 @@ type RandomCommit struct {
 	Timestamp uint32
 	Recipient *address.Address
 	ValueHash []byte // The hash of RevealedValue value.
+	// ProjectsRoot is the Merkle root of the project list the draw is made from.
+	ProjectsRoot []byte
 }
 
 func (msg RandomCommit) GetOpcode() uint32 {
@@ -25,6 +28,7 @@ func (msg RandomCommit) ToCell() *cell.Cell {
 		MustStoreUInt(uint64(msg.Timestamp), 32).
 		MustStoreAddr(msg.Recipient).
 		MustStoreRef(hashCell).
+		MustStoreSlice(msg.ProjectsRoot, 256).
 		EndCell()
 }
 
@@ -34,6 +38,9 @@ type RandomReveal struct {
 	RevealTimestamp uint32
 	Nonce           uint64
 	TxHash          []byte
+	WeightsHash     []byte // The hash of the weight table used for the draw.
+	Index           uint32 // The project index in the list with the committed Merkle root.
+	VRF             VRFProof
 }
 
 func (msg RandomReveal) GetOpcode() uint32 {
@@ -48,16 +55,22 @@ func (msg RandomReveal) ToCell() *cell.Cell {
 		MustStoreUInt(uint64(msg.RevealTimestamp), 32).
 		MustStoreUInt(msg.Nonce, 64).
 		MustStoreRef(txHashCell).
+		MustStoreSlice(msg.WeightsHash, 256).
+		MustStoreUInt(uint64(msg.Index), 32).
+		MustStoreRef(msg.VRF.ToCell()).
 		EndCell()
 }
 
-
 type RevealedValue struct {
-	Timestamp uint32
-	Recipient *address.Address
-	Nonce     uint64
-	DoraID    uint64
-	Name      string
+	Timestamp   uint32
+	Recipient   *address.Address
+	Nonce       uint64
+	DoraID      uint64
+	Name        string
+	WeightsHash []byte // The hash of the weight table used for the draw.
+	// ProjectsRoot is the Merkle root of the project list, Index is the project index in it.
+	ProjectsRoot []byte
+	Index        uint32
 }
 
 func (msg RevealedValue) ToCell() *cell.Cell {
@@ -67,9 +80,118 @@ func (msg RevealedValue) ToCell() *cell.Cell {
 		MustStoreUInt(msg.Nonce, 64).
 		MustStoreUInt(uint64(msg.DoraID), 64).
 		MustStoreRef(cell.BeginCell().MustStoreStringSnake(msg.Name).EndCell()).
+		MustStoreSlice(msg.WeightsHash, 256).
+		MustStoreSlice(msg.ProjectsRoot, 256).
+		MustStoreUInt(uint64(msg.Index), 32).
 		EndCell()
 }
 
 func (msg RevealedValue) Hash() []byte {
 	return msg.ToCell().Hash()
 }
+
+// Winner is a project drawn in the draw of several distinct winners.
+type Winner struct {
+	DoraID uint64
+	Index  uint32 // The project index in the list with the committed Merkle root.
+	Name   string
+}
+
+// winnersToCell packs winners as a list of cells: DoraID, index, name reference and the next winner reference.
+func winnersToCell(winners []Winner) *cell.Cell {
+	var next *cell.Cell
+	for i := len(winners) - 1; i >= 0; i-- {
+		builder := cell.BeginCell().
+			MustStoreUInt(winners[i].DoraID, 64).
+			MustStoreUInt(uint64(winners[i].Index), 32).
+			MustStoreRef(cell.BeginCell().MustStoreStringSnake(winners[i].Name).EndCell())
+		if next != nil {
+			builder.MustStoreRef(next)
+		}
+		next = builder.EndCell()
+	}
+	return next
+}
+
+// RandomRevealWinners reveals several distinct winners drawn in one commit.
+type RandomRevealWinners struct {
+	Winners         []Winner
+	RevealTimestamp uint32
+	Nonce           uint64
+	TxHash          []byte
+	WeightsHash     []byte // The hash of the weight table used for the draw.
+	VRF             VRFProof
+}
+
+func (msg RandomRevealWinners) GetOpcode() uint32 {
+	return 0x5c6e4e1f
+}
+
+func (msg RandomRevealWinners) ToCell() *cell.Cell {
+	txHashCell := cell.BeginCell().MustStoreSlice(msg.TxHash, 256).EndCell()
+	return cell.BeginCell().
+		MustStoreRef(winnersToCell(msg.Winners)).
+		MustStoreUInt(uint64(msg.RevealTimestamp), 32).
+		MustStoreUInt(msg.Nonce, 64).
+		MustStoreRef(txHashCell).
+		MustStoreSlice(msg.WeightsHash, 256).
+		MustStoreRef(msg.VRF.ToCell()).
+		EndCell()
+}
+
+// RevealedWinners is the committed value of the draw of several distinct winners.
+type RevealedWinners struct {
+	Timestamp   uint32
+	Recipient   *address.Address
+	Nonce       uint64
+	Winners     []Winner
+	WeightsHash []byte // The hash of the weight table used for the draw.
+	// ProjectsRoot is the Merkle root of the project list.
+	ProjectsRoot []byte
+}
+
+func (msg RevealedWinners) ToCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(uint64(msg.Timestamp), 32).
+		MustStoreAddr(msg.Recipient).
+		MustStoreUInt(msg.Nonce, 64).
+		MustStoreRef(winnersToCell(msg.Winners)).
+		MustStoreSlice(msg.WeightsHash, 256).
+		MustStoreSlice(msg.ProjectsRoot, 256).
+		EndCell()
+}
+
+func (msg RevealedWinners) Hash() []byte {
+	return msg.ToCell().Hash()
+}
+
+// ParseRandomCommit parses the RandomCommit response body: opcode, signature reference and the payload.
+func ParseRandomCommit(body *cell.Cell) (RandomCommit, error) {
+	var msg RandomCommit
+	slice := body.BeginParse()
+	if opcode, err := slice.LoadUInt(32); err != nil || opcode != uint64(msg.GetOpcode()) {
+		return msg, errors.New("not a RandomCommit message")
+	}
+	if _, err := slice.LoadRef(); err != nil {
+		return msg, err
+	}
+	timestamp, err := slice.LoadUInt(32)
+	if err != nil {
+		return msg, err
+	}
+	msg.Timestamp = uint32(timestamp)
+	if msg.Recipient, err = slice.LoadAddr(); err != nil {
+		return msg, err
+	}
+	hashSlice, err := slice.LoadRef()
+	if err != nil {
+		return msg, err
+	}
+	if msg.ValueHash, err = hashSlice.LoadSlice(256); err != nil {
+		return msg, err
+	}
+	if msg.ProjectsRoot, err = slice.LoadSlice(256); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
@@ -1,19 +1,26 @@
 package emessages
 
 import (
+	"bytes"
 	"encoding/hex"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
 	"testing"
 )
 
 func TestRevealedValue(t *testing.T) {
 	t.Run("Hash as expected", func(t *testing.T) {
 		msg := RevealedValue{
-			Timestamp: 1721233023,
-			DoraID:    0xaaaaaa,
-			Name:      "Test project",
+			Timestamp:    1721233023,
+			DoraID:       0xaaaaaa,
+			Name:         "Test project",
+			WeightsHash:  bytes.Repeat([]byte{0xdd}, 32),
+			ProjectsRoot: bytes.Repeat([]byte{0xee}, 32),
+			Index:        3,
 		}
 		hash := hex.EncodeToString(msg.Hash())
-		expected := "258de9da53e437e731e0c198d62d4749da3febfda671f28d3f90cc57047d61ad"
+		expected := "4df53aa8e26773db378bfffc704f97155c48e77ea1dcf5551185d4b66cfba8a7"
 		if hash != expected {
 			t.Errorf("Unexpected hash: %#v. Expected: %#v", hash, expected)
 		}
@@ -32,11 +39,57 @@ func TestRandomReveal(t *testing.T) {
 			RevealTimestamp: 1721233100,
 			Nonce:           0xbbbbbb,
 			TxHash:          txHash,
+			WeightsHash:     bytes.Repeat([]byte{0xdd}, 32),
+			Index:           3,
+			VRF: VRFProof{
+				Alpha: bytes.Repeat([]byte{0xee}, 32),
+				Proof: bytes.Repeat([]byte{0xff}, 80),
+			},
 		}
 		boc := hex.EncodeToString(msg.ToCell().ToBOC())
-		expectedBOC := "b5ee9c724101030100480002280000000000aaaaaa6697eecc0000000000bbbbbb01020018546573742070726f6a6563740040cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc885d3b1e"
+		expectedBOC := "b5ee9c724101040100df0003700000000000aaaaaa6697eecc0000000000bbbbbbdddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd000000030102030018546573742070726f6a6563740040cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc00e0eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff23d6d2ae"
 		if boc != expectedBOC {
 			t.Errorf("Unexpected BOC: %#v. Expected: %#v", boc, expectedBOC)
 		}
 	})
+
+	t.Run("VRF proof is parsed back", func(t *testing.T) {
+		proof := VRFProof{
+			Alpha: bytes.Repeat([]byte{0x01}, 32),
+			Proof: bytes.Repeat([]byte{0x02}, 80),
+		}
+		parsed, err := ParseVRFProof(proof.ToCell())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(parsed.Alpha, proof.Alpha) || !bytes.Equal(parsed.Proof, proof.Proof) {
+			t.Errorf("Unexpected VRF proof: %x %x", parsed.Alpha, parsed.Proof)
+		}
+	})
+}
+
+func TestParseRandomRequested(t *testing.T) {
+	requester := address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+	t.Run("Parsed as expected", func(t *testing.T) {
+		body := cell.BeginCell().
+			MustStoreUInt(RandomRequestedOpcode, 32).
+			MustStoreAddr(requester).
+			MustStoreUInt(7, 64).
+			MustStoreBigUInt(big.NewInt(100), 256).
+			EndCell()
+		msg, err := ParseRandomRequested(body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if msg.QueryID != 7 || msg.Range.Int64() != 100 || msg.Requester.String() != requester.String() {
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+	})
+
+	t.Run("Other opcode", func(t *testing.T) {
+		body := cell.BeginCell().MustStoreUInt(RandomCommittedOpcode, 32).MustStoreAddr(requester).EndCell()
+		if _, err := ParseRandomRequested(body); err == nil {
+			t.Errorf("Expected error not raised")
+		}
+	})
 }
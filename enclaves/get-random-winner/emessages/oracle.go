@@ -0,0 +1,153 @@
+package emessages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+)
+
+// Messages of the general-purpose randomness oracle: any contract requests a random value
+// with a query ID and a range, the enclave commits and reveals the value bound to the request.
+
+const (
+	RandomRequestedOpcode = 0x7e2a0b3c
+	RandomCommittedOpcode = 0x3c4b8e02
+)
+
+// RequestID identifies the random value request by the requester and its query ID.
+func RequestID(requester *address.Address, queryID uint64) string {
+	return fmt.Sprintf("%d:%x:%d", requester.Workchain(), requester.Data(), queryID)
+}
+
+// RandomRequested is the event emitted by the contract on a random value request.
+type RandomRequested struct {
+	Requester *address.Address
+	QueryID   uint64
+	Range     *big.Int // The value is drawn from [0, Range).
+}
+
+// ParseRandomRequested parses the RandomRequested event body.
+func ParseRandomRequested(body *cell.Cell) (RandomRequested, error) {
+	var msg RandomRequested
+	slice := body.BeginParse()
+	if opcode, err := slice.LoadUInt(32); err != nil || opcode != RandomRequestedOpcode {
+		return msg, errors.New("not a RandomRequested event")
+	}
+	var err error
+	if msg.Requester, err = slice.LoadAddr(); err != nil {
+		return msg, err
+	}
+	if msg.QueryID, err = slice.LoadUInt(64); err != nil {
+		return msg, err
+	}
+	if msg.Range, err = slice.LoadBigUInt(256); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// RandomCommitted is the event emitted by the contract when the commitment to the requested value is accepted.
+type RandomCommitted struct {
+	Requester *address.Address
+	QueryID   uint64
+}
+
+// ParseRandomCommitted parses the RandomCommitted event body.
+func ParseRandomCommitted(body *cell.Cell) (RandomCommitted, error) {
+	var msg RandomCommitted
+	slice := body.BeginParse()
+	if opcode, err := slice.LoadUInt(32); err != nil || opcode != RandomCommittedOpcode {
+		return msg, errors.New("not a RandomCommitted event")
+	}
+	var err error
+	if msg.Requester, err = slice.LoadAddr(); err != nil {
+		return msg, err
+	}
+	if msg.QueryID, err = slice.LoadUInt(64); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// RandomRequestCommit commits to the value of the request.
+type RandomRequestCommit struct {
+	Timestamp uint32
+	Recipient *address.Address
+	Requester *address.Address
+	QueryID   uint64
+	ValueHash []byte // The hash of RevealedRandom value.
+}
+
+func (msg RandomRequestCommit) GetOpcode() uint32 {
+	return 0x6a0d4c13
+}
+
+func (msg RandomRequestCommit) ToCell() *cell.Cell {
+	hashCell := cell.BeginCell().
+		MustStoreSlice(msg.ValueHash, 256).
+		EndCell()
+
+	return cell.BeginCell().
+		MustStoreUInt(uint64(msg.Timestamp), 32).
+		MustStoreAddr(msg.Recipient).
+		MustStoreAddr(msg.Requester).
+		MustStoreUInt(msg.QueryID, 64).
+		MustStoreRef(hashCell).
+		EndCell()
+}
+
+// RandomRequestReveal reveals the committed value of the request.
+type RandomRequestReveal struct {
+	Requester *address.Address
+	QueryID   uint64
+	Value     *big.Int
+	Nonce     uint64
+	TxHash    []byte // The hash of the request transaction.
+	VRF       VRFProof
+}
+
+func (msg RandomRequestReveal) GetOpcode() uint32 {
+	return 0x1d7e5b64
+}
+
+func (msg RandomRequestReveal) ToCell() *cell.Cell {
+	txHashCell := cell.BeginCell().MustStoreSlice(msg.TxHash, 256).EndCell()
+	return cell.BeginCell().
+		MustStoreAddr(msg.Requester).
+		MustStoreUInt(msg.QueryID, 64).
+		MustStoreBigUInt(msg.Value, 256).
+		MustStoreUInt(msg.Nonce, 64).
+		MustStoreRef(txHashCell).
+		MustStoreRef(msg.VRF.ToCell()).
+		EndCell()
+}
+
+// RevealedRandom is the committed value of the request,
+// bound to the requester, the query ID and the request transaction hash.
+type RevealedRandom struct {
+	Timestamp uint32
+	Recipient *address.Address
+	Requester *address.Address
+	QueryID   uint64
+	Value     *big.Int
+	Nonce     uint64
+	TxHash    []byte
+}
+
+func (msg RevealedRandom) ToCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(uint64(msg.Timestamp), 32).
+		MustStoreAddr(msg.Recipient).
+		MustStoreAddr(msg.Requester).
+		MustStoreUInt(msg.QueryID, 64).
+		MustStoreBigUInt(msg.Value, 256).
+		MustStoreUInt(msg.Nonce, 64).
+		MustStoreRef(cell.BeginCell().MustStoreSlice(msg.TxHash, 256).EndCell()).
+		EndCell()
+}
+
+func (msg RevealedRandom) Hash() []byte {
+	return msg.ToCell().Hash()
+}
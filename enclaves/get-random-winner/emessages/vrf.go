@@ -0,0 +1,37 @@
+package emessages
+
+import (
+	"errors"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// VRFProof is the ECVRF proof of the revealed value: the value is derived from the VRF output
+// of the enclave key for Alpha, the hash of the transaction requesting the value.
+type VRFProof struct {
+	Alpha []byte // 32 bytes
+	Proof []byte // 80 bytes
+}
+
+func (msg VRFProof) ToCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreSlice(msg.Alpha, 256).
+		MustStoreSlice(msg.Proof, 640).
+		EndCell()
+}
+
+// ParseVRFProof parses the VRF proof cell, e.g. loaded from a reveal message.
+func ParseVRFProof(c *cell.Cell) (VRFProof, error) {
+	var msg VRFProof
+	if c == nil {
+		return msg, errors.New("no VRF proof")
+	}
+	slice := c.BeginParse()
+	var err error
+	if msg.Alpha, err = slice.LoadSlice(256); err != nil {
+		return msg, err
+	}
+	if msg.Proof, err = slice.LoadSlice(640); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
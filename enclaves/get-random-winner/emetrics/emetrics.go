@@ -0,0 +1,37 @@
+// Package emetrics provides Prometheus metrics of the enclave, served over HTTP.
+package emetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefBuckets are the default histogram buckets in seconds, for latencies from 10ms to 5 minutes.
+var DefBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Serve serves the metrics of the registry at /metrics on the port until the context is done.
+func Serve(ctx context.Context, port int, registry *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	slog.Info("serving metrics", "address", listener.Addr().String(), "path", "/metrics")
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	return nil
+}
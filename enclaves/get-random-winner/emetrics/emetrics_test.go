@@ -0,0 +1,58 @@
+package emetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/xssnick/tonutils-go/tlb"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	r := prometheus.NewRegistry()
+	metrics := NewWatch(r)
+	metrics.Transaction()
+	metrics.Command("random()")
+	metrics.Delivered("commit", time.Now())
+	metrics.SendFailed("rejected")
+	metrics.Balances(tlb.MustFromTON("2.5"), nil)
+
+	server := httptest.NewServer(promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"enclave_transactions_total 1",
+		`enclave_commands_total{command="random()"} 1`,
+		`enclave_response_latency_seconds_count{response="commit"} 1`,
+		`enclave_send_failures_total{reason="rejected"} 1`,
+		"enclave_wallet_balance_ton 2.5",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Missing %q in:\n%s", line, body)
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var disabled *Watch
+		disabled.Transaction()
+		disabled.Command("random()")
+		disabled.Delivered("commit", time.Now())
+		disabled.SendFailed("rejected")
+		disabled.Balances(tlb.MustFromTON("2.5"), nil)
+		disabled.Subscription(nil)
+	})
+}
@@ -0,0 +1,142 @@
+package emetrics
+
+import (
+	"enclave/tonnet"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/xssnick/tonutils-go/tlb"
+	"math/big"
+	"time"
+)
+
+// Watch holds the metrics of the watch command. The nil *Watch records nothing, so metrics are optional.
+type Watch struct {
+	transactions    prometheus.Counter
+	commands        *prometheus.CounterVec
+	responseLatency *prometheus.HistogramVec
+	sendFailures    *prometheus.CounterVec
+	walletBalance   prometheus.Gauge
+	contractBalance prometheus.Gauge
+	registerer      prometheus.Registerer
+}
+
+// NewWatch registers the watch metrics.
+func NewWatch(r prometheus.Registerer) *Watch {
+	factory := promauto.With(r)
+	return &Watch{
+		transactions: factory.NewCounter(prometheus.CounterOpts{
+			Name: "enclave_transactions_total",
+			Help: "Contract transactions processed by the watch loop.",
+		}),
+		commands: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "enclave_commands_total",
+			Help: "Commands emitted by the contract, by type.",
+		}, []string{"command"}),
+		responseLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "enclave_response_latency_seconds",
+			Help:    "Time from the command to the contract transaction accepting the response, by response type.",
+			Buckets: DefBuckets,
+		}, []string{"response"}),
+		sendFailures: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "enclave_send_failures_total",
+			Help: "Responses not delivered or not accepted by the contract, by reason.",
+		}, []string{"reason"}),
+		walletBalance: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "enclave_wallet_balance_ton",
+			Help: "Balance of the sender wallet.",
+		}),
+		contractBalance: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "enclave_contract_balance_ton",
+			Help: "Balance of the contract.",
+		}),
+		registerer: r,
+	}
+}
+
+// Transaction records the processed contract transaction.
+func (m *Watch) Transaction() {
+	if m == nil {
+		return
+	}
+	m.transactions.Inc()
+}
+
+// Command records the command emitted by the contract, e.g. "random()".
+func (m *Watch) Command(command string) {
+	if m == nil {
+		return
+	}
+	m.commands.WithLabelValues(command).Inc()
+}
+
+// Delivered records the latency of the response accepted by the contract, e.g. "commit".
+func (m *Watch) Delivered(response string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.responseLatency.WithLabelValues(response).Observe(time.Since(start).Seconds())
+}
+
+// SendFailed records the response not delivered for the reason, e.g. "rejected".
+func (m *Watch) SendFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.sendFailures.WithLabelValues(reason).Inc()
+}
+
+// Balances records the wallet balance and the contract one, if it's known.
+func (m *Watch) Balances(wallet tlb.Coins, contract *tlb.Coins) {
+	if m == nil {
+		return
+	}
+	m.walletBalance.Set(toTON(wallet))
+	if contract != nil {
+		m.contractBalance.Set(toTON(*contract))
+	}
+}
+
+// Subscription registers the liveness metrics of the transaction subscription, it must be called once.
+func (m *Watch) Subscription(subscription *tonnet.Subscription) {
+	if m == nil {
+		return
+	}
+	factory := promauto.With(m.registerer)
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "enclave_subscription_live",
+		Help: "Whether the transaction subscription runs and keeps up with the chain.",
+	}, func() float64 {
+		if subscription.Liveness().Live {
+			return 1
+		}
+		return 0
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "enclave_subscription_restarts",
+		Help: "Restarts of the transaction subscription.",
+	}, func() float64 { return float64(subscription.Liveness().Restarts) })
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "enclave_last_block_time_seconds",
+		Help: "Block time of the last contract transaction seen, as a Unix time.",
+	}, func() float64 { return unixTime(subscription.Liveness().LastTxTime) })
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "enclave_masterchain_seqno",
+		Help: "Last seen masterchain block.",
+	}, func() float64 { return float64(subscription.Liveness().MasterSeqno) })
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "enclave_masterchain_updated_time_seconds",
+		Help: "When the masterchain was last seen advancing, as a Unix time.",
+	}, func() float64 { return unixTime(subscription.Liveness().MasterUpdated) })
+}
+
+func toTON(coins tlb.Coins) float64 {
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(coins.Nano()), big.NewFloat(1e9)).Float64()
+	return value
+}
+
+func unixTime(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
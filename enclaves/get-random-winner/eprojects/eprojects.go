@@ -1,30 +1,74 @@
 package eprojects
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"os"
 )
 
+// DefaultWeight is the weight of projects without an explicit weight.
+const DefaultWeight = 1
+
+// ErrNotEnoughProjects is returned when fewer projects are left than winners are drawn.
+var ErrNotEnoughProjects = errors.New("not enough projects left to draw")
+
 type Project struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Weight uint64 `json:"weight,omitempty"` // Optional draw weight, e.g. community votes or a tier.
+}
+
+// GetWeight returns the project draw weight, DefaultWeight if it is not set.
+func (p Project) GetWeight() uint64 {
+	if p.Weight == 0 {
+		return DefaultWeight
+	}
+	return p.Weight
 }
 
 type Projects []Project
 
 func LoadProjects(filename string) (Projects, error) {
-	var projects Projects
-
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
+	return ParseProjects(data)
+}
 
-	err = json.Unmarshal(data, &projects)
-	if err != nil {
-		return nil, err
+// ParseProjects parses the JSON project list strictly: unknown fields are rejected,
+// names are normalized and the list is validated. Errors refer to lines of the data.
+func ParseProjects(data []byte) (Projects, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if token, err := decoder.Token(); err != nil {
+		return nil, lineError(data, decoder.InputOffset(), err)
+	} else if token != json.Delim('[') {
+		return nil, lineError(data, 0, errors.New("project list must be a JSON array"))
 	}
 
+	var projects Projects
+	var lines []int
+	for decoder.More() {
+		line := lineAt(data, decoder.InputOffset())
+		var project Project
+		if err := decoder.Decode(&project); err != nil {
+			return nil, lineError(data, decoder.InputOffset(), err)
+		}
+		project.Name = NormalizeName(project.Name)
+		projects = append(projects, project)
+		lines = append(lines, line)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, lineError(data, decoder.InputOffset(), err)
+	}
+
+	if err := projects.validate(lines); err != nil {
+		return nil, err
+	}
 	return projects, nil
 }
 
@@ -40,3 +84,34 @@ func (p Projects) GetByIndex(index int) *Project {
 	}
 	return &p[index]
 }
+
+// Weights returns the draw weights of projects, in the database order.
+func (p Projects) Weights() []uint64 {
+	weights := make([]uint64, len(p))
+	for i, project := range p {
+		weights[i] = project.GetWeight()
+	}
+	return weights
+}
+
+// WeightsHash returns the SHA-256 hash of the weight table,
+// the sequence of big-endian uint64 project ID and weight pairs in the database order.
+func (p Projects) WeightsHash() []byte {
+	hash := sha256.New()
+	for _, project := range p {
+		binary.Write(hash, binary.BigEndian, uint64(project.ID))
+		binary.Write(hash, binary.BigEndian, project.GetWeight())
+	}
+	return hash.Sum(nil)
+}
+
+// Exclude returns projects except ones with the given IDs.
+func (p Projects) Exclude(ids map[int]bool) Projects {
+	result := make(Projects, 0, len(p))
+	for _, project := range p {
+		if !ids[project.ID] {
+			result = append(result, project)
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package eprojects
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWeights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buidls.json")
+	content := `[{"id": 1, "name": "First"}, {"id": 2, "name": "Second", "weight": 3}]`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	projects, err := LoadProjects(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Default weight", func(t *testing.T) {
+		weights := projects.Weights()
+		if len(weights) != 2 || weights[0] != DefaultWeight || weights[1] != 3 {
+			t.Errorf("Unexpected weights: %v", weights)
+		}
+	})
+
+	t.Run("Weights hash", func(t *testing.T) {
+		hash := projects.WeightsHash()
+		if len(hash) != 32 {
+			t.Fatalf("Unexpected hash size: %d", len(hash))
+		}
+		explicit := Projects{{ID: 1, Name: "First", Weight: 1}, {ID: 2, Name: "Other", Weight: 3}}
+		if !bytes.Equal(explicit.WeightsHash(), hash) {
+			t.Errorf("Hash depends on names or default weights")
+		}
+		changed := Projects{{ID: 1, Name: "First"}, {ID: 2, Name: "Second", Weight: 4}}
+		if bytes.Equal(changed.WeightsHash(), hash) {
+			t.Errorf("Hash does not depend on weights")
+		}
+	})
+}
+
+func TestMerkleProof(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		projects := make(Projects, count)
+		for i := range projects {
+			projects[i] = Project{ID: i + 10, Name: "Project", Weight: uint64(i % 3)}
+		}
+		root := projects.MerkleRoot()
+		if len(root) != 32 {
+			t.Fatalf("Unexpected root size: %d", len(root))
+		}
+		for index, project := range projects {
+			proof, err := projects.Proof(index)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !VerifyProof(root, project, proof) {
+				t.Errorf("Proof of %d of %d is invalid", index, count)
+			}
+			other := project
+			other.Name = "Other"
+			if VerifyProof(root, other, proof) {
+				t.Errorf("Proof of %d of %d is valid for another project", index, count)
+			}
+			if count > 1 {
+				proof.Index = (index + 1) % count
+				if VerifyProof(root, project, proof) {
+					t.Errorf("Proof of %d of %d is valid at another index", index, count)
+				}
+			}
+		}
+	}
+
+	t.Run("Root depends on order", func(t *testing.T) {
+		projects := Projects{{ID: 1, Name: "First"}, {ID: 2, Name: "Second"}}
+		swapped := Projects{projects[1], projects[0]}
+		if bytes.Equal(projects.MerkleRoot(), swapped.MerkleRoot()) {
+			t.Errorf("Root does not depend on the order")
+		}
+	})
+
+	t.Run("Index out of range", func(t *testing.T) {
+		if _, err := (Projects{{ID: 1}}).Proof(1); err == nil {
+			t.Errorf("Expected error not raised")
+		}
+	})
+}
+
+func TestParseProjects(t *testing.T) {
+	t.Run("Names are normalized", func(t *testing.T) {
+		projects, err := ParseProjects([]byte(`[{"id": 1, "name": " Café "}]`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if projects[0].Name != "Café" {
+			t.Errorf("Unexpected name: %q", projects[0].Name)
+		}
+	})
+
+	cases := []struct {
+		name        string
+		content     string
+		expectedErr error
+		expectedMsg string
+	}{
+		{"Not array", `{"id": 1}`, nil, "line 1: project list must be a JSON array"},
+		{"Empty list", `[]`, ErrEmptyList, ""},
+		{"Syntax error", "[\n{\"id\": 1, \"name\": \"A\"},\n{\"id\": 2 \"name\": \"B\"}]", nil, "line 3:"},
+		{"Type error", "[\n{\"id\": \"1\", \"name\": \"A\"}]", nil, "line 2:"},
+		{"Unknown field", "[\n{\"id\": 1, \"name\": \"A\"},\n{\"id\": 2, \"title\": \"B\"}]", nil, "line 3:"},
+		{"Zero ID", "[\n{\"id\": 0, \"name\": \"A\"}]", ErrInvalidID, "line 2: project #0"},
+		{"Duplicate ID", "[\n{\"id\": 1, \"name\": \"A\"},\n{\"id\": 1, \"name\": \"B\"}]", ErrDuplicateID, "line 3: project #1"},
+		{"Empty name", "[\n{\"id\": 1, \"name\": \"  \"}]", ErrEmptyName, "line 2:"},
+		{"Long name", `[{"id": 1, "name": "` + strings.Repeat("я", 64) + `"}]`, ErrNameTooLong, "line 1:"},
+		{"Control characters", `[{"id": 1, "name": "A\nB"}]`, ErrNameControl, ""},
+	}
+	for _, tcase := range cases {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := ParseProjects([]byte(tcase.content))
+			if err == nil {
+				t.Fatalf("Expected error not raised")
+			}
+			if tcase.expectedErr != nil && !errors.Is(err, tcase.expectedErr) {
+				t.Errorf("Unexpected error: %v, expected: %v", err, tcase.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tcase.expectedMsg) {
+				t.Errorf("Unexpected error: %v, expected: %v", err, tcase.expectedMsg)
+			}
+		})
+	}
+
+	t.Run("Not normalized names are invalid", func(t *testing.T) {
+		err := Projects{{ID: 1, Name: "A "}}.Validate()
+		if !errors.Is(err, ErrNotNormalized) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Built-in list is valid", func(t *testing.T) {
+		if _, err := LoadProjects("../buidls.json"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package eprojects
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Prefixes separate leaf and node hashes, so a node can't be presented as a leaf.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Proof is the Merkle inclusion proof of the project at Index in the list of Count projects.
+type Proof struct {
+	Index    int
+	Count    int
+	Siblings [][]byte // Sibling hashes from the leaf level up.
+}
+
+// LeafHash returns the Merkle leaf hash of the project:
+// SHA-256 of 0x00, big-endian uint64 ID and weight, and the UTF-8 name.
+func (p Project) LeafHash() []byte {
+	hash := sha256.New()
+	hash.Write([]byte{leafPrefix})
+	binary.Write(hash, binary.BigEndian, uint64(p.ID))
+	binary.Write(hash, binary.BigEndian, p.GetWeight())
+	hash.Write([]byte(p.Name))
+	return hash.Sum(nil)
+}
+
+func nodeHash(left []byte, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{nodePrefix})
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+// nextLevel hashes pairs of the level nodes, the last odd node is carried up as is.
+func nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+		} else {
+			next = append(next, nodeHash(level[i], level[i+1]))
+		}
+	}
+	return next
+}
+
+func (p Projects) leaves() [][]byte {
+	level := make([][]byte, len(p))
+	for i, project := range p {
+		level[i] = project.LeafHash()
+	}
+	return level
+}
+
+// MerkleRoot returns the Merkle root over the projects in the database order, nil for an empty list.
+func (p Projects) MerkleRoot() []byte {
+	if len(p) == 0 {
+		return nil
+	}
+	level := p.leaves()
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+// Proof returns the inclusion proof of the project at the index.
+func (p Projects) Proof(index int) (Proof, error) {
+	if index < 0 || index >= len(p) {
+		return Proof{}, errors.New("project index is out of range")
+	}
+	proof := Proof{Index: index, Count: len(p)}
+	level := p.leaves()
+	for i := index; len(level) > 1; i /= 2 {
+		if sibling := i ^ 1; sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+		level = nextLevel(level)
+	}
+	return proof, nil
+}
+
+// VerifyProof checks that the project is included in the list with the root at the proof index.
+func VerifyProof(root []byte, project Project, proof Proof) bool {
+	if proof.Index < 0 || proof.Index >= proof.Count {
+		return false
+	}
+	hash := project.LeafHash()
+	siblings := proof.Siblings
+	for i, count := proof.Index, proof.Count; count > 1; i, count = i/2, (count+1)/2 {
+		if i^1 >= count {
+			// The last odd node is carried up.
+			continue
+		}
+		if len(siblings) == 0 {
+			return false
+		}
+		if i%2 == 0 {
+			hash = nodeHash(hash, siblings[0])
+		} else {
+			hash = nodeHash(siblings[0], hash)
+		}
+		siblings = siblings[1:]
+	}
+	return len(siblings) == 0 && bytes.Equal(hash, root)
+}
+
+// IndexOf returns the index of the project with the ID, or -1 if it is not found.
+func (p Projects) IndexOf(id int) int {
+	for i, project := range p {
+		if project.ID == id {
+			return i
+		}
+	}
+	return -1
+}
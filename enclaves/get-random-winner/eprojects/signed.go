@@ -0,0 +1,87 @@
+package eprojects
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tonteeton/golib/ekeys"
+	"os"
+)
+
+// rootSignaturePrefix separates project list signatures from other messages signed by the owner key.
+const rootSignaturePrefix = "get-random-winner projects root:"
+
+// SignedProjects is the project list signed by the contract owner.
+type SignedProjects struct {
+	Projects  Projects `json:"projects"`
+	Signature string   `json:"signature"` // Hex-encoded ed25519 signature of the list Merkle root.
+}
+
+func rootSigningMessage(root []byte) []byte {
+	return append([]byte(rootSignaturePrefix), root...)
+}
+
+// Sign signs the project list Merkle root with the owner private key.
+func Sign(projects Projects, privateKey ed25519.PrivateKey) SignedProjects {
+	signature := ed25519.Sign(privateKey, rootSigningMessage(projects.MerkleRoot()))
+	return SignedProjects{Projects: projects, Signature: hex.EncodeToString(signature)}
+}
+
+// LoadSignedProjects loads the signed project list from the JSON file.
+func LoadSignedProjects(filename string) (SignedProjects, error) {
+	var signed SignedProjects
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return signed, err
+	}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return signed, err
+	}
+	return signed, nil
+}
+
+// Verify checks the list is signed by the owner key and its Merkle root is the announced one.
+func (s SignedProjects) Verify(ownerKey ed25519.PublicKey, announcedRoot []byte) error {
+	if err := s.Projects.Validate(); err != nil {
+		return err
+	}
+	if len(ownerKey) != ed25519.PublicKeySize {
+		return errors.New("unexpected owner key size")
+	}
+	signature, err := hex.DecodeString(s.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	root := s.Projects.MerkleRoot()
+	if !ed25519.Verify(ownerKey, rootSigningMessage(root), signature) {
+		return errors.New("project list is not signed by the owner")
+	}
+	if announcedRoot == nil {
+		return errors.New("project list is not announced in the contract")
+	}
+	if !bytes.Equal(root, announcedRoot) {
+		return fmt.Errorf("project list root %x does not match the announced root %x", root, announcedRoot)
+	}
+	return nil
+}
+
+// Seal writes the project list to the sealed file.
+func (p Projects) Seal(path string, additionalData []byte, sealers ...ekeys.DataSealer) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ekeys.WriteEncryptedFile(path, data, additionalData, sealers...)
+}
+
+// LoadSealedProjects reads the project list from the sealed file.
+func LoadSealedProjects(path string, additionalData []byte, unsealers ...ekeys.DataSealer) (Projects, error) {
+	data, err := ekeys.ReadEncryptedFile(path, additionalData, unsealers...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProjects(data)
+}
@@ -0,0 +1,68 @@
+package eprojects
+
+import (
+	"crypto/ed25519"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func noSeal(data []byte, additionalData []byte) ([]byte, error) {
+	return data, nil
+}
+
+func TestSignedProjects(t *testing.T) {
+	ownerKey, ownerPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := Projects{{ID: 1, Name: "First"}, {ID: 2, Name: "Second", Weight: 3}}
+	signed := Sign(projects, ownerPrivateKey)
+
+	if err := signed.Verify(ownerKey, projects.MerkleRoot()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	changed := signed
+	changed.Projects = Projects{projects[0], {ID: 2, Name: "Second", Weight: 4}}
+	cases := []struct {
+		name          string
+		signed        SignedProjects
+		ownerKey      ed25519.PublicKey
+		announcedRoot []byte
+		expectedErr   string
+	}{
+		{"Other key", signed, otherKey, projects.MerkleRoot(), "not signed by the owner"},
+		{"Changed list", changed, ownerKey, projects.MerkleRoot(), "not signed by the owner"},
+		{"Not announced", signed, ownerKey, nil, "not announced"},
+		{"Other root", signed, ownerKey, changed.Projects.MerkleRoot(), "does not match"},
+		{"Empty list", SignedProjects{}, ownerKey, nil, "empty"},
+	}
+	for _, tcase := range cases {
+		t.Run(tcase.name, func(t *testing.T) {
+			err := tcase.signed.Verify(tcase.ownerKey, tcase.announcedRoot)
+			if err == nil || !strings.Contains(err.Error(), tcase.expectedErr) {
+				t.Errorf("Unexpected error: %v, expected: %v", err, tcase.expectedErr)
+			}
+		})
+	}
+
+	t.Run("Seal and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "projects.enc")
+		if err := projects.Seal(path, []byte("test"), noSeal); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadSealedProjects(path, []byte("test"), noSeal)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, projects) {
+			t.Errorf("Unexpected projects: %+v", loaded)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package eprojects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// MaxNameLength is the max project name size in bytes,
+// so the name fits one cell of the snake string in RandomReveal (1023 bits).
+const MaxNameLength = 127
+
+var (
+	ErrEmptyList     = errors.New("project list is empty")
+	ErrInvalidID     = errors.New("id must be positive")
+	ErrDuplicateID   = errors.New("duplicate id")
+	ErrEmptyName     = errors.New("name is empty")
+	ErrNameTooLong   = fmt.Errorf("name is longer than %d bytes", MaxNameLength)
+	ErrNameControl   = errors.New("name contains control characters")
+	ErrNotNormalized = errors.New("name is not normalized")
+)
+
+// ProjectError is the validation error of the project at Index of the list.
+type ProjectError struct {
+	Line  int // The line of the project in the JSON data, 0 if unknown.
+	Index int
+	ID    int
+	Err   error
+}
+
+func (e ProjectError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: project #%d (id %d): %v", e.Line, e.Index, e.ID, e.Err)
+	}
+	return fmt.Sprintf("project #%d (id %d): %v", e.Index, e.ID, e.Err)
+}
+
+func (e ProjectError) Unwrap() error {
+	return e.Err
+}
+
+// NormalizeName returns the name in Unicode NFC form without surrounding spaces.
+func NormalizeName(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+// Validate checks the list can be used for a draw. Names must be normalized with NormalizeName.
+// All found errors are returned joined, each one is a ProjectError.
+func (p Projects) Validate() error {
+	return p.validate(nil)
+}
+
+// validate validates projects, lines are the project lines in the JSON data, if known.
+func (p Projects) validate(lines []int) error {
+	if len(p) == 0 {
+		return ErrEmptyList
+	}
+
+	var errs []error
+	seen := map[int]int{}
+	for i, project := range p {
+		projectErr := func(err error) {
+			e := ProjectError{Index: i, ID: project.ID, Err: err}
+			if i < len(lines) {
+				e.Line = lines[i]
+			}
+			errs = append(errs, e)
+		}
+
+		if project.ID <= 0 {
+			projectErr(ErrInvalidID)
+		} else if first, ok := seen[project.ID]; ok {
+			projectErr(fmt.Errorf("%w, first is project #%d", ErrDuplicateID, first))
+		} else {
+			seen[project.ID] = i
+		}
+
+		switch {
+		case project.Name == "":
+			projectErr(ErrEmptyName)
+		case project.Name != NormalizeName(project.Name):
+			projectErr(ErrNotNormalized)
+		case len(project.Name) > MaxNameLength:
+			projectErr(ErrNameTooLong)
+		case strings.IndexFunc(project.Name, unicode.IsControl) >= 0:
+			projectErr(ErrNameControl)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lineAt returns the line of the first value at or after the offset in the JSON data.
+func lineAt(data []byte, offset int64) int {
+	end := int(min(offset, int64(len(data))))
+	for end < len(data) && strings.IndexByte(" \t\r\n,", data[end]) >= 0 {
+		end++
+	}
+	return 1 + strings.Count(string(data[:end]), "\n")
+}
+
+// lineError adds the line to the JSON decoding error, using the error offset if it has one.
+func lineError(data []byte, offset int64, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	return fmt.Errorf("line %d: %w", lineAt(data, offset), err)
+}
@@ -2,21 +2,175 @@
 package erand
 
 import (
-	"crypto/rand"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"math"
 	"math/big"
+	"sort"
 )
 
+// uint256Range is the number of uint256 values, 2^256.
+var uint256Range = new(big.Int).Lsh(big.NewInt(1), 256)
+
 // RandUInt64 generates a pseudo-random uint64 value in the range [0, maxValue).
+// The range can't include math.MaxUint64, use RandFullUInt64 for the full range.
 func RandUInt64(maxValue uint64) (uint64, error) {
+	return UInt64From(CryptoSource, maxValue)
+}
+
+// UInt64From is RandUInt64 with random bytes from the source.
+func UInt64From(source Source, maxValue uint64) (uint64, error) {
 	if maxValue == 0 {
 		return math.MaxUint64, errors.New("maxValue must be greater than 0")
 	}
 	maxBig := big.NewInt(0).SetUint64(maxValue)
-	value, err := rand.Int(rand.Reader, maxBig)
+	value, err := uniformInt(source, maxBig)
 	if err != nil {
 		return math.MaxUint64, err
 	}
 	return value.Uint64(), nil
 }
+
+// RandFullUInt64 generates a random uint64 value in the full range, including math.MaxUint64, e.g. a nonce.
+func RandFullUInt64() (uint64, error) {
+	return FullUInt64From(CryptoSource)
+}
+
+// FullUInt64From is RandFullUInt64 with random bytes from the source.
+func FullUInt64From(source Source) (uint64, error) {
+	buf, err := BytesFrom(source, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// RandBytes returns n random bytes.
+func RandBytes(n int) ([]byte, error) {
+	return BytesFrom(CryptoSource, n)
+}
+
+// BytesFrom is RandBytes with random bytes from the source.
+func BytesFrom(source Source, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("n must not be negative")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(source, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Shuffle shuffles n elements with the Fisher–Yates algorithm, swap swaps the elements with indexes i and j.
+func Shuffle(n int, swap func(i, j int)) error {
+	return ShuffleFrom(CryptoSource, n, swap)
+}
+
+// ShuffleFrom is Shuffle with random bytes from the source, e.g. a seeded one to reproduce the order.
+func ShuffleFrom(source Source, n int, swap func(i, j int)) error {
+	if n < 0 {
+		return errors.New("n must not be negative")
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := UInt64From(source, uint64(i)+1)
+		if err != nil {
+			return err
+		}
+		swap(i, int(j))
+	}
+	return nil
+}
+
+// RandWeightedIndex returns a random index in weights, picked with probability proportional to its weight.
+// The index is found by the uniform value in [0, total weight) in cumulative weights, so the draw is unbiased.
+func RandWeightedIndex(weights []uint64) (int, error) {
+	return weightedIndex(CryptoSource, weights)
+}
+
+func weightedIndex(source Source, weights []uint64) (int, error) {
+	cumulative := make([]uint64, len(weights))
+	var total uint64
+	for i, weight := range weights {
+		if total+weight < total {
+			return -1, errors.New("total weight overflows uint64")
+		}
+		total += weight
+		cumulative[i] = total
+	}
+	if total == 0 {
+		return -1, errors.New("total weight must be greater than 0")
+	}
+
+	value, err := uniformInt(source, big.NewInt(0).SetUint64(total))
+	if err != nil {
+		return -1, err
+	}
+	// The first index with the cumulative weight greater than value.
+	return sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > value.Uint64() }), nil
+}
+
+// RandWeightedSample returns count distinct random indexes in weights, drawn one by one without replacement,
+// each with probability proportional to its weight among the remaining ones.
+func RandWeightedSample(weights []uint64, count int) ([]int, error) {
+	return WeightedSampleFrom(CryptoSource, weights, count)
+}
+
+// WeightedSampleFrom is RandWeightedSample with random bytes from the source,
+// e.g. the VRF output stream, so the draw can be reproduced from it.
+func WeightedSampleFrom(source Source, weights []uint64, count int) ([]int, error) {
+	remaining := append([]uint64(nil), weights...)
+	indexes := make([]int, 0, count)
+	for len(indexes) < count {
+		index, err := weightedIndex(source, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("can't draw %d of %d: %w", len(indexes)+1, count, err)
+		}
+		indexes = append(indexes, index)
+		remaining[index] = 0
+	}
+	return indexes, nil
+}
+
+// RandBigInt generates a random value in the range [0, maxValue), e.g. a 256-bit range.
+func RandBigInt(maxValue *big.Int) (*big.Int, error) {
+	return BigIntFrom(CryptoSource, maxValue)
+}
+
+// BigIntFrom is RandBigInt with random bytes from the source.
+func BigIntFrom(source Source, maxValue *big.Int) (*big.Int, error) {
+	if maxValue == nil || maxValue.Sign() <= 0 {
+		return nil, errors.New("maxValue must be greater than 0")
+	}
+	return uniformInt(source, maxValue)
+}
+
+// RandUint256 generates a random value in the full uint256 range [0, 2^256).
+func RandUint256() (*big.Int, error) {
+	return BigIntFrom(CryptoSource, uint256Range)
+}
+
+// uniformInt returns a uniform value in [0, maxValue) by rejection sampling:
+// big-endian values of the maxValue bit length are read until one is less than maxValue.
+// Unlike crypto/rand.Int, the algorithm is fixed, so third parties can reproduce values from a known stream.
+func uniformInt(source Source, maxValue *big.Int) (*big.Int, error) {
+	limit := new(big.Int).Sub(maxValue, big.NewInt(1))
+	bitLen := limit.BitLen()
+	if bitLen == 0 {
+		return new(big.Int), nil
+	}
+	buf := make([]byte, (bitLen+7)/8)
+	value := new(big.Int)
+	for {
+		if _, err := io.ReadFull(source, buf); err != nil {
+			return nil, err
+		}
+		// Clear the bits above the bit length.
+		buf[0] &= byte(1<<(uint(bitLen-1)%8+1) - 1)
+		if value.SetBytes(buf).Cmp(maxValue) < 0 {
+			return value, nil
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package erand
 
 import (
+	"bytes"
 	"math"
+	"math/big"
 	"testing"
 )
 
@@ -39,3 +41,166 @@ func TestRandUInt64(t *testing.T) {
 		})
 	}
 }
+
+func TestRandWeightedIndex(t *testing.T) {
+	t.Run("Invalid weights", func(t *testing.T) {
+		for _, weights := range [][]uint64{nil, {0, 0}, {math.MaxUint64, 1}} {
+			if _, err := RandWeightedIndex(weights); err == nil {
+				t.Errorf("RandWeightedIndex(%v) did not return an error", weights)
+			}
+		}
+	})
+
+	t.Run("Zero weights are never drawn", func(t *testing.T) {
+		weights := []uint64{0, 5, 0, 1, 0}
+		for i := 0; i < 1000; i++ {
+			index, err := RandWeightedIndex(weights)
+			if err != nil {
+				t.Fatalf("RandWeightedIndex(%v) returned error: %v", weights, err)
+			}
+			if weights[index] == 0 {
+				t.Fatalf("RandWeightedIndex(%v) = %d, zero weight drawn", weights, index)
+			}
+		}
+	})
+
+	t.Run("Draws are proportional to weights", func(t *testing.T) {
+		weights := []uint64{1, 3}
+		counts := make([]int, len(weights))
+		const draws = 20000
+		for i := 0; i < draws; i++ {
+			index, err := RandWeightedIndex(weights)
+			if err != nil {
+				t.Fatalf("RandWeightedIndex(%v) returned error: %v", weights, err)
+			}
+			counts[index]++
+		}
+		// Expected 5000 and 15000, the bounds are far beyond the standard deviation of ~61.
+		if counts[0] < 4500 || counts[0] > 5500 {
+			t.Errorf("Unexpected draw counts: %v", counts)
+		}
+	})
+}
+
+func TestRandWeightedSample(t *testing.T) {
+	weights := []uint64{1, 0, 7, 2}
+	for i := 0; i < 100; i++ {
+		indexes, err := RandWeightedSample(weights, 3)
+		if err != nil {
+			t.Fatalf("RandWeightedSample(%v, 3) returned error: %v", weights, err)
+		}
+		seen := map[int]bool{}
+		for _, index := range indexes {
+			if weights[index] == 0 || seen[index] {
+				t.Fatalf("RandWeightedSample(%v, 3) = %v, not distinct positive weights", weights, indexes)
+			}
+			seen[index] = true
+		}
+	}
+	if _, err := RandWeightedSample(weights, 4); err == nil {
+		t.Errorf("RandWeightedSample(%v, 4) did not return an error", weights)
+	}
+}
+
+func TestSeededSource(t *testing.T) {
+	first, err := BytesFrom(NewSeededSource(1), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := BytesFrom(NewSeededSource(1), 100)
+	other, _ := BytesFrom(NewSeededSource(2), 100)
+	if !bytes.Equal(first, second) {
+		t.Error("Seeded source is not deterministic")
+	}
+	if bytes.Equal(first, other) {
+		t.Error("Seeded sources don't depend on the seed")
+	}
+
+	sample, _ := WeightedSampleFrom(NewSeededSource(1), []uint64{1, 2, 3, 4}, 4)
+	again, _ := WeightedSampleFrom(NewSeededSource(1), []uint64{1, 2, 3, 4}, 4)
+	for i := range sample {
+		if sample[i] != again[i] {
+			t.Fatalf("Draws from the same seed differ: %v, %v", sample, again)
+		}
+	}
+}
+
+func TestRandBytes(t *testing.T) {
+	for _, n := range []int{0, 1, 32, 100} {
+		data, err := RandBytes(n)
+		if err != nil || len(data) != n {
+			t.Errorf("RandBytes(%d) = %x, %v", n, data, err)
+		}
+	}
+	if _, err := RandBytes(-1); err == nil {
+		t.Error("RandBytes(-1) did not return an error")
+	}
+}
+
+func TestRandUint256(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		value, err := RandUint256()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value.Sign() < 0 || value.BitLen() > 256 {
+			t.Fatalf("RandUint256() = %v, out of range", value)
+		}
+	}
+	maxValue := new(big.Int).Lsh(big.NewInt(1), 255)
+	value, err := RandBigInt(maxValue)
+	if err != nil || value.Cmp(maxValue) >= 0 {
+		t.Errorf("RandBigInt(2^255) = %v, %v", value, err)
+	}
+}
+
+func TestFullUInt64(t *testing.T) {
+	// All 8 bytes are used as is, so math.MaxUint64 can be returned.
+	source := bytes.NewReader(bytes.Repeat([]byte{0xff}, 8))
+	value, err := FullUInt64From(source)
+	if err != nil || value != math.MaxUint64 {
+		t.Errorf("FullUInt64From() = %d, %v, expected math.MaxUint64", value, err)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	shuffled := func(seed uint64) []int {
+		values := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		if err := ShuffleFrom(NewSeededSource(seed), len(values), func(i, j int) {
+			values[i], values[j] = values[j], values[i]
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	values := shuffled(1)
+	seen := map[int]bool{}
+	for _, value := range values {
+		seen[value] = true
+	}
+	if len(seen) != len(values) {
+		t.Fatalf("Shuffle result is not a permutation: %v", values)
+	}
+	again := shuffled(1)
+	for i := range values {
+		if values[i] != again[i] {
+			t.Fatalf("Shuffles with the same seed differ: %v, %v", values, again)
+		}
+	}
+
+	// Every element gets to every position with the crypto source.
+	positions := map[[2]int]bool{}
+	for i := 0; i < 200; i++ {
+		values := []int{0, 1, 2}
+		if err := Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] }); err != nil {
+			t.Fatal(err)
+		}
+		for position, value := range values {
+			positions[[2]int{value, position}] = true
+		}
+	}
+	if len(positions) != 9 {
+		t.Errorf("Not all positions are reached: %v", positions)
+	}
+}
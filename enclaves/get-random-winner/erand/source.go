@@ -0,0 +1,50 @@
+package erand
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+)
+
+// Source is the source of random bytes the values are drawn from.
+type Source interface {
+	io.Reader
+}
+
+// CryptoSource is the production source, the crypto/rand reader.
+var CryptoSource Source = rand.Reader
+
+// hashStream is the deterministic stream of SHA-512 blocks of the seed and the block counter.
+type hashStream struct {
+	seed    []byte
+	counter uint64
+	block   []byte
+}
+
+func newHashStream(seed []byte) *hashStream {
+	return &hashStream{seed: append([]byte(nil), seed...)}
+}
+
+func (stream *hashStream) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(stream.block) == 0 {
+			hash := sha512.New()
+			hash.Write(stream.seed)
+			binary.Write(hash, binary.BigEndian, stream.counter)
+			stream.block = hash.Sum(nil)
+			stream.counter++
+		}
+		copied := copy(p[n:], stream.block)
+		stream.block = stream.block[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// NewSeededSource returns the deterministic source for the seed, e.g. to reproduce draws in tests.
+// It must not be used in production: anyone knowing the seed knows the values.
+func NewSeededSource(seed uint64) Source {
+	return newHashStream(binary.BigEndian.AppendUint64(nil, seed))
+}
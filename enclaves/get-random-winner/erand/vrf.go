@@ -0,0 +1,176 @@
+package erand
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"github.com/oasisprotocol/curve25519-voi/curve"
+	"github.com/oasisprotocol/curve25519-voi/curve/scalar"
+)
+
+// ECVRF-EDWARDS25519-SHA512-TAI verifiable random function (RFC 9381, section 5.5).
+// The enclave ed25519 key is the VRF key: anyone can check with the enclave public key
+// that the output is the only one possible for the input, so the value was not chosen by the operator.
+
+const (
+	// VRFProofSize is the size of the proof: Gamma point, challenge and response.
+	VRFProofSize = 80
+	// VRFOutputSize is the size of the VRF output (beta).
+	VRFOutputSize = 64
+
+	vrfSuite         = 0x03
+	vrfChallengeSize = 16
+)
+
+var ErrInvalidVRFProof = errors.New("invalid VRF proof")
+
+// VRFProve returns the proof and the output of the VRF of the key for the input (alpha).
+func VRFProve(key ed25519.PrivateKey, alpha []byte) (proof []byte, output []byte, err error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, nil, errors.New("unexpected private key size")
+	}
+	// The secret scalar and the nonce prefix are derived from the seed as in ed25519.
+	hashedKey := sha512.Sum512(key.Seed())
+	hashedKey[0] &= 248
+	hashedKey[31] &= 127
+	hashedKey[31] |= 64
+	x, err := scalar.NewFromBytesModOrder(hashedKey[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	h, err := vrfHashToCurve(publicKey, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	hString := compress(h)
+	gamma := curve.NewEdwardsPoint().Mul(h, x)
+
+	nonceHash := sha512.Sum512(append(hashedKey[32:], hString...))
+	k, err := scalar.NewFromBytesModOrderWide(nonceHash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	u := curve.NewEdwardsPoint().MulBasepoint(curve.ED25519_BASEPOINT_TABLE, k)
+	v := curve.NewEdwardsPoint().Mul(h, k)
+	c := vrfChallenge(publicKey, hString, compress(gamma), compress(u), compress(v))
+
+	cScalar, err := challengeScalar(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := scalar.New().Mul(cScalar, x)
+	s.Add(s, k)
+	sBytes := make([]byte, scalar.ScalarSize)
+	if err := s.ToBytes(sBytes); err != nil {
+		return nil, nil, err
+	}
+
+	proof = append(append(compress(gamma), c...), sBytes...)
+	return proof, vrfProofToHash(gamma), nil
+}
+
+// VRFVerify checks the proof of the VRF of the public key for the input (alpha) and returns the VRF output.
+func VRFVerify(publicKey ed25519.PublicKey, alpha []byte, proof []byte) ([]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize || len(proof) != VRFProofSize {
+		return nil, ErrInvalidVRFProof
+	}
+	y, err := decompress(publicKey)
+	if err != nil || y.IsSmallOrder() {
+		return nil, ErrInvalidVRFProof
+	}
+	gamma, err := decompress(proof[:32])
+	if err != nil {
+		return nil, ErrInvalidVRFProof
+	}
+	c := proof[32 : 32+vrfChallengeSize]
+	cScalar, err := challengeScalar(c)
+	if err != nil {
+		return nil, ErrInvalidVRFProof
+	}
+	s, err := scalar.NewFromCanonicalBytes(proof[32+vrfChallengeSize:])
+	if err != nil {
+		return nil, ErrInvalidVRFProof
+	}
+
+	h, err := vrfHashToCurve(publicKey, alpha)
+	if err != nil {
+		return nil, ErrInvalidVRFProof
+	}
+	negC := scalar.New().Neg(cScalar)
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	u := curve.NewEdwardsPoint().DoubleScalarMulBasepointVartime(negC, y, s)
+	v := curve.NewEdwardsPoint().MultiscalarMulVartime([]*scalar.Scalar{s, negC}, []*curve.EdwardsPoint{h, gamma})
+	expected := vrfChallenge(publicKey, compress(h), proof[:32], compress(u), compress(v))
+	if !bytes.Equal(expected, c) {
+		return nil, ErrInvalidVRFProof
+	}
+	return vrfProofToHash(gamma), nil
+}
+
+// vrfHashToCurve is ECVRF_encode_to_curve_try_and_increment with the public key as the salt.
+func vrfHashToCurve(publicKey []byte, alpha []byte) (*curve.EdwardsPoint, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		hash := sha512.New()
+		hash.Write([]byte{vrfSuite, 0x01})
+		hash.Write(publicKey)
+		hash.Write(alpha)
+		hash.Write([]byte{byte(ctr), 0x00})
+		if point, err := decompress(hash.Sum(nil)[:32]); err == nil {
+			return curve.NewEdwardsPoint().MulByCofactor(point), nil
+		}
+	}
+	return nil, errors.New("can't hash VRF input to curve")
+}
+
+// vrfChallenge is ECVRF_challenge_generation over the encoded points.
+func vrfChallenge(points ...[]byte) []byte {
+	hash := sha512.New()
+	hash.Write([]byte{vrfSuite, 0x02})
+	for _, point := range points {
+		hash.Write(point)
+	}
+	hash.Write([]byte{0x00})
+	return hash.Sum(nil)[:vrfChallengeSize]
+}
+
+func vrfProofToHash(gamma *curve.EdwardsPoint) []byte {
+	hash := sha512.New()
+	hash.Write([]byte{vrfSuite, 0x03})
+	hash.Write(compress(curve.NewEdwardsPoint().MulByCofactor(gamma)))
+	hash.Write([]byte{0x00})
+	return hash.Sum(nil)
+}
+
+// challengeScalar converts the little-endian challenge to the scalar.
+func challengeScalar(c []byte) (*scalar.Scalar, error) {
+	padded := make([]byte, scalar.ScalarSize)
+	copy(padded, c)
+	return scalar.NewFromCanonicalBytes(padded)
+}
+
+func compress(point *curve.EdwardsPoint) []byte {
+	compressed := curve.NewCompressedEdwardsY().SetEdwardsPoint(point)
+	return compressed[:]
+}
+
+// decompress decodes the canonical point encoding as in RFC 8032.
+func decompress(data []byte) (*curve.EdwardsPoint, error) {
+	compressed, err := curve.NewCompressedEdwardsYFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed.IsCanonicalVartime() {
+		return nil, errors.New("non-canonical point encoding")
+	}
+	return curve.NewEdwardsPoint().SetCompressedY(compressed)
+}
+
+// NewVRFStream returns the source of random bytes derived from the VRF output:
+// SHA-512(output || big-endian uint64 counter) for counter 0, 1, ...
+// Values drawn from the source with WeightedSampleFrom or BigIntFrom can be reproduced by anyone with the output.
+func NewVRFStream(output []byte) Source {
+	return newHashStream(output)
+}
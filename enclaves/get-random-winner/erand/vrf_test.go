@@ -0,0 +1,124 @@
+package erand
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVRF(t *testing.T) {
+	// The first ECVRF-EDWARDS25519-SHA512-TAI example of RFC 9381, appendix B.3.
+	seed := mustDecodeHex(t, "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	publicKey := mustDecodeHex(t, "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	expectedProof := mustDecodeHex(t, "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f723f"+
+		"26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0daab12"+
+		"68a1b0db10836d9826a528ca76567805")
+	expectedOutput := mustDecodeHex(t, "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876ff"+
+		"66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fdd8ae")
+
+	key := ed25519.NewKeyFromSeed(seed)
+	t.Run("Test vector", func(t *testing.T) {
+		proof, output, err := VRFProve(key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(proof, expectedProof) {
+			t.Errorf("proof = %x, expected %x", proof, expectedProof)
+		}
+		if !bytes.Equal(output, expectedOutput) {
+			t.Errorf("output = %x, expected %x", output, expectedOutput)
+		}
+		output, err = VRFVerify(publicKey, nil, expectedProof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(output, expectedOutput) {
+			t.Errorf("verified output = %x, expected %x", output, expectedOutput)
+		}
+	})
+
+	t.Run("Invalid proofs", func(t *testing.T) {
+		alpha := []byte("request tx hash")
+		proof, _, err := VRFProve(key, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+		tests := []struct {
+			name      string
+			publicKey []byte
+			alpha     []byte
+			proof     []byte
+		}{
+			{"Other input", publicKey, []byte("other tx hash"), proof},
+			{"Other key", otherKey.Public().(ed25519.PublicKey), alpha, proof},
+			{"Short proof", publicKey, alpha, proof[:VRFProofSize-1]},
+			{"Changed gamma", publicKey, alpha, flipBit(proof, 0)},
+			{"Changed challenge", publicKey, alpha, flipBit(proof, 32)},
+			{"Changed response", publicKey, alpha, flipBit(proof, 48)},
+			{"Response out of range", publicKey, alpha, append(proof[:48:48], bytes.Repeat([]byte{0xff}, 32)...)},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := VRFVerify(tt.publicKey, tt.alpha, tt.proof); err == nil {
+					t.Error("invalid proof is accepted")
+				}
+			})
+		}
+		if _, err := VRFVerify(publicKey, alpha, proof); err != nil {
+			t.Errorf("valid proof is rejected: %v", err)
+		}
+	})
+}
+
+func flipBit(data []byte, index int) []byte {
+	result := append([]byte(nil), data...)
+	result[index] ^= 1
+	return result
+}
+
+func TestVRFStream(t *testing.T) {
+	output := bytes.Repeat([]byte{7}, VRFOutputSize)
+	first := make([]byte, 100)
+	NewVRFStream(output).Read(first)
+	second := make([]byte, 100)
+	stream := NewVRFStream(output)
+	stream.Read(second[:10])
+	stream.Read(second[10:])
+	if !bytes.Equal(first, second) {
+		t.Fatal("stream depends on the read sizes")
+	}
+
+	// Values drawn from the same output are reproducible.
+	weights := []uint64{1, 5, 0, 3, 2}
+	sample, err := WeightedSampleFrom(NewVRFStream(output), weights, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := WeightedSampleFrom(NewVRFStream(output), weights, 3)
+	for i := range sample {
+		if sample[i] != again[i] || weights[sample[i]] == 0 {
+			t.Fatalf("unexpected samples %v and %v", sample, again)
+		}
+	}
+	maxValue := big.NewInt(1000)
+	value, err := BigIntFrom(NewVRFStream(output), maxValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	againValue, _ := BigIntFrom(NewVRFStream(output), maxValue)
+	if value.Cmp(againValue) != 0 || value.Cmp(maxValue) >= 0 {
+		t.Fatalf("unexpected values %v and %v", value, againValue)
+	}
+}
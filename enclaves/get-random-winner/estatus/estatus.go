@@ -0,0 +1,229 @@
+// Package estatus provides the status report of the enclave deployment:
+// the contract state and balance, the sender wallet balance and the enclave identity trusted by the contract.
+// The report is collected on a best-effort basis, so a failing getter doesn't hide the rest of the status.
+package estatus
+
+import (
+	"bytes"
+	"context"
+	"enclave/econtract"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/esign"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Sources are the status report sources.
+type Sources struct {
+	Contract    econtract.Contract
+	Wallet      *address.Address    // The sender wallet, not reported if nil.
+	Keys        econf.KeysConfig    // The enclave signature keys.
+	Attestation eattest.Attestation // The enclave self report.
+	// LastHandledLT returns the last contract transaction handled by the watch, not reported if nil.
+	LastHandledLT func() (uint64, error)
+}
+
+// Report is the status report. Balances are in nanotons in the JSON output.
+type Report struct {
+	Contract ContractStatus        `json:"contract"`
+	Event    *econtract.EventState `json:"event,omitempty"`
+	Wallet   *WalletStatus         `json:"wallet,omitempty"`
+	Enclave  EnclaveStatus         `json:"enclave"`
+	Errors   []string              `json:"errors,omitempty"`
+}
+
+// ContractStatus is the contract state() report and account state.
+type ContractStatus struct {
+	Address string     `json:"address"`
+	Name    string     `json:"name,omitempty"`
+	Version string     `json:"version,omitempty"`
+	Owner   string     `json:"owner,omitempty"`
+	Balance *tlb.Coins `json:"balance,omitempty"`
+	// The contract state() doesn't report whether the contract is moved or stopped, they are always null.
+	Moved     *bool   `json:"moved"`
+	Stopped   *bool   `json:"stopped"`
+	LastTxLT  uint64  `json:"lastTxLT"`            // The last transaction of the contract.
+	HandledLT *uint64 `json:"handledLT,omitempty"` // The last transaction handled by the watch, zero if none yet.
+}
+
+// WalletStatus is the sender wallet account state.
+type WalletStatus struct {
+	Address string     `json:"address"`
+	Balance *tlb.Coins `json:"balance,omitempty"`
+}
+
+// EnclaveStatus is the running enclave identity and the one trusted by the contract.
+type EnclaveStatus struct {
+	PublicKey           string `json:"publicKey,omitempty"`
+	UniqueID            string `json:"uniqueId,omitempty"`
+	ContractPublicKey   string `json:"contractPublicKey,omitempty"`
+	ContractMeasurement string `json:"contractMeasurement,omitempty"`
+	KeyMatch            bool   `json:"keyMatch"`
+	MeasurementMatch    bool   `json:"measurementMatch"`
+}
+
+// Collect collects the status report, errors of the sources are reported in Report.Errors.
+func Collect(ctx context.Context, src Sources) Report {
+	var report Report
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	contract := src.Contract
+
+	report.Contract.Address = contract.Address.String()
+	if state, err := contract.State(ctx); err == nil {
+		report.Contract.Name = state.Name
+		report.Contract.Version = state.VersionString()
+		report.Contract.Owner = state.Owner.String()
+	} else {
+		fail(err)
+	}
+	if balance, err := contract.Balance(ctx); err == nil {
+		report.Contract.Balance = &balance
+	} else {
+		fail(err)
+	}
+	if account, err := contract.Chain.GetAccount(ctx, contract.Address); err == nil {
+		report.Contract.LastTxLT = account.LastTxLT
+	} else {
+		fail(fmt.Errorf("contract account: %w", err))
+	}
+	if src.LastHandledLT != nil {
+		if lt, err := src.LastHandledLT(); err == nil {
+			report.Contract.HandledLT = &lt
+		} else {
+			fail(fmt.Errorf("watch state: %w", err))
+		}
+	}
+	if event, err := contract.EventState(ctx); err == nil {
+		report.Event = &event
+	} else {
+		fail(err)
+	}
+
+	if src.Wallet != nil {
+		report.Wallet = &WalletStatus{Address: src.Wallet.String()}
+		if account, err := contract.Chain.GetAccount(ctx, src.Wallet); err == nil {
+			report.Wallet.Balance = &account.Balance
+		} else {
+			fail(fmt.Errorf("wallet account: %w", err))
+		}
+	}
+
+	// The key and the measurement are read separately, so the key is reported outside SGX as well.
+	var publicKey, uniqueID []byte
+	if key, err := esign.GetSignatureKey(src.Keys); err == nil {
+		publicKey = key.GetPublicKey()
+		report.Enclave.PublicKey = hex.EncodeToString(publicKey)
+	} else {
+		fail(fmt.Errorf("can't load the signature key: %w", err))
+	}
+	if selfReport, err := src.Attestation.GetSelfReport(); err == nil {
+		uniqueID = selfReport.UniqueID
+		report.Enclave.UniqueID = hex.EncodeToString(uniqueID)
+	} else {
+		fail(fmt.Errorf("can't get the enclave report: %w", err))
+	}
+	if key, err := contract.EnclavePublicKey(ctx); err == nil {
+		report.Enclave.ContractPublicKey = hex.EncodeToString(key)
+		report.Enclave.KeyMatch = publicKey != nil && bytes.Equal(key, publicKey)
+	} else {
+		fail(err)
+	}
+	if measurement, err := contract.EnclaveMeasurement(ctx); err == nil {
+		report.Enclave.ContractMeasurement = hex.EncodeToString(measurement)
+		report.Enclave.MeasurementMatch = uniqueID != nil && bytes.Equal(measurement, uniqueID)
+	} else {
+		fail(err)
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report for humans.
+func (r Report) WriteText(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Contract %s\n", r.Contract.Address)
+	fmt.Fprintf(&b, "  name:        %s %s\n", orUnknown(r.Contract.Name), r.Contract.Version)
+	fmt.Fprintf(&b, "  owner:       %s\n", orUnknown(r.Contract.Owner))
+	fmt.Fprintf(&b, "  balance:     %s\n", formatCoins(r.Contract.Balance))
+	fmt.Fprintf(&b, "  moved:       unavailable, not reported by the contract\n")
+	fmt.Fprintf(&b, "  stopped:     unavailable, not reported by the contract\n")
+	fmt.Fprintf(&b, "  last tx LT:  %s handled by the watch, %d on chain\n", formatLT(r.Contract.HandledLT), r.Contract.LastTxLT)
+	if e := r.Event; e != nil {
+		fmt.Fprintf(&b, "Event\n")
+		fmt.Fprintf(&b, "  state:       %s, updated %s\n", econtract.StateName(e.State), formatTime(uint64(e.Updated)))
+		fmt.Fprintf(&b, "  completion:  %s\n", formatTime(e.CompletionTime))
+		fmt.Fprintf(&b, "  stakes:      rock %s TON, roll %s TON\n", tlb.FromNanoTONU(e.StakeOnRock), tlb.FromNanoTONU(e.StakeOnRoll))
+		fmt.Fprintf(&b, "  winner:      #%d %s\n", e.DoraID, e.Name)
+		fmt.Fprintf(&b, "  prize:       %s TON\n", tlb.FromNanoTONU(e.Prize))
+	}
+	if r.Wallet != nil {
+		fmt.Fprintf(&b, "Wallet %s\n", r.Wallet.Address)
+		fmt.Fprintf(&b, "  balance:     %s\n", formatCoins(r.Wallet.Balance))
+	}
+	fmt.Fprintf(&b, "Enclave\n")
+	fmt.Fprintf(&b, "  public key:  %s (%s)\n", orUnknown(r.Enclave.PublicKey),
+		formatMatch(r.Enclave.KeyMatch, r.Enclave.ContractPublicKey))
+	fmt.Fprintf(&b, "  unique ID:   %s (%s)\n", orUnknown(r.Enclave.UniqueID),
+		formatMatch(r.Enclave.MeasurementMatch, r.Enclave.ContractMeasurement))
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Errors\n")
+		for _, err := range r.Errors {
+			fmt.Fprintf(&b, "  %s\n", err)
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+func formatLT(lt *uint64) string {
+	switch {
+	case lt == nil:
+		return "unknown"
+	case *lt == 0:
+		return "none"
+	}
+	return strconv.FormatUint(*lt, 10)
+}
+
+func formatCoins(coins *tlb.Coins) string {
+	if coins == nil {
+		return "unknown"
+	}
+	return coins.String() + " TON"
+}
+
+func formatTime(unix uint64) string {
+	return time.Unix(int64(unix), 0).UTC().Format(time.RFC3339)
+}
+
+func formatMatch(match bool, contractValue string) string {
+	switch {
+	case match:
+		return "trusted by the contract"
+	case contractValue == "":
+		return "contract value unknown"
+	}
+	return "MISMATCH, the contract trusts " + contractValue
+}
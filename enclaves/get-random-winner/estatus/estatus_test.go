@@ -0,0 +1,131 @@
+package estatus
+
+import (
+	"bytes"
+	"context"
+	"enclave/econtract"
+	"enclave/tonnet/tonnettest"
+	"encoding/json"
+	"errors"
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/esign"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testContract = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+	testWallet   = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+)
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	keys := econf.KeysConfig{
+		PublicKeyPath:  filepath.Join(dir, "public.key"),
+		PrivateKeyPath: filepath.Join(dir, "private.key"),
+		SealedDatePath: filepath.Join(dir, "date.enc"),
+		Version:        "test",
+	}
+	key, err := esign.GetSignatureKey(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uniqueID := bytes.Repeat([]byte{3}, 32)
+
+	chain := tonnettest.NewFakeChain()
+	chain.AddTransaction(testContract, "Rock")
+	chain.SetBalance(testWallet, tlb.MustFromTON("2"))
+	chain.SetGetMethod(testContract, "state", []any{
+		cell.BeginCell().MustStoreStringSnake("get-random-winner").EndCell().BeginParse(),
+		big.NewInt(1<<24 | 1<<16),
+		cell.BeginCell().MustStoreAddr(testWallet).EndCell().BeginParse(),
+	})
+	chain.SetGetMethod(testContract, "balance", big.NewInt(1_500_000_000))
+	chain.SetGetMethod(testContract, "eventState", []any{
+		big.NewInt(econtract.StateRock), big.NewInt(0), big.NewInt(1000), big.NewInt(2000),
+		big.NewInt(econtract.StakeMinValue), big.NewInt(0), big.NewInt(7),
+		cell.BeginCell().MustStoreStringSnake("Winner").EndCell().BeginParse(), big.NewInt(5),
+	})
+	chain.SetGetMethod(testContract, "enclavePublicKey", new(big.Int).SetBytes(key.GetPublicKey()))
+	chain.SetGetMethod(testContract, "enclaveMeasurment", big.NewInt(4))
+
+	src := Sources{
+		Contract: econtract.Contract{Chain: chain, Address: testContract},
+		Wallet:   testWallet,
+		Keys:     keys,
+		Attestation: eattest.Attestation{
+			GetSelfReport: func() (attestation.Report, error) {
+				return attestation.Report{UniqueID: uniqueID}, nil
+			},
+		},
+		LastHandledLT: func() (uint64, error) { return 42, nil },
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		report := Collect(context.Background(), src)
+		if len(report.Errors) != 0 {
+			t.Fatalf("Unexpected errors: %v", report.Errors)
+		}
+		if report.Contract.Name != "get-random-winner" || report.Contract.Version != "1.1.0" ||
+			report.Contract.Balance.String() != "1.5" || report.Contract.LastTxLT == 0 || *report.Contract.HandledLT != 42 {
+			t.Errorf("Unexpected contract status: %+v", report.Contract)
+		}
+		if report.Event == nil || report.Event.DoraID != 7 || report.Event.Name != "Winner" {
+			t.Errorf("Unexpected event status: %+v", report.Event)
+		}
+		if report.Wallet == nil || report.Wallet.Balance.String() != "2" {
+			t.Errorf("Unexpected wallet status: %+v", report.Wallet)
+		}
+		if !report.Enclave.KeyMatch || report.Enclave.MeasurementMatch {
+			t.Errorf("Unexpected enclave status: %+v", report.Enclave)
+		}
+
+		var text bytes.Buffer
+		if err := report.WriteText(&text); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"1.5 TON", "#7 Winner", "trusted by the contract", "MISMATCH",
+			"42 handled by the watch", "stopped:     unavailable"} {
+			if !strings.Contains(text.String(), expected) {
+				t.Errorf("%q is not in the text report:\n%s", expected, text.String())
+			}
+		}
+
+		var out bytes.Buffer
+		if err := report.WriteJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		var decoded Report
+		if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Contract.Balance.Nano().Int64() != 1_500_000_000 || *decoded.Event != *report.Event ||
+			!strings.Contains(out.String(), `"moved": null`) {
+			t.Errorf("Unexpected JSON report: %s", out.String())
+		}
+	})
+
+	t.Run("Errors are reported", func(t *testing.T) {
+		failing := src
+		failing.Contract.Address = testWallet
+		failing.Wallet = nil
+		failing.Attestation.GetSelfReport = func() (attestation.Report, error) {
+			return attestation.Report{}, errors.New("not in an enclave")
+		}
+		report := Collect(context.Background(), failing)
+		if report.Enclave.PublicKey == "" || report.Enclave.UniqueID != "" || report.Wallet != nil {
+			t.Errorf("Unexpected enclave status: %+v", report.Enclave)
+		}
+		// state, balance, eventState, the self report and both enclave getters.
+		if len(report.Errors) != 6 {
+			t.Errorf("Unexpected errors: %v", report.Errors)
+		}
+	})
+}
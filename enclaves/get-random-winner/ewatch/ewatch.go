@@ -0,0 +1,208 @@
+// Package ewatch provides the loop watching the contract transactions and dispatching emitted commands to handlers.
+package ewatch
+
+import (
+	"context"
+	"enclave/ebalance"
+	"enclave/econtract"
+	"enclave/elog"
+	"enclave/emessages"
+	"enclave/emetrics"
+	"enclave/eprojects"
+	"enclave/tonnet"
+	"enclave/txparser"
+	"errors"
+	"github.com/tonteeton/golib/ekeys"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// CommandHandlers handles commands emitted by the contract.
+type CommandHandlers interface {
+	RandomCommit(tx *tlb.Transaction) error
+	RandomReveal(tx *tlb.Transaction) error
+
+	// Random value requests of other contracts.
+	RandomRequestCommit(tx *tlb.Transaction, request emessages.RandomRequested) error
+	RandomRequestReveal(tx *tlb.Transaction, committed emessages.RandomCommitted) error
+
+	// Detected sets when the commands handled next were detected, the response latency is measured from it.
+	Detected(at time.Time)
+
+	// Recommit commits the draw again if the contract hasn't accepted the commit before the reveal timeout.
+	Recommit(ctx context.Context) error
+
+	// Deliveries returns the results of the queued responses, applied by the watch loop.
+	Deliveries() <-chan func() error
+}
+
+// Config contains dependencies of the watch loop.
+type Config struct {
+	Chain    tonnet.Chain
+	Parser   txparser.TransactionParser
+	Handlers CommandHandlers
+	// RecommitInterval is the period of reveal timeout checks, they are disabled if it is not set.
+	RecommitInterval time.Duration
+	// Subscription supervises the contract transactions subscription, one with the default settings is used if not set.
+	Subscription *tonnet.Subscription
+	// Metrics records the processed transactions and commands, nothing is recorded if not set.
+	Metrics *emetrics.Watch
+	// StatePath is the sealed file of the last handled transaction, the watch resumes after it on restart.
+	// The watch starts from the current contract state if it's empty or the file doesn't exist.
+	StatePath string
+	// SealVersion is the additional data used for sealing.
+	SealVersion string
+	// Sealer and Unsealer override the default ekeys sealing, e.g. in tests.
+	Sealer   ekeys.DataSealer
+	Unsealer ekeys.DataSealer
+}
+
+// Watch handles new contract transactions until the context is done or a handler fails.
+// The subscription is supervised, it's resumed from the last handled transaction when it stops or stalls.
+// The last handled transaction is sealed, so after a restart the watch resumes after it rather than
+// from the current contract state, and the transactions received meanwhile are handled.
+// Responses rejected by the contract, not sent on the critical wallet balance or not confirmed are logged
+// by the handlers, the watch goes on with the next commands and transactions. Responses of an asynchronous
+// sender are delivered while the loop keeps handling transactions, their results are applied by the loop.
+func Watch(ctx context.Context, cfg Config) error {
+	contractAddress := cfg.Parser.Address
+
+	slog.Info("fetching contract state", "address", contractAddress.String())
+	acc, err := cfg.Chain.GetAccount(ctx, contractAddress)
+	if err != nil {
+		return err
+	}
+	state, err := loadState(cfg)
+	if err != nil {
+		return err
+	}
+	if state.LastLT == 0 || state.LastLT > acc.LastTxLT {
+		state.LastLT = acc.LastTxLT
+	} else if state.LastLT < acc.LastTxLT {
+		slog.Info("resuming after the last handled transaction", elog.LT(state.LastLT))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	subscription := cfg.Subscription
+	if subscription == nil {
+		subscription = tonnet.NewSubscription(cfg.Chain, contractAddress, tonnet.SubscriptionConfig{})
+	}
+	transactions := make(chan *tlb.Transaction)
+	go subscription.Run(ctx, state.LastLT, transactions)
+
+	var recommit <-chan time.Time
+	if cfg.RecommitInterval > 0 {
+		ticker := time.NewTicker(cfg.RecommitInterval)
+		defer ticker.Stop()
+		recommit = ticker.C
+	}
+
+	slog.Info("waiting for transactions", elog.LT(state.LastLT))
+	for {
+		select {
+		case tx, ok := <-transactions:
+			if !ok {
+				return ctx.Err()
+			}
+			if err := handleTransaction(cfg, tx); err != nil && !skipped(err) {
+				return err
+			}
+			state.LastLT = tx.LT
+			if err := saveState(cfg, state); err != nil {
+				return err
+			}
+		case <-recommit:
+			if err := cfg.Handlers.Recommit(ctx); err != nil && !skipped(err) {
+				return err
+			}
+		case delivered := <-cfg.Handlers.Deliveries():
+			if err := delivered(); err != nil && !skipped(err) {
+				return err
+			}
+		}
+	}
+}
+
+// skipped reports whether the error is the contract rejection of the response, the critical wallet balance,
+// the wallet transaction not confirmed after resubmissions, or the draw from a drained project list.
+// The watch goes on after them.
+func skipped(err error) bool {
+	return errors.Is(err, econtract.ErrRejected) ||
+		errors.Is(err, eprojects.ErrNotEnoughProjects) ||
+		errors.Is(err, ebalance.ErrCriticalBalance) ||
+		errors.Is(err, tonnet.ErrNotConfirmed)
+}
+
+// handled logs the skipped error of the command handler and returns nil, so the other commands
+// of the transaction are handled. Other errors are returned.
+func handled(logger *slog.Logger, command string, err error) error {
+	if err != nil && skipped(err) {
+		logger.Info("command is skipped", elog.Command(command), "error", err)
+		return nil
+	}
+	return err
+}
+
+func handleTransaction(cfg Config, tx *tlb.Transaction) error {
+	cfg.Metrics.Transaction()
+	logger := slog.With(elog.TxHash(tx.Hash), elog.LT(tx.LT))
+	logger.Debug("transaction received")
+	comments := cfg.Parser.ParseExternalComments(tx)
+	cfg.Handlers.Detected(time.Now())
+	if slices.Contains(comments, "random()") {
+		logger.Info("command detected", elog.Command("random()"))
+		cfg.Metrics.Command("random()")
+		if err := handled(logger, "random()", cfg.Handlers.RandomCommit(tx)); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(comments, "reveal()") {
+		logger.Info("command detected", elog.Command("reveal()"))
+		cfg.Metrics.Command("reveal()")
+		if err := handled(logger, "reveal()", cfg.Handlers.RandomReveal(tx)); err != nil {
+			return err
+		}
+	}
+	for _, body := range cfg.Parser.ParseExternalBodies(tx) {
+		if err := handleEvent(cfg, logger, tx, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleEvent dispatches the event emitted by the contract, unknown events are ignored.
+func handleEvent(cfg Config, logger *slog.Logger, tx *tlb.Transaction, body *cell.Cell) error {
+	opcode, err := body.BeginParse().LoadUInt(32)
+	if err != nil {
+		return nil
+	}
+	switch opcode {
+	case emessages.RandomRequestedOpcode:
+		request, err := emessages.ParseRandomRequested(body)
+		if err != nil {
+			logger.Warn("invalid event", elog.Command("RandomRequested"), "error", err)
+			return nil
+		}
+		logger.Info("command detected", elog.Command("RandomRequested"),
+			elog.RequestID(emessages.RequestID(request.Requester, request.QueryID)))
+		cfg.Metrics.Command("RandomRequested")
+		return handled(logger, "RandomRequested", cfg.Handlers.RandomRequestCommit(tx, request))
+	case emessages.RandomCommittedOpcode:
+		committed, err := emessages.ParseRandomCommitted(body)
+		if err != nil {
+			logger.Warn("invalid event", elog.Command("RandomCommitted"), "error", err)
+			return nil
+		}
+		logger.Info("command detected", elog.Command("RandomCommitted"),
+			elog.RequestID(emessages.RequestID(committed.Requester, committed.QueryID)))
+		cfg.Metrics.Command("RandomCommitted")
+		return handled(logger, "RandomCommitted", cfg.Handlers.RandomRequestReveal(tx, committed))
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package ewatch
+
+import (
+	"bytes"
+	"context"
+	"enclave/econtract"
+	"enclave/ehandlers"
+	"enclave/emessages"
+	"enclave/eprojects"
+	"enclave/tonnet"
+	"enclave/tonnet/tonnettest"
+	"enclave/txparser"
+	"errors"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/eresp"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The contract address is used non-bounceable, as in appconf.
+var testContract = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2").Bounce(false)
+
+var testProjects = eprojects.Projects{{ID: 11, Name: "First"}, {ID: 22, Name: "Second", Weight: 5}, {ID: 33, Name: "Third"}}
+
+func newTestHandlers(t *testing.T, chain tonnet.Chain) *ehandlers.Handlers {
+	senderWallet, err := tonnet.NewWallet(chain, wallet.NewSeed(), wallet.V3R2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Responses are sent through the queue, as in the watch command.
+	queue := tonnet.NewSendQueue(senderWallet, tonnet.QueueConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go queue.Run(ctx)
+	dir := t.TempDir()
+	config := ehandlers.Config{
+		Sender:          ehandlers.QueueSender{Queue: queue},
+		ContractAddress: testContract,
+		ResponseValue:   tlb.MustFromTON("0.025"),
+		Response: eresp.Config{
+			SignatureKeys: econf.KeysConfig{
+				PublicKeyPath:  filepath.Join(dir, "public.key"),
+				PrivateKeyPath: filepath.Join(dir, "private.key"),
+				SealedDatePath: filepath.Join(dir, "date.enc"),
+				Version:        "test",
+			},
+		},
+	}
+	handlers, err := ehandlers.New(config, testProjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers
+}
+
+// startWatch runs Watch over the fake chain and returns the function stopping it.
+func startWatch(t *testing.T, chain *tonnettest.FakeChain) func() error {
+	return startSupervisedWatch(t, chain, nil)
+}
+
+// startSupervisedWatch runs Watch with the subscription, the default one if nil.
+func startSupervisedWatch(t *testing.T, chain *tonnettest.FakeChain, subscription *tonnet.Subscription) func() error {
+	return runWatch(Config{
+		Chain:        chain,
+		Parser:       txparser.TransactionParser{TestNet: true, Address: testContract},
+		Handlers:     newTestHandlers(t, chain),
+		Subscription: subscription,
+	})
+}
+
+// runWatch runs Watch with the config and returns the function stopping it.
+func runWatch(cfg Config) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, cfg)
+	}()
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func TestWatchCommitReveal(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	// Transactions before the start are not handled.
+	chain.AddTransaction(testContract, "random()")
+	stop := startWatch(t, chain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Wait for the subscription to start before scripting new transactions.
+	if err := chain.WaitSubscribed(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	commitTx := chain.AddTransaction(testContract, "random()")
+	if err := chain.WaitSentMessages(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	revealTx := chain.AddTransaction(testContract, "reveal()")
+	if err := chain.WaitSentMessages(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected watch result: %v", err)
+	}
+
+	messages := chain.InternalMessages()
+	if len(messages) != 2 {
+		t.Fatalf("Unexpected messages count: %d", len(messages))
+	}
+	for _, msg := range messages {
+		if !bytes.Equal(msg.DstAddr.Data(), testContract.Data()) {
+			t.Errorf("Unexpected destination: %v", msg.DstAddr)
+		}
+	}
+
+	// RandomCommit: opcode, signature ref, timestamp, recipient, value hash ref, projects root.
+	commit := messages[0].Body.BeginParse()
+	if opcode := commit.MustLoadUInt(32); opcode != uint64(emessages.RandomCommit{}.GetOpcode()) {
+		t.Fatalf("Unexpected commit opcode: %x", opcode)
+	}
+	commit.MustLoadRef()
+	commitTimestamp := uint32(commit.MustLoadUInt(32))
+	commit.MustLoadAddr()
+	valueHash := commit.MustLoadRef().MustLoadSlice(256)
+	projectsRoot := commit.MustLoadSlice(256)
+	if !bytes.Equal(projectsRoot, testProjects.MerkleRoot()) {
+		t.Errorf("Unexpected projects root: %x", projectsRoot)
+	}
+	if commitTimestamp != commitTx.Now {
+		t.Errorf("Unexpected commit timestamp: %d", commitTimestamp)
+	}
+
+	// RandomReveal: opcode, signature ref, DoraID, name ref, timestamp, nonce, tx hash ref, weights hash, index.
+	reveal := messages[1].Body.BeginParse()
+	if opcode := reveal.MustLoadUInt(32); opcode != uint64(emessages.RandomReveal{}.GetOpcode()) {
+		t.Fatalf("Unexpected reveal opcode: %x", opcode)
+	}
+	reveal.MustLoadRef()
+	revealed := emessages.RevealedValue{
+		Timestamp: commitTimestamp,
+		Recipient: testContract,
+		DoraID:    reveal.MustLoadUInt(64),
+	}
+	revealed.Name = reveal.MustLoadRef().MustLoadStringSnake()
+	if timestamp := uint32(reveal.MustLoadUInt(32)); timestamp != revealTx.Now {
+		t.Errorf("Unexpected reveal timestamp: %d", timestamp)
+	}
+	revealed.Nonce = reveal.MustLoadUInt(64)
+	if txHash := reveal.MustLoadRef().MustLoadSlice(256); !bytes.Equal(txHash, revealTx.Hash) {
+		t.Errorf("Unexpected reveal tx hash: %x", txHash)
+	}
+	revealed.WeightsHash = reveal.MustLoadSlice(256)
+	if !bytes.Equal(revealed.WeightsHash, testProjects.WeightsHash()) {
+		t.Errorf("Unexpected weights hash: %x", revealed.WeightsHash)
+	}
+	revealed.ProjectsRoot = projectsRoot
+	revealed.Index = uint32(reveal.MustLoadUInt(32))
+
+	if !bytes.Equal(revealed.Hash(), valueHash) {
+		t.Errorf("Revealed value %+v does not match the commitment", revealed)
+	}
+
+	// The winner is included in the announced list at the revealed index.
+	proof, err := testProjects.Proof(int(revealed.Index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	winner := eprojects.Project{ID: int(revealed.DoraID), Name: revealed.Name, Weight: testProjects[revealed.Index].Weight}
+	if !eprojects.VerifyProof(projectsRoot, winner, proof) {
+		t.Errorf("Winner %+v is not included at index %d", winner, revealed.Index)
+	}
+}
+
+func TestWatchRandomRequest(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	stop := startWatch(t, chain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := chain.WaitSubscribed(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	requester := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	requestRange := big.NewInt(1000)
+	requestTx := chain.AddEventTransaction(testContract, cell.BeginCell().
+		MustStoreUInt(emessages.RandomRequestedOpcode, 32).
+		MustStoreAddr(requester).
+		MustStoreUInt(42, 64).
+		MustStoreBigUInt(requestRange, 256).
+		EndCell())
+	if err := chain.WaitSentMessages(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	chain.AddEventTransaction(testContract, cell.BeginCell().
+		MustStoreUInt(emessages.RandomCommittedOpcode, 32).
+		MustStoreAddr(requester).
+		MustStoreUInt(42, 64).
+		EndCell())
+	if err := chain.WaitSentMessages(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected watch result: %v", err)
+	}
+
+	messages := chain.InternalMessages()
+	if len(messages) != 2 {
+		t.Fatalf("Unexpected messages count: %d", len(messages))
+	}
+
+	// RandomRequestCommit: opcode, signature ref, timestamp, recipient, requester, query ID, value hash ref.
+	commit := messages[0].Body.BeginParse()
+	if opcode := commit.MustLoadUInt(32); opcode != uint64(emessages.RandomRequestCommit{}.GetOpcode()) {
+		t.Fatalf("Unexpected commit opcode: %x", opcode)
+	}
+	commit.MustLoadRef()
+	revealed := emessages.RevealedRandom{
+		Timestamp: uint32(commit.MustLoadUInt(32)),
+		Recipient: commit.MustLoadAddr(),
+		Requester: commit.MustLoadAddr(),
+		QueryID:   commit.MustLoadUInt(64),
+	}
+	valueHash := commit.MustLoadRef().MustLoadSlice(256)
+	if revealed.QueryID != 42 || !bytes.Equal(revealed.Requester.Data(), requester.Data()) {
+		t.Errorf("Unexpected commit: %+v", revealed)
+	}
+
+	// RandomRequestReveal: opcode, signature ref, requester, query ID, value, nonce, tx hash ref.
+	reveal := messages[1].Body.BeginParse()
+	if opcode := reveal.MustLoadUInt(32); opcode != uint64(emessages.RandomRequestReveal{}.GetOpcode()) {
+		t.Fatalf("Unexpected reveal opcode: %x", opcode)
+	}
+	reveal.MustLoadRef()
+	reveal.MustLoadAddr()
+	reveal.MustLoadUInt(64)
+	revealed.Value = reveal.MustLoadBigUInt(256)
+	revealed.Nonce = reveal.MustLoadUInt(64)
+	revealed.TxHash = reveal.MustLoadRef().MustLoadSlice(256)
+	if revealed.Value.Cmp(requestRange) >= 0 {
+		t.Errorf("Value %v is out of range", revealed.Value)
+	}
+	if !bytes.Equal(revealed.TxHash, requestTx.Hash) {
+		t.Errorf("Value is not bound to the request transaction: %x", revealed.TxHash)
+	}
+	if !bytes.Equal(revealed.Hash(), valueHash) {
+		t.Errorf("Revealed value %+v does not match the commitment", revealed)
+	}
+}
+
+func TestWatchRejectedResponse(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	stop := startWatch(t, chain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := chain.WaitSubscribed(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejected commit is logged, the watch goes on with the next transactions.
+	chain.SetResult(testContract, econtract.TactExitCode(econtract.ErrInvalidSignature.Error()))
+	chain.AddTransaction(testContract, "random()")
+	if err := chain.WaitSentMessages(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	chain.SetResult(testContract, 0)
+	chain.AddTransaction(testContract, "random()")
+	if err := chain.WaitSentMessages(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected watch result: %v", err)
+	}
+}
+
+func TestWatchResubscribes(t *testing.T) {
+	config := tonnet.SubscriptionConfig{
+		CheckInterval: 10 * time.Millisecond,
+		StallTimeout:  50 * time.Millisecond,
+		MinBackoff:    10 * time.Millisecond,
+	}
+	tests := []struct {
+		name    string
+		disrupt func(chain *tonnettest.FakeChain)
+	}{
+		{"Stopped", (*tonnettest.FakeChain).DropSubscriptions},
+		{"Stalled", (*tonnettest.FakeChain).StallSubscriptions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := tonnettest.NewFakeChain()
+			subscription := tonnet.NewSubscription(chain, testContract, config)
+			stop := startSupervisedWatch(t, chain, subscription)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := chain.WaitSubscribed(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+			commitTx := chain.AddTransaction(testContract, "random()")
+			if err := chain.WaitSentMessages(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			// The transaction added while the subscription is broken is handled after the restart, the handled one is not.
+			tt.disrupt(chain)
+			chain.AddTransaction(testContract, "reveal()")
+			if err := chain.WaitSentMessages(ctx, 2); err != nil {
+				t.Fatal(err)
+			}
+			if err := stop(); !errors.Is(err, context.Canceled) {
+				t.Errorf("Unexpected watch result: %v", err)
+			}
+			if sent := len(chain.SentMessages()); sent != 2 {
+				t.Errorf("Unexpected sent messages count: %d", sent)
+			}
+			liveness := subscription.Liveness()
+			if liveness.Restarts == 0 || liveness.LastLT <= commitTx.LT {
+				t.Errorf("Unexpected liveness: %+v", liveness)
+			}
+		})
+	}
+}
+
+func noSeal(data []byte, additionalData []byte) ([]byte, error) {
+	return data, nil
+}
+
+func TestWatchResumesAfterRestart(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	statePath := filepath.Join(t.TempDir(), "watch.enc")
+	start := func() func() error {
+		return runWatch(Config{
+			Chain:     chain,
+			Parser:    txparser.TransactionParser{TestNet: true, Address: testContract},
+			Handlers:  newTestHandlers(t, chain),
+			StatePath: statePath,
+			Sealer:    noSeal,
+			Unsealer:  noSeal,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stop := start()
+	if err := chain.WaitSubscribed(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	chain.AddTransaction(testContract, "random()")
+	if err := chain.WaitSentMessages(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected watch result: %v", err)
+	}
+
+	// The transaction added while the watch is down is handled after the restart, the handled one is not.
+	chain.AddTransaction(testContract, "random()")
+	stop = start()
+	if err := chain.WaitSentMessages(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected watch result: %v", err)
+	}
+	if sent := len(chain.SentMessages()); sent != 2 {
+		t.Errorf("Unexpected sent messages count: %d", sent)
+	}
+}
+
+// skippingHandlers fails the draw commits with the error and counts the reveals.
+type skippingHandlers struct {
+	*ehandlers.Handlers
+	err     error
+	reveals int
+}
+
+func (handlers *skippingHandlers) RandomCommit(tx *tlb.Transaction) error {
+	return handlers.err
+}
+
+func (handlers *skippingHandlers) Detected(at time.Time) {}
+
+func (handlers *skippingHandlers) RandomReveal(tx *tlb.Transaction) error {
+	handlers.reveals++
+	return nil
+}
+
+func TestHandleTransactionAfterSkippedCommand(t *testing.T) {
+	chain := tonnettest.NewFakeChain()
+	tx := chain.AddTransaction(testContract, "random()", "reveal()")
+	cfg := Config{Parser: txparser.TransactionParser{TestNet: true, Address: testContract}}
+
+	t.Run("Skipped", func(t *testing.T) {
+		handlers := &skippingHandlers{err: eprojects.ErrNotEnoughProjects}
+		cfg.Handlers = handlers
+		if err := handleTransaction(cfg, tx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if handlers.reveals != 1 {
+			t.Errorf("Reveal is not handled after the skipped commit")
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		failure := errors.New("failure")
+		handlers := &skippingHandlers{err: failure}
+		cfg.Handlers = handlers
+		if err := handleTransaction(cfg, tx); !errors.Is(err, failure) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if handlers.reveals != 0 {
+			t.Errorf("Reveal is handled after the failed commit")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package ewatch
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tonteeton/golib/ekeys"
+	"os"
+)
+
+// watchState is the progress of the watch, sealed after every handled transaction,
+// so the transactions received while the enclave is down are handled after the restart.
+type watchState struct {
+	// LastLT is the logical time of the last handled contract transaction.
+	LastLT uint64 `json:"lastLt"`
+}
+
+// loadState loads the sealed state, it is empty if the path is empty or the file doesn't exist.
+func loadState(cfg Config) (watchState, error) {
+	var state watchState
+	if cfg.StatePath == "" {
+		return state, nil
+	}
+	data, err := ekeys.ReadEncryptedFile(cfg.StatePath, []byte(cfg.SealVersion), sealers(cfg.Unsealer)...)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// saveState seals the state, it's not kept if the path is empty.
+func saveState(cfg Config, state watchState) error {
+	if cfg.StatePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ekeys.WriteEncryptedFile(cfg.StatePath, data, []byte(cfg.SealVersion), sealers(cfg.Sealer)...)
+}
+
+func sealers(sealer ekeys.DataSealer) []ekeys.DataSealer {
+	if sealer == nil {
+		return nil
+	}
+	return []ekeys.DataSealer{sealer}
+}
+
+// LastHandledLT returns the logical time of the last contract transaction handled by the watch with the config,
+// zero if the watch hasn't handled any yet. The running watch reports it as Subscription.Liveness().LastLT.
+func LastHandledLT(cfg Config) (uint64, error) {
+	state, err := loadState(cfg)
+	return state.LastLT, err
+}
@@ -3,16 +3,25 @@ module enclave
 go 1.21.8
 
 require (
+	github.com/edgelesssys/ego v1.5.3
+	github.com/oasisprotocol/curve25519-voi v0.0.0-20220328075252-7dd334e3daae
+	github.com/prometheus/client_golang v1.20.5
 	github.com/tonteeton/golib v1.1.3
 	github.com/xssnick/tonutils-go v1.9.8
+	golang.org/x/text v0.16.0
 )
 
 require (
-	github.com/edgelesssys/ego v1.5.3 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/go-jose/go-jose/v4 v4.0.2 // indirect
-	github.com/oasisprotocol/curve25519-voi v0.0.0-20220328075252-7dd334e3daae // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/sigurn/crc16 v0.0.0-20240131213347-83fcde1e29d1 // indirect
 	golang.org/x/crypto v0.24.0 // indirect
-	golang.org/x/sys v0.21.0 // indirect
-	google.golang.org/protobuf v1.34.1 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -3,98 +3,239 @@ package main
 import (
 	"context"
 	"enclave/appconf"
+	"enclave/ebalance"
+	"enclave/econtract"
+	"enclave/efees"
 	"enclave/ehandlers"
+	"enclave/elog"
+	"enclave/emetrics"
+	"enclave/epreflight"
+	"enclave/eprojects"
+	"enclave/estatus"
+	"enclave/ewatch"
+	"enclave/tonnet"
 	"enclave/txparser"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tonteeton/golib/eresp"
-	"github.com/xssnick/tonutils-go/liteclient"
-	"github.com/xssnick/tonutils-go/tlb"
-	"github.com/xssnick/tonutils-go/ton"
-	"github.com/xssnick/tonutils-go/ton/wallet"
-	"log"
+	"log/slog"
 	"os"
-	"slices"
+	"time"
 
+	"encoding/hex"
 	"errors"
 	"flag"
 	"github.com/tonteeton/golib/eattest"
 	"github.com/tonteeton/golib/ereport"
 )
 
-func watchTransactions(cfg *appconf.Config) error {
-	contractAddress := cfg.Network.ContractAddress
-
-	client := liteclient.NewConnectionPool()
-	clientCfg, err := liteclient.GetConfigFromUrl(context.Background(), cfg.Network.GlobalConfigURL)
-	if err != nil {
-		return err
-	}
-	err = client.AddConnectionsFromConfig(context.Background(), clientCfg)
-	if err != nil {
+func watchTransactions(cfg *appconf.Config, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Log responses and save them to "+appconf.DRY_RUN_PATH+" instead of sending, keep the state in memory")
+	if err := flags.Parse(args); err != nil {
 		return err
 	}
 
-	api := ton.NewAPIClient(client).WithRetry()
-	api.SetTrustedBlockFromConfig(clientCfg)
+	contractAddress := cfg.Network.ContractAddress
 
-	senderWallet, err := wallet.FromSeed(api, cfg.Wallet.Mnemonic, wallet.V3R2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chain, err := tonnet.Connect(ctx, cfg.Network.Connection)
 	if err != nil {
 		return err
 	}
-
-	log.Println("fetching and checking proofs since config init block...")
-	master, err := api.CurrentMasterchainInfo(context.Background())
-	if err != nil {
+	contract := econtract.Contract{Chain: chain, Address: contractAddress}
+	if err := preflight(cfg, contract); err != nil {
 		return err
 	}
 
-	acc, err := api.GetAccount(context.Background(), master, contractAddress)
-	if err != nil {
-		return err
+	var metrics *emetrics.Watch
+	if cfg.Metrics.Port != 0 {
+		registry := prometheus.NewRegistry()
+		metrics = emetrics.NewWatch(registry)
+		go func() {
+			if err := emetrics.Serve(ctx, cfg.Metrics.Port, registry); err != nil {
+				slog.Error("metrics are not served", "error", err)
+			}
+		}()
 	}
 
-	lastProcessedLT := acc.LastTxLT
-	transactions := make(chan *tlb.Transaction)
-	go api.SubscribeOnTransactions(context.Background(), contractAddress, lastProcessedLT, transactions)
+	fees := efees.NewEstimator(efees.Config{
+		Initial: cfg.Fees.ResponseValue,
+		Max:     cfg.Fees.MaxResponseValue,
+		Margin:  cfg.Fees.MarginPercent,
+	})
+	var sender ehandlers.Sender
+	if *dryRun {
+		slog.Info("dry run: responses are not sent")
+		sender = &ehandlers.DryRunSender{Dir: appconf.DRY_RUN_PATH}
+	} else {
+		senderWallet, err := tonnet.NewWallet(chain, cfg.Wallet.Mnemonic, cfg.Wallet.Version)
+		if err != nil {
+			return err
+		}
+		if err := fees.Seed(ctx, chain, contractAddress, senderWallet.Address()); err != nil {
+			slog.Warn("response values are not seeded from the contract history", "error", err)
+		}
+		balance := &ebalance.Monitor{
+			Chain:    chain,
+			Wallet:   senderWallet.Address(),
+			Contract: contract,
+			Fees:     fees,
+			Metrics:  metrics,
+			Config: ebalance.Config{
+				WalletWarning:   cfg.Balance.WalletWarning,
+				WalletCritical:  cfg.Balance.WalletCritical,
+				ContractWarning: cfg.Balance.ContractWarning,
+			},
+		}
+		if balances, err := balance.Check(ctx); err == nil || errors.Is(err, ebalance.ErrCriticalBalance) {
+			slog.Info("sender wallet", "address", senderWallet.Address().String(), "balance", balances.Wallet.String(),
+				"updates_left", balances.UpdatesLeft)
+		}
+		// The queue is the single sender of the wallet, the watch loop doesn't wait for the deliveries.
+		queue := tonnet.NewSendQueue(senderWallet, tonnet.QueueConfig{
+			ConfirmTimeout: cfg.Intervals.ConfirmTimeout,
+			Resubmits:      cfg.Wallet.Resubmits,
+		})
+		go queue.Run(ctx)
+		sender = ehandlers.QueueSender{Queue: queue, Fees: fees, Balance: balance}
+	}
 
+	// The dry run doesn't respond, so it keeps its state in memory and doesn't change the sealed files
+	// of a live instance on the mount: the commitments, the revealed projects and the watch resume point.
+	draw, statePath := cfg.Draw, cfg.Watch.StatePath
+	if *dryRun {
+		draw.PendingPath, draw.RevealedPath, statePath = "", "", ""
+	}
 	handlers, err := ehandlers.Init(
 		ehandlers.Config{
-			SenderWallet:    senderWallet,
+			Sender:          sender,
 			ContractAddress: contractAddress,
+			ResponseValue:   cfg.Fees.ResponseValue,
+			Fees:            fees,
+			Metrics:         metrics,
 			Response: eresp.Config{
 				Response:      cfg.Response,
 				SignatureKeys: cfg.SignatureKeys,
 			},
+			Winners:       draw.Winners,
+			ProjectsPath:  cfg.Projects.Path,
+			RevealedPath:  draw.RevealedPath,
+			PendingPath:   draw.PendingPath,
+			RevealTimeout: econtract.WaitRevealMaxPeriod * time.Second,
+			SealVersion:   appconf.APP_VERSION,
+			State:         contract,
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	txParser := txparser.TransactionParser{
-		TestNet: cfg.Network.TestNet,
-		Address: contractAddress,
+	subscription := tonnet.NewSubscription(chain, contractAddress, tonnet.SubscriptionConfig{
+		// The subscription is checked several times per period, to resume soon after the stall.
+		CheckInterval: cfg.Intervals.StallTimeout / 4,
+		StallTimeout:  cfg.Intervals.StallTimeout,
+	})
+	metrics.Subscription(subscription)
+
+	return ewatch.Watch(ctx, ewatch.Config{
+		Chain: chain,
+		Parser: txparser.TransactionParser{
+			TestNet: cfg.Network.TestNet,
+			Address: contractAddress,
+		},
+		Handlers: handlers,
+		// The reveal timeout is checked several times per period, to commit again soon after it.
+		RecommitInterval: econtract.WaitRevealMaxPeriod * time.Second / 4,
+		Subscription:     subscription,
+		Metrics:          metrics,
+		StatePath:        statePath,
+		SealVersion:      appconf.APP_VERSION,
+	})
+}
+
+// preflight refuses to start if the contract doesn't trust the running enclave,
+// otherwise every update would fail the contract signature check.
+func preflight(cfg *appconf.Config, contract econtract.Contract) error {
+	identity, err := epreflight.SelfIdentity(cfg.SignatureKeys, eattest.NewAttestation())
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	if err := epreflight.Check(context.Background(), contract, identity); err != nil {
+		return fmt.Errorf("preflight: contract %s doesn't trust this enclave: %w", contract.Address, err)
 	}
+	slog.Info("preflight: contract trusts the enclave",
+		"public_key", hex.EncodeToString(identity.PublicKey), "measurement", hex.EncodeToString(identity.UniqueID))
+	return nil
+}
 
-	log.Println("waiting for transactions...")
-	for tx := range transactions {
-		comments := txParser.ParseExternalComments(tx)
-		if slices.Contains(comments, "random()") {
-			log.Println("random() command detected")
-			err := handlers.RandomCommit(tx)
-			if err != nil {
-				return err
-			}
-		}
-		if slices.Contains(comments, "reveal()") {
-			log.Println("reveal() command detected")
-			err := handlers.RandomReveal(tx)
-			if err != nil {
-				return err
-			}
-		}
+// status prints the contract, sender wallet and enclave status, for humans or as JSON.
+func status(cfg *appconf.Config, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Print the status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	chain, err := tonnet.Connect(ctx, cfg.Network.Connection)
+	if err != nil {
+		return err
+	}
+	senderWallet, err := tonnet.NewWallet(chain, cfg.Wallet.Mnemonic, cfg.Wallet.Version)
+	if err != nil {
+		return err
 	}
 
+	report := estatus.Collect(ctx, estatus.Sources{
+		Contract:    econtract.Contract{Chain: chain, Address: cfg.Network.ContractAddress},
+		Wallet:      senderWallet.Address(),
+		Keys:        cfg.SignatureKeys,
+		Attestation: eattest.NewAttestation(),
+		LastHandledLT: func() (uint64, error) {
+			return ewatch.LastHandledLT(ewatch.Config{StatePath: cfg.Watch.StatePath, SealVersion: appconf.APP_VERSION})
+		},
+	})
+	if *jsonOutput {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteText(os.Stdout)
+}
+
+// importProjects verifies the project list signed by the contract owner and seals it to the mount.
+// The list Merkle root must be announced in the contract with AnnounceProjects.
+func importProjects(cfg *appconf.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: import-projects <signed projects file>")
+	}
+	signed, err := eprojects.LoadSignedProjects(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	chain, err := tonnet.Connect(ctx, cfg.Network.Connection)
+	if err != nil {
+		return err
+	}
+	contract := econtract.Contract{Chain: chain, Address: cfg.Network.ContractAddress}
+	ownerKey, err := contract.OwnerPublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	announcedRoot, err := contract.ProjectsRoot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := signed.Verify(ownerKey, announcedRoot); err != nil {
+		return err
+	}
+
+	if err := signed.Projects.Seal(cfg.Projects.Path, []byte(appconf.APP_VERSION)); err != nil {
+		return err
+	}
+	slog.Info("projects are imported, restart watch to use them", "projects", len(signed.Projects), "root", hex.EncodeToString(announcedRoot))
 	return nil
 }
 
@@ -114,21 +255,28 @@ func main() {
 		fmt.Println("Error loading config:", err)
 		os.Exit(1)
 	}
+	elog.Setup(os.Stderr, cfg.Log)
 
 	flag.Usage = func() {
 		fmt.Println("Usage: [command]")
 		fmt.Println("Commands:")
 		fmt.Println("  watch            Watch for incoming transactions and handle them")
+		fmt.Println("    --dry-run      Log responses and save them to the mount instead of sending")
+		fmt.Println("  status           Print the contract, sender wallet and enclave status")
+		fmt.Println("    --json         Print the status as JSON")
+		fmt.Println("  import-projects  Import the project list signed by the contract owner")
 		fmt.Println("  report-key       Generate SGX-signed report with public keys")
 		fmt.Println("  import-key       Import encrypted signature Private key")
 		fmt.Println("  export-key       Export encrypted signature Private key")
 	}
 
 	cmds := map[string]func(cfg *appconf.Config) error{
-		"watch":      watchTransactions,
-		"report-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPublicKeys, cfg) },
-		"import-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ImportPrivateSignature, cfg) },
-		"export-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPrivateSignature, cfg) },
+		"watch":           func(cfg *appconf.Config) error { return watchTransactions(cfg, os.Args[2:]) },
+		"status":          func(cfg *appconf.Config) error { return status(cfg, os.Args[2:]) },
+		"import-projects": func(cfg *appconf.Config) error { return importProjects(cfg, os.Args[2:]) },
+		"report-key":      func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPublicKeys, cfg) },
+		"import-key":      func(cfg *appconf.Config) error { return executeReportFunc(ereport.ImportPrivateSignature, cfg) },
+		"export-key":      func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPrivateSignature, cfg) },
 	}
 
 	if len(os.Args) < 2 {
@@ -145,7 +293,7 @@ func main() {
 	}
 
 	if err != nil {
-		fmt.Println("Error:", err)
+		slog.Error("command failed", "cmd", os.Args[1], "error", err)
 		os.Exit(1)
 	}
 }
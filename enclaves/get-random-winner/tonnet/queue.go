@@ -0,0 +1,235 @@
+package tonnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxBatchMessages is the number of messages the V3 and V4 wallets send in one external message.
+const maxBatchMessages = 4
+
+// ErrNotConfirmed is returned for the messages of the batch not confirmed after all resubmissions.
+var ErrNotConfirmed = errors.New("wallet transaction is not confirmed")
+
+// SendResult is the result of the queued message:
+// the wallet transaction sending it and the destination transaction processing it.
+type SendResult struct {
+	WalletTx *tlb.Transaction
+	Tx       *tlb.Transaction
+	Err      error
+}
+
+// QueueConfig holds the confirmation settings of the send queue.
+type QueueConfig struct {
+	ConfirmTimeout time.Duration // Wait for the wallet transaction before resubmitting the batch, 60s if not set.
+	Resubmits      int           // Resubmissions of the unconfirmed batch, it fails after the last one expires.
+}
+
+// SendQueue sends messages from the wallet in batches, it's the single owner of the wallet seqno:
+// one batch is in flight at a time, the next one is built from the messages queued meanwhile.
+// The destination transactions are traced concurrently, so they don't delay the next batch.
+type SendQueue struct {
+	wallet *Wallet
+	config QueueConfig
+
+	mu     sync.Mutex
+	queued []queuedMessage
+	wake   chan struct{}
+	seqno  *uint32 // the seqno of the next batch, fetched from the wallet if unknown
+}
+
+type queuedMessage struct {
+	msg    *wallet.Message
+	result chan SendResult
+}
+
+// NewSendQueue creates a new SendQueue of the wallet, Run sends the queued messages.
+func NewSendQueue(w *Wallet, config QueueConfig) *SendQueue {
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = 60 * time.Second
+	}
+	return &SendQueue{wallet: w, config: config, wake: make(chan struct{}, 1)}
+}
+
+// Wallet returns the wallet sending the messages.
+func (q *SendQueue) Wallet() *Wallet {
+	return q.wallet
+}
+
+// Submit queues the message without blocking, the result is received once from the returned channel.
+func (q *SendQueue) Submit(msg *wallet.Message) <-chan SendResult {
+	result := make(chan SendResult, 1)
+	q.mu.Lock()
+	q.queued = append(q.queued, queuedMessage{msg: msg, result: result})
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return result
+}
+
+// Run sends the queued messages until the context is done, then fails the messages left in the queue.
+func (q *SendQueue) Run(ctx context.Context) error {
+	for {
+		if batch := q.next(); len(batch) > 0 {
+			q.send(ctx, batch)
+			continue
+		}
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			for _, queued := range q.next() {
+				queued.result <- SendResult{Err: ctx.Err()}
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// next takes the next batch from the queue.
+func (q *SendQueue) next() []queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := min(len(q.queued), maxBatchMessages)
+	batch := q.queued[:n:n]
+	q.queued = q.queued[n:]
+	return batch
+}
+
+// send sends the batch and traces its messages, the results are reported to the submitters.
+func (q *SendQueue) send(ctx context.Context, batch []queuedMessage) {
+	messages := make([]*wallet.Message, 0, len(batch))
+	for _, queued := range batch {
+		messages = append(messages, queued.msg)
+	}
+	walletTx, err := q.sendBatch(ctx, messages)
+	if err != nil {
+		for _, queued := range batch {
+			queued.result <- SendResult{Err: err}
+		}
+		return
+	}
+	for _, queued := range batch {
+		go func(queued queuedMessage) {
+			out, err := outgoingMessage(walletTx, queued.msg.InternalMessage)
+			if err != nil {
+				queued.result <- SendResult{WalletTx: walletTx, Err: err}
+				return
+			}
+			tx, err := TraceMessage(ctx, q.wallet.chain, out, q.wallet.pollInterval)
+			queued.result <- SendResult{WalletTx: walletTx, Tx: tx, Err: err}
+		}(queued)
+	}
+}
+
+// sendBatch sends the messages with the next seqno and waits for the wallet transaction.
+// The unconfirmed batch is resubmitted with the same seqno, so the wallet processes it once.
+func (q *SendQueue) sendBatch(ctx context.Context, messages []*wallet.Message) (*tlb.Transaction, error) {
+	w := q.wallet
+	acc, err := w.chain.GetAccount(ctx, w.Address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account state: %w", err)
+	}
+	seqno, err := q.nextSeqno(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []*tlb.ExternalMessage
+	var expires time.Time
+	for attempt := 0; ; attempt++ {
+		ext, err := w.prepare(ctx, seqno, !acc.IsActive, messages)
+		if err != nil {
+			return nil, err
+		}
+		expires = time.Now().Add(w.messageTTL)
+		if err := w.chain.SendExternalMessage(ctx, ext); err != nil {
+			q.resetSeqno()
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		sent = append(sent, ext)
+
+		confirmCtx, cancel := context.WithTimeout(ctx, q.config.ConfirmTimeout)
+		tx, err := w.waitConfirmation(confirmCtx, acc, ext)
+		cancel()
+		if err == nil {
+			q.confirmSeqno(seqno)
+			return tx, nil
+		}
+		if ctx.Err() != nil {
+			q.resetSeqno()
+			return nil, err
+		}
+
+		current, err := w.seqno(ctx, 0)
+		if err != nil {
+			q.resetSeqno()
+			return nil, err
+		}
+		if current != seqno {
+			// An earlier submission may be processed after its confirmation timeout.
+			q.resetSeqno()
+			if tx := q.findSent(ctx, acc, sent); tx != nil {
+				return tx, nil
+			}
+			return nil, fmt.Errorf("%w: seqno %d is used by another message", ErrNotConfirmed, seqno)
+		}
+		if attempt >= q.config.Resubmits {
+			// The wallet can process a sent message until it expires, the outcome is known after that.
+			q.resetSeqno()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Until(expires) + w.pollInterval):
+			}
+			if tx := q.findSent(ctx, acc, sent); tx != nil {
+				return tx, nil
+			}
+			return nil, fmt.Errorf("%w: %d messages, %d resubmissions", ErrNotConfirmed, len(messages), attempt)
+		}
+		slog.Warn("wallet transaction is not confirmed, resubmitting", "seqno", seqno, "timeout", q.config.ConfirmTimeout)
+	}
+}
+
+// findSent returns the wallet transaction processing any of the sent messages of the batch, if any.
+func (q *SendQueue) findSent(ctx context.Context, acc Account, sent []*tlb.ExternalMessage) *tlb.Transaction {
+	w := q.wallet
+	for _, ext := range sent {
+		if tx := w.findTransaction(ctx, w.Address(), acc, ext.Body.Hash()); tx != nil {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (q *SendQueue) nextSeqno(ctx context.Context) (uint32, error) {
+	q.mu.Lock()
+	seqno := q.seqno
+	q.mu.Unlock()
+	if seqno != nil {
+		return *seqno, nil
+	}
+	return q.wallet.seqno(ctx, 0)
+}
+
+// confirmSeqno advances the seqno after the confirmed batch.
+func (q *SendQueue) confirmSeqno(seqno uint32) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	next := seqno + 1
+	q.seqno = &next
+}
+
+// resetSeqno makes the next batch fetch the wallet seqno, e.g. after an unknown outcome.
+func (q *SendQueue) resetSeqno() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seqno = nil
+}
@@ -0,0 +1,185 @@
+package tonnet
+
+import (
+	"context"
+	"enclave/elog"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SubscriptionConfig holds the supervision settings of the transaction subscription.
+type SubscriptionConfig struct {
+	CheckInterval time.Duration // Period of the stall checks, 30s if not set.
+	StallTimeout  time.Duration // The subscription is restarted if it lags behind the chain this long, 2m if not set.
+	MinBackoff    time.Duration // Delay of the first reconnection, doubled after each one without transactions, 1s if not set.
+	MaxBackoff    time.Duration // Maximum delay of the reconnection, 1m if not set.
+}
+
+// Liveness is the state of the supervised subscription.
+type Liveness struct {
+	Live          bool      `json:"live"`          // The subscription runs and keeps up with the chain.
+	LastLT        uint64    `json:"lastLT"`        // The last delivered transaction.
+	LastTxTime    time.Time `json:"lastTxTime"`    // The block time of the last delivered transaction.
+	MasterSeqno   uint32    `json:"masterSeqno"`   // The last seen masterchain block.
+	MasterUpdated time.Time `json:"masterUpdated"` // When the masterchain was seen advancing.
+	Restarts      int       `json:"restarts"`
+}
+
+// Subscription supervises Chain.SubscribeOnTransactions of the account. The subscription is restarted
+// from the last delivered transaction when it stops, e.g. the liteserver connection is lost, or stalls:
+// the account has newer transactions, or the masterchain doesn't advance, for the stall timeout.
+type Subscription struct {
+	chain  Chain
+	addr   *address.Address
+	config SubscriptionConfig
+
+	mu       sync.Mutex
+	liveness Liveness
+}
+
+// NewSubscription creates a new Subscription of the account transactions, Run starts it.
+func NewSubscription(chain Chain, addr *address.Address, config SubscriptionConfig) *Subscription {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30 * time.Second
+	}
+	if config.StallTimeout <= 0 {
+		config.StallTimeout = 2 * time.Minute
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = max(time.Minute, config.MinBackoff)
+	}
+	return &Subscription{chain: chain, addr: addr, config: config}
+}
+
+// Liveness returns the current state of the subscription, it's safe for concurrent use.
+func (s *Subscription) Liveness() Liveness {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.liveness
+}
+
+// Run sends the account transactions after lastProcessedLT to the channel, from old to new,
+// until the context is done, then closes the channel.
+func (s *Subscription) Run(ctx context.Context, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	defer close(channel)
+	s.update(func(l *Liveness) {
+		l.LastLT = lastProcessedLT
+		l.MasterUpdated = time.Now()
+	})
+
+	backoff := s.config.MinBackoff
+	for {
+		delivered, reason := s.subscribe(ctx, channel)
+		s.update(func(l *Liveness) { l.Live = false })
+		if ctx.Err() != nil {
+			return
+		}
+		if delivered {
+			backoff = s.config.MinBackoff
+		}
+		slog.Warn("transaction subscription is resumed", "reason", reason, elog.LT(s.Liveness().LastLT), "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if !delivered {
+			backoff = min(backoff*2, s.config.MaxBackoff)
+		}
+		s.update(func(l *Liveness) { l.Restarts++ })
+	}
+}
+
+// subscribe runs one subscription after the last delivered transaction until it stops or stalls.
+// It reports whether any transaction is delivered, and the reason of the stop.
+func (s *Subscription) subscribe(ctx context.Context, channel chan<- *tlb.Transaction) (bool, string) {
+	subCtx, cancel := context.WithCancel(ctx)
+	transactions := make(chan *tlb.Transaction)
+	go s.chain.SubscribeOnTransactions(subCtx, s.addr, s.Liveness().LastLT, transactions)
+	defer func() {
+		cancel()
+		// The subscription closes the channel when it returns.
+		for range transactions {
+		}
+	}()
+	s.update(func(l *Liveness) { l.Live = true })
+
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	delivered := false
+	var lagging time.Time // since when the account has undelivered transactions
+	for {
+		select {
+		case tx, ok := <-transactions:
+			if !ok {
+				return delivered, "stopped"
+			}
+			select {
+			case channel <- tx:
+			case <-ctx.Done():
+				return delivered, "cancelled"
+			}
+			delivered = true
+			lagging = time.Time{}
+			s.update(func(l *Liveness) {
+				l.LastLT = tx.LT
+				l.LastTxTime = time.Unix(int64(tx.Now), 0)
+			})
+		case <-ticker.C:
+			if reason := s.check(ctx, &lagging); reason != "" {
+				return delivered, reason
+			}
+		case <-ctx.Done():
+			return delivered, "cancelled"
+		}
+	}
+}
+
+// check returns the stall reason, if any: the account has undelivered transactions since lagging
+// or the masterchain doesn't advance for the stall timeout. Errors of the checks are logged only.
+func (s *Subscription) check(ctx context.Context, lagging *time.Time) string {
+	now := time.Now()
+	if seqno, err := s.chain.MasterchainSeqno(ctx); err == nil {
+		s.update(func(l *Liveness) {
+			if seqno != l.MasterSeqno {
+				l.MasterSeqno = seqno
+				l.MasterUpdated = now
+			}
+		})
+	} else {
+		slog.Warn("can't check the masterchain", "error", err)
+	}
+	liveness := s.Liveness()
+	if now.Sub(liveness.MasterUpdated) >= s.config.StallTimeout {
+		return "stalled: the masterchain doesn't advance"
+	}
+
+	acc, err := s.chain.GetAccount(ctx, s.addr)
+	if err != nil {
+		slog.Warn("can't check the account transactions", "error", err)
+		return ""
+	}
+	if acc.LastTxLT <= liveness.LastLT {
+		*lagging = time.Time{}
+		return ""
+	}
+	if lagging.IsZero() {
+		*lagging = now
+	}
+	if now.Sub(*lagging) >= s.config.StallTimeout {
+		return "stalled: newer account transactions are not delivered"
+	}
+	return ""
+}
+
+func (s *Subscription) update(apply func(l *Liveness)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apply(&s.liveness)
+}
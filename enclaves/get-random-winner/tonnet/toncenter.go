@@ -0,0 +1,499 @@
+package tonnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TONCENTER_MAINNET = "https://toncenter.com"
+	TONCENTER_TESTNET = "https://testnet.toncenter.com"
+)
+
+// TonCenter implements Chain over the toncenter v2 HTTP API.
+// Reference: https://toncenter.com/api/v2/
+type TonCenter struct {
+	baseURL      string
+	apiKey       string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+// NewTonCenter creates a new TonCenter instance, apiKey may be empty.
+func NewTonCenter(baseURL string, apiKey string) *TonCenter {
+	return &TonCenter{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       apiKey,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		pollInterval: 3 * time.Second,
+	}
+}
+
+// tonCenterResponse represents the common toncenter response envelope.
+type tonCenterResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+	Code   int             `json:"code"`
+}
+
+type tonCenterTxID struct {
+	LT   string `json:"lt"`
+	Hash string `json:"hash"`
+}
+
+type tonCenterAddressInfo struct {
+	Balance           string        `json:"balance"`
+	State             string        `json:"state"`
+	LastTransactionID tonCenterTxID `json:"last_transaction_id"`
+}
+
+type tonCenterGetMethodResult struct {
+	ExitCode int32             `json:"exit_code"`
+	Stack    []json.RawMessage `json:"stack"`
+}
+
+type tonCenterMasterchainInfo struct {
+	Last struct {
+		Seqno uint32 `json:"seqno"`
+	} `json:"last"`
+}
+
+type tonCenterTransaction struct {
+	Data string `json:"data"`
+}
+
+func (tc *TonCenter) GetAccount(ctx context.Context, addr *address.Address) (Account, error) {
+	var info tonCenterAddressInfo
+	query := url.Values{"address": {addr.String()}}
+	if err := tc.call(ctx, http.MethodGet, "getAddressInformation", query, nil, &info); err != nil {
+		return Account{}, err
+	}
+
+	balance, ok := new(big.Int).SetString(info.Balance, 10)
+	if !ok {
+		return Account{}, fmt.Errorf("invalid balance: %s", info.Balance)
+	}
+	account := Account{
+		IsActive: info.State == "active",
+		Balance:  tlb.FromNanoTON(balance),
+	}
+	if info.LastTransactionID.LT != "" && info.LastTransactionID.LT != "0" {
+		lt, err := strconv.ParseUint(info.LastTransactionID.LT, 10, 64)
+		if err != nil {
+			return Account{}, fmt.Errorf("invalid last transaction lt: %w", err)
+		}
+		hash, err := base64.StdEncoding.DecodeString(info.LastTransactionID.Hash)
+		if err != nil {
+			return Account{}, fmt.Errorf("invalid last transaction hash: %w", err)
+		}
+		account.LastTxLT = lt
+		account.LastTxHash = hash
+	}
+	return account, nil
+}
+
+func (tc *TonCenter) RunGetMethod(ctx context.Context, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	stack := make([][]string, 0, len(params))
+	for _, param := range params {
+		entry, err := encodeStackEntry(param)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, entry)
+	}
+	request := map[string]any{
+		"address": addr.String(),
+		"method":  method,
+		"stack":   stack,
+	}
+
+	var result tonCenterGetMethodResult
+	if err := tc.call(ctx, http.MethodPost, "runGetMethod", nil, request, &result); err != nil {
+		return nil, err
+	}
+	if err := getMethodError(result.ExitCode); err != nil {
+		return nil, err
+	}
+
+	values := make([]any, 0, len(result.Stack))
+	for _, entry := range result.Stack {
+		value, err := decodeStackEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return ton.NewExecutionResult(values), nil
+}
+
+func (tc *TonCenter) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	query := url.Values{
+		"address":  {addr.String()},
+		"limit":    {strconv.FormatUint(uint64(num), 10)},
+		"lt":       {strconv.FormatUint(lt, 10)},
+		"hash":     {base64.StdEncoding.EncodeToString(txHash)},
+		"archival": {"true"},
+	}
+	var list []tonCenterTransaction
+	if err := tc.call(ctx, http.MethodGet, "getTransactions", query, nil, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ton.ErrNoTransactionsWereFound
+	}
+
+	// toncenter returns transactions from new to old
+	transactions := make([]*tlb.Transaction, len(list))
+	for i, item := range list {
+		tx, err := decodeTransaction(item.Data)
+		if err != nil {
+			return nil, err
+		}
+		transactions[len(list)-1-i] = tx
+	}
+	return transactions, nil
+}
+
+func (tc *TonCenter) MasterchainSeqno(ctx context.Context) (uint32, error) {
+	var info tonCenterMasterchainInfo
+	if err := tc.call(ctx, http.MethodGet, "getMasterchainInfo", nil, nil, &info); err != nil {
+		return 0, err
+	}
+	return info.Last.Seqno, nil
+}
+
+func (tc *TonCenter) SubscribeOnTransactions(ctx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	pollTransactions(ctx, tc, tc.pollInterval, addr, lastProcessedLT, channel)
+}
+
+func (tc *TonCenter) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	msgCell, err := tlb.ToCell(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize external message: %w", err)
+	}
+	request := map[string]string{
+		"boc": base64.StdEncoding.EncodeToString(msgCell.ToBOCWithFlags(false)),
+	}
+	return tc.call(ctx, http.MethodPost, "sendBoc", nil, request, nil)
+}
+
+// call performs the API method request and decodes the result.
+func (tc *TonCenter) call(ctx context.Context, httpMethod string, method string, query url.Values, body any, result any) error {
+	status, data, err := doRequest(ctx, tc.client, httpMethod, tc.baseURL+"/api/v2/"+method, tc.apiKey, query, body)
+	if err != nil {
+		return err
+	}
+	var envelope tonCenterResponse
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("toncenter %s: unexpected response, status code %d", method, status)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("toncenter %s: %s (code %d)", method, envelope.Error, envelope.Code)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// doRequest sends the request with the JSON body, if any, and returns the status code and the response body.
+func doRequest(ctx context.Context, client *http.Client, httpMethod string, apiURL string, apiKey string, query url.Values, body any) (int, []byte, error) {
+	if len(query) > 0 {
+		apiURL += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, httpMethod, apiURL, reqBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// getMethodError returns the error of the get-method exit code, nil on success.
+func getMethodError(exitCode int32) error {
+	switch exitCode {
+	case 0, 1:
+		return nil
+	case -13:
+		// toncenter reports uninitialized accounts with the exit code -13, liteservers with -256.
+		return ton.ContractExecError{Code: ton.ErrCodeContractNotInitialized}
+	default:
+		return ton.ContractExecError{Code: exitCode}
+	}
+}
+
+// encodeStackEntry encodes the get-method parameter in the toncenter stack format.
+func encodeStackEntry(param any) ([]string, error) {
+	switch v := param.(type) {
+	case *big.Int:
+		return []string{"num", "0x" + v.Text(16)}, nil
+	case int:
+		return []string{"num", "0x" + big.NewInt(int64(v)).Text(16)}, nil
+	case uint64:
+		return []string{"num", "0x" + new(big.Int).SetUint64(v).Text(16)}, nil
+	case *cell.Cell:
+		return []string{"tvm.Cell", base64.StdEncoding.EncodeToString(v.ToBOC())}, nil
+	case *cell.Slice:
+		c, err := v.ToCell()
+		if err != nil {
+			return nil, err
+		}
+		return []string{"tvm.Slice", base64.StdEncoding.EncodeToString(c.ToBOC())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported get-method parameter type: %T", param)
+	}
+}
+
+// decodeStackEntry decodes the toncenter stack entry, e.g. ["num", "0x1"] or ["cell", {"bytes": "..."}],
+// to the types returned by liteservers: *big.Int, *cell.Cell, *cell.Slice, []any or nil.
+func decodeStackEntry(entry json.RawMessage) (any, error) {
+	var typed []json.RawMessage
+	if err := json.Unmarshal(entry, &typed); err != nil || len(typed) != 2 {
+		return nil, errors.New("invalid stack entry")
+	}
+	var entryType string
+	if err := json.Unmarshal(typed[0], &entryType); err != nil {
+		return nil, errors.New("invalid stack entry type")
+	}
+
+	switch entryType {
+	case "num":
+		var num string
+		if err := json.Unmarshal(typed[1], &num); err != nil {
+			return nil, errors.New("invalid number stack entry")
+		}
+		return parseNumber(num)
+	case "cell", "slice":
+		var value struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(typed[1], &value); err != nil {
+			return nil, errors.New("invalid cell stack entry")
+		}
+		c, err := decodeCell(value.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if entryType == "slice" {
+			return c.BeginParse(), nil
+		}
+		return c, nil
+	case "null":
+		return nil, nil
+	case "tuple", "list":
+		var value struct {
+			Elements []json.RawMessage `json:"elements"`
+		}
+		if err := json.Unmarshal(typed[1], &value); err != nil {
+			return nil, errors.New("invalid tuple stack entry")
+		}
+		tuple := make([]any, 0, len(value.Elements))
+		for _, element := range value.Elements {
+			v, err := decodeTupleElement(element)
+			if err != nil {
+				return nil, err
+			}
+			tuple = append(tuple, v)
+		}
+		return tuple, nil
+	default:
+		return nil, fmt.Errorf("unsupported stack entry type: %s", entryType)
+	}
+}
+
+// decodeTupleElement decodes the tuple element in the TL format, e.g. {"@type": "tvm.stackEntryNumber", ...}.
+func decodeTupleElement(element json.RawMessage) (any, error) {
+	var value struct {
+		Type   string `json:"@type"`
+		Number struct {
+			Number string `json:"number"`
+		} `json:"number"`
+		Cell struct {
+			Bytes string `json:"bytes"`
+		} `json:"cell"`
+		Slice struct {
+			Bytes string `json:"bytes"`
+		} `json:"slice"`
+		Tuple struct {
+			Elements []json.RawMessage `json:"elements"`
+		} `json:"tuple"`
+	}
+	if err := json.Unmarshal(element, &value); err != nil {
+		return nil, errors.New("invalid tuple element")
+	}
+
+	switch value.Type {
+	case "tvm.stackEntryNumber":
+		n, ok := new(big.Int).SetString(value.Number.Number, 10)
+		if !ok {
+			return nil, errors.New("invalid number tuple element")
+		}
+		return n, nil
+	case "tvm.stackEntryCell":
+		return decodeCell(value.Cell.Bytes)
+	case "tvm.stackEntrySlice":
+		c, err := decodeCell(value.Slice.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return c.BeginParse(), nil
+	case "tvm.stackEntryTuple", "tvm.stackEntryList":
+		tuple := make([]any, 0, len(value.Tuple.Elements))
+		for _, e := range value.Tuple.Elements {
+			v, err := decodeTupleElement(e)
+			if err != nil {
+				return nil, err
+			}
+			tuple = append(tuple, v)
+		}
+		return tuple, nil
+	case "tvm.stackEntryNull":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported tuple element type: %s", value.Type)
+	}
+}
+
+// parseNumber parses a hex number as returned by toncenter, e.g. "0x1f" or "-0x1f".
+func parseNumber(num string) (*big.Int, error) {
+	negative := strings.HasPrefix(num, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(num, "-"), "0x")
+	n, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", num)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return n, nil
+}
+
+func decodeCell(encoded string) (*cell.Cell, error) {
+	boc, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cell encoding: %w", err)
+	}
+	return cell.FromBOC(boc)
+}
+
+func decodeTransaction(encoded string) (*tlb.Transaction, error) {
+	txCell, err := decodeCell(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var tx tlb.Transaction
+	if err := tlb.LoadFromCell(&tx, txCell.BeginParse()); err != nil {
+		return nil, fmt.Errorf("failed to load transaction from cell: %w", err)
+	}
+	tx.Hash = txCell.Hash()
+	return &tx, nil
+}
+
+// Bounds of the delay between the retries of a failed transaction list request.
+const (
+	minPollRetry = 100 * time.Millisecond
+	maxPollRetry = time.Minute
+)
+
+// pollTransactions implements Chain.SubscribeOnTransactions by polling the account state.
+func pollTransactions(ctx context.Context, chain Chain, interval time.Duration, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	defer close(channel)
+
+	wait := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = interval
+
+		acc, err := chain.GetAccount(ctx, addr)
+		if err != nil || acc.LastTxLT == 0 || acc.LastTxLT == lastProcessedLT {
+			continue
+		}
+
+		// Collect new transactions from new to old, until the last processed one.
+		var transactions []*tlb.Transaction
+		lt, hash := acc.LastTxLT, acc.LastTxHash
+		retry := interval
+	list:
+		for lt > lastProcessedLT {
+			res, err := chain.ListTransactions(ctx, addr, 10, lt, hash)
+			if err != nil {
+				if errors.Is(err, ton.ErrNoTransactionsWereFound) {
+					break
+				}
+				// Retry the page with a growing delay, so a failing API is not hammered.
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retry):
+				}
+				retry = min(max(2*retry, minPollRetry), maxPollRetry)
+				continue
+			}
+			retry = interval
+			for i := len(res) - 1; i >= 0; i-- {
+				if res[i].LT <= lastProcessedLT {
+					break list
+				}
+				transactions = append(transactions, res[i])
+			}
+			lt, hash = res[0].PrevTxLT, res[0].PrevTxHash
+		}
+
+		if len(transactions) > 0 {
+			lastProcessedLT = transactions[0].LT
+			for i := len(transactions) - 1; i >= 0; i-- {
+				select {
+				case channel <- transactions[i]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			wait = 0
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package tonnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testAddress = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+
+func newTestTonCenter(t *testing.T, handler func(method string, r *http.Request) any) *TonCenter {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			t.Errorf("API key is not sent")
+		}
+		result := handler(r.URL.Path[len("/api/v2/"):], r)
+		if err, ok := result.(error); ok {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error(), "code": 500})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return NewTonCenter(server.URL+"/", "key")
+}
+
+func TestTonCenterGetAccount(t *testing.T) {
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		if method != "getAddressInformation" || r.URL.Query().Get("address") != testAddress.String() {
+			return errors.New("unexpected request")
+		}
+		return map[string]any{
+			"balance":             "1500000000",
+			"state":               "active",
+			"last_transaction_id": map[string]string{"lt": "42", "hash": base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		}
+	})
+
+	acc, err := tc.GetAccount(context.Background(), testAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !acc.IsActive || acc.Balance.String() != "1.5" || acc.LastTxLT != 42 || len(acc.LastTxHash) != 32 {
+		t.Errorf("Unexpected account: %+v", acc)
+	}
+}
+
+func TestTonCenterRunGetMethod(t *testing.T) {
+	payload := cell.BeginCell().MustStoreUInt(7, 8).EndCell()
+	encoded := base64.StdEncoding.EncodeToString(payload.ToBOC())
+
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		var request struct {
+			Method string     `json:"method"`
+			Stack  [][]string `json:"stack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return err
+		}
+		switch request.Method {
+		case "state":
+			if len(request.Stack) != 1 || request.Stack[0][1] != "0x5" {
+				return errors.New("unexpected stack")
+			}
+			return map[string]any{
+				"exit_code": 0,
+				"stack": []any{
+					[]any{"num", "-0x1f"},
+					[]any{"cell", map[string]string{"bytes": encoded}},
+					[]any{"slice", map[string]string{"bytes": encoded}},
+					[]any{"tuple", map[string]any{"elements": []any{
+						map[string]any{"@type": "tvm.stackEntryNumber", "number": map[string]string{"number": "12"}},
+					}}},
+				},
+			}
+		default:
+			return map[string]any{"exit_code": -13, "stack": []any{}}
+		}
+	})
+
+	res, err := tc.RunGetMethod(context.Background(), testAddress, "state", big.NewInt(5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := res.MustInt(0); n.Int64() != -31 {
+		t.Errorf("Unexpected number: %v", n)
+	}
+	if c := res.MustCell(1); c.BeginParse().MustLoadUInt(8) != 7 {
+		t.Errorf("Unexpected cell: %v", c)
+	}
+	if s := res.MustSlice(2); s.MustLoadUInt(8) != 7 {
+		t.Errorf("Unexpected slice: %v", s)
+	}
+	if tuple := res.MustTuple(3); len(tuple) != 1 || tuple[0].(*big.Int).Int64() != 12 {
+		t.Errorf("Unexpected tuple: %v", tuple)
+	}
+
+	_, err = tc.RunGetMethod(context.Background(), testAddress, "seqno")
+	var execErr ton.ContractExecError
+	if !errors.As(err, &execErr) || execErr.Code != ton.ErrCodeContractNotInitialized {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTonCenterMasterchainSeqno(t *testing.T) {
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		if method != "getMasterchainInfo" {
+			return errors.New("unexpected request")
+		}
+		return map[string]any{"last": map[string]any{"workchain": -1, "seqno": 38291046}}
+	})
+	seqno, err := tc.MasterchainSeqno(context.Background())
+	if err != nil || seqno != 38291046 {
+		t.Errorf("Unexpected seqno: %d, %v", seqno, err)
+	}
+}
+
+func TestTonCenterSendExternalMessage(t *testing.T) {
+	body := cell.BeginCell().MustStoreUInt(1, 32).EndCell()
+	var sent *cell.Cell
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		var request struct {
+			Boc string `json:"boc"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &request); err != nil {
+			return err
+		}
+		boc, err := base64.StdEncoding.DecodeString(request.Boc)
+		if err != nil {
+			return err
+		}
+		if sent, err = cell.FromBOC(boc); err != nil {
+			return err
+		}
+		return map[string]any{"@type": "ok"}
+	})
+
+	err := tc.SendExternalMessage(context.Background(), &tlb.ExternalMessage{DstAddr: testAddress, Body: body})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var msg tlb.ExternalMessage
+	if err := tlb.LoadFromCell(&msg, sent.BeginParse()); err != nil {
+		t.Fatalf("Unexpected message: %v", err)
+	}
+	if msg.DstAddr.String() != testAddress.String() || string(msg.Body.Hash()) != string(body.Hash()) {
+		t.Errorf("Unexpected message: %v", msg)
+	}
+}
+
+func TestTonCenterError(t *testing.T) {
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		return errors.New("rate limit exceeded")
+	})
+	if _, err := tc.GetAccount(context.Background(), testAddress); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+	if _, err := tc.ListTransactions(context.Background(), testAddress, 10, 1, make([]byte, 32)); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+}
+
+func TestPollTransactionsRetry(t *testing.T) {
+	var requests atomic.Int32
+	tc := newTestTonCenter(t, func(method string, r *http.Request) any {
+		if method == "getAddressInformation" {
+			return map[string]any{
+				"balance":             "1500000000",
+				"state":               "active",
+				"last_transaction_id": map[string]string{"lt": "42", "hash": base64.StdEncoding.EncodeToString(make([]byte, 32))},
+			}
+		}
+		requests.Add(1)
+		return errors.New("rate limit exceeded")
+	})
+	tc.pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	channel := make(chan *tlb.Transaction)
+	go tc.SubscribeOnTransactions(ctx, testAddress, 0, channel)
+	if _, ok := <-channel; ok {
+		t.Fatal("Unexpected transaction")
+	}
+	// The retries back off from the poll interval: 10, 20, 40, 80 and 160 ms fit into the timeout.
+	if n := requests.Load(); n < 2 || n > 8 {
+		t.Errorf("Unexpected number of requests: %d", n)
+	}
+}
@@ -0,0 +1,500 @@
+package tonnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TonCenterV3 implements Chain over the toncenter v3 HTTP API, served by the TON indexer.
+// The indexer returns transactions decoded to JSON instead of BOCs, so they are rebuilt
+// from the fields the enclave reads: the messages, the fees and the phases of ordinary transactions.
+// Reference: https://toncenter.com/api/v3/
+type TonCenterV3 struct {
+	baseURL      string
+	apiKey       string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+// NewTonCenterV3 creates a new TonCenterV3 instance, apiKey may be empty.
+func NewTonCenterV3(baseURL string, apiKey string) *TonCenterV3 {
+	return &TonCenterV3{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       apiKey,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		pollInterval: 3 * time.Second,
+	}
+}
+
+// tonCenterV3Error is the error response of the v3 API.
+type tonCenterV3Error struct {
+	Method     string
+	StatusCode int
+	Message    string
+}
+
+func (e tonCenterV3Error) Error() string {
+	return fmt.Sprintf("toncenter %s: %s (code %d)", e.Method, e.Message, e.StatusCode)
+}
+
+// uintString decodes the unsigned integer sent either as a number or as a string,
+// the indexer sends 64-bit values as strings.
+type uintString uint64
+
+func (u *uintString) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*u = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer: %s", data)
+	}
+	*u = uintString(v)
+	return nil
+}
+
+type tonCenterV3Account struct {
+	Balance             uintString `json:"balance"`
+	Status              string     `json:"status"`
+	LastTransactionLT   uintString `json:"last_transaction_lt"`
+	LastTransactionHash string     `json:"last_transaction_hash"`
+}
+
+type tonCenterV3StackEntry struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type tonCenterV3GetMethodResult struct {
+	ExitCode int32                   `json:"exit_code"`
+	Stack    []tonCenterV3StackEntry `json:"stack"`
+}
+
+type tonCenterV3Message struct {
+	Source         string     `json:"source"`
+	Destination    string     `json:"destination"`
+	Value          uintString `json:"value"`
+	FwdFee         uintString `json:"fwd_fee"`
+	IHRFee         uintString `json:"ihr_fee"`
+	CreatedLT      uintString `json:"created_lt"`
+	CreatedAt      uintString `json:"created_at"`
+	IHRDisabled    bool       `json:"ihr_disabled"`
+	Bounce         bool       `json:"bounce"`
+	Bounced        bool       `json:"bounced"`
+	MessageContent *struct {
+		Body string `json:"body"`
+	} `json:"message_content"`
+}
+
+type tonCenterV3ComputePhase struct {
+	Skipped          bool       `json:"skipped"`
+	Reason           string     `json:"reason"`
+	Success          bool       `json:"success"`
+	MsgStateUsed     bool       `json:"msg_state_used"`
+	AccountActivated bool       `json:"account_activated"`
+	GasFees          uintString `json:"gas_fees"`
+	GasUsed          uintString `json:"gas_used"`
+	GasLimit         uintString `json:"gas_limit"`
+	Mode             int8       `json:"mode"`
+	ExitCode         int32      `json:"exit_code"`
+	VMSteps          uint32     `json:"vm_steps"`
+}
+
+type tonCenterV3ActionPhase struct {
+	Success        bool   `json:"success"`
+	Valid          bool   `json:"valid"`
+	NoFunds        bool   `json:"no_funds"`
+	ResultCode     int32  `json:"result_code"`
+	TotActions     uint16 `json:"tot_actions"`
+	SpecActions    uint16 `json:"spec_actions"`
+	SkippedActions uint16 `json:"skipped_actions"`
+	MsgsCreated    uint16 `json:"msgs_created"`
+}
+
+type tonCenterV3Transaction struct {
+	Account       string     `json:"account"`
+	Hash          string     `json:"hash"`
+	LT            uintString `json:"lt"`
+	Now           uint32     `json:"now"`
+	PrevTransHash string     `json:"prev_trans_hash"`
+	PrevTransLT   uintString `json:"prev_trans_lt"`
+	TotalFees     uintString `json:"total_fees"`
+	Description   struct {
+		Type        string                  `json:"type"`
+		Aborted     bool                    `json:"aborted"`
+		Destroyed   bool                    `json:"destroyed"`
+		CreditFirst bool                    `json:"credit_first"`
+		ComputePh   tonCenterV3ComputePhase `json:"compute_ph"`
+		Action      *tonCenterV3ActionPhase `json:"action"`
+	} `json:"description"`
+	InMsg   *tonCenterV3Message  `json:"in_msg"`
+	OutMsgs []tonCenterV3Message `json:"out_msgs"`
+}
+
+func (tc *TonCenterV3) GetAccount(ctx context.Context, addr *address.Address) (Account, error) {
+	var info tonCenterV3Account
+	query := url.Values{"address": {addr.String()}}
+	if err := tc.call(ctx, http.MethodGet, "account", query, nil, &info); err != nil {
+		var apiErr tonCenterV3Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// The indexer doesn't know accounts without transactions.
+			return Account{Balance: tlb.ZeroCoins}, nil
+		}
+		return Account{}, err
+	}
+
+	account := Account{
+		IsActive: info.Status == "active",
+		Balance:  tlb.FromNanoTONU(uint64(info.Balance)),
+	}
+	if info.LastTransactionLT != 0 {
+		hash, err := base64.StdEncoding.DecodeString(info.LastTransactionHash)
+		if err != nil {
+			return Account{}, fmt.Errorf("invalid last transaction hash: %w", err)
+		}
+		account.LastTxLT = uint64(info.LastTransactionLT)
+		account.LastTxHash = hash
+	}
+	return account, nil
+}
+
+func (tc *TonCenterV3) RunGetMethod(ctx context.Context, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	stack := make([]tonCenterV3StackEntry, 0, len(params))
+	for _, param := range params {
+		entry, err := encodeStackEntryV3(param)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, entry)
+	}
+	request := map[string]any{
+		"address": addr.String(),
+		"method":  method,
+		"stack":   stack,
+	}
+
+	var result tonCenterV3GetMethodResult
+	if err := tc.call(ctx, http.MethodPost, "runGetMethod", nil, request, &result); err != nil {
+		return nil, err
+	}
+	if err := getMethodError(result.ExitCode); err != nil {
+		return nil, err
+	}
+
+	values := make([]any, 0, len(result.Stack))
+	for _, entry := range result.Stack {
+		value, err := decodeStackEntryV3(entry)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return ton.NewExecutionResult(values), nil
+}
+
+func (tc *TonCenterV3) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	query := url.Values{
+		"account": {addr.String()},
+		"limit":   {strconv.FormatUint(uint64(num), 10)},
+		"end_lt":  {strconv.FormatUint(lt, 10)},
+		"sort":    {"desc"},
+	}
+	var result struct {
+		Transactions []tonCenterV3Transaction `json:"transactions"`
+	}
+	if err := tc.call(ctx, http.MethodGet, "transactions", query, nil, &result); err != nil {
+		return nil, err
+	}
+	list := result.Transactions
+	if len(list) == 0 {
+		return nil, ton.ErrNoTransactionsWereFound
+	}
+
+	// The transactions are requested from new to old
+	transactions := make([]*tlb.Transaction, len(list))
+	for i, item := range list {
+		tx, err := item.decode()
+		if err != nil {
+			return nil, err
+		}
+		transactions[len(list)-1-i] = tx
+	}
+	return transactions, nil
+}
+
+func (tc *TonCenterV3) MasterchainSeqno(ctx context.Context) (uint32, error) {
+	var info tonCenterMasterchainInfo
+	if err := tc.call(ctx, http.MethodGet, "masterchainInfo", nil, nil, &info); err != nil {
+		return 0, err
+	}
+	return info.Last.Seqno, nil
+}
+
+func (tc *TonCenterV3) SubscribeOnTransactions(ctx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	pollTransactions(ctx, tc, tc.pollInterval, addr, lastProcessedLT, channel)
+}
+
+func (tc *TonCenterV3) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	msgCell, err := tlb.ToCell(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize external message: %w", err)
+	}
+	request := map[string]string{
+		"boc": base64.StdEncoding.EncodeToString(msgCell.ToBOCWithFlags(false)),
+	}
+	return tc.call(ctx, http.MethodPost, "message", nil, request, nil)
+}
+
+// call performs the API method request and decodes the result.
+func (tc *TonCenterV3) call(ctx context.Context, httpMethod string, method string, query url.Values, body any, result any) error {
+	status, data, err := doRequest(ctx, tc.client, httpMethod, tc.baseURL+"/api/v3/"+method, tc.apiKey, query, body)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		var response struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil || response.Error == "" {
+			response.Error = "unexpected response"
+		}
+		return tonCenterV3Error{Method: method, StatusCode: status, Message: response.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// encodeStackEntryV3 encodes the get-method parameter in the v3 stack format, e.g. {"type": "num", "value": "0x1"}.
+func encodeStackEntryV3(param any) (tonCenterV3StackEntry, error) {
+	entry, err := encodeStackEntry(param)
+	if err != nil {
+		return tonCenterV3StackEntry{}, err
+	}
+	entryType := map[string]string{"num": "num", "tvm.Cell": "cell", "tvm.Slice": "slice"}[entry[0]]
+	value, err := json.Marshal(entry[1])
+	if err != nil {
+		return tonCenterV3StackEntry{}, err
+	}
+	return tonCenterV3StackEntry{Type: entryType, Value: value}, nil
+}
+
+// decodeStackEntryV3 decodes the v3 stack entry to the types returned by liteservers,
+// as decodeStackEntry does for the v2 one.
+func decodeStackEntryV3(entry tonCenterV3StackEntry) (any, error) {
+	switch entry.Type {
+	case "num":
+		var num string
+		if err := json.Unmarshal(entry.Value, &num); err != nil {
+			return nil, errors.New("invalid number stack entry")
+		}
+		return parseNumber(num)
+	case "cell", "slice":
+		var encoded string
+		if err := json.Unmarshal(entry.Value, &encoded); err != nil {
+			return nil, errors.New("invalid cell stack entry")
+		}
+		c, err := decodeCell(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type == "slice" {
+			return c.BeginParse(), nil
+		}
+		return c, nil
+	case "null":
+		return nil, nil
+	case "tuple", "list":
+		var elements []tonCenterV3StackEntry
+		if err := json.Unmarshal(entry.Value, &elements); err != nil {
+			return nil, errors.New("invalid tuple stack entry")
+		}
+		tuple := make([]any, 0, len(elements))
+		for _, element := range elements {
+			v, err := decodeStackEntryV3(element)
+			if err != nil {
+				return nil, err
+			}
+			tuple = append(tuple, v)
+		}
+		return tuple, nil
+	default:
+		return nil, fmt.Errorf("unsupported stack entry type: %s", entry.Type)
+	}
+}
+
+// decode rebuilds the transaction, the state update and the storage and credit phases are not set.
+func (item tonCenterV3Transaction) decode() (*tlb.Transaction, error) {
+	account, err := parseAddressV3(item.Account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction account: %w", err)
+	}
+	hash, err := base64.StdEncoding.DecodeString(item.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hash: %w", err)
+	}
+	prevHash, err := base64.StdEncoding.DecodeString(item.PrevTransHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid previous transaction hash: %w", err)
+	}
+
+	tx := &tlb.Transaction{
+		AccountAddr: account.Data(),
+		LT:          uint64(item.LT),
+		PrevTxHash:  prevHash,
+		PrevTxLT:    uint64(item.PrevTransLT),
+		Now:         item.Now,
+		OutMsgCount: uint16(len(item.OutMsgs)),
+		Hash:        hash,
+	}
+	tx.TotalFees.Coins = tlb.FromNanoTONU(uint64(item.TotalFees))
+	if item.InMsg != nil {
+		msgCell, err := item.InMsg.toCell()
+		if err != nil {
+			return nil, fmt.Errorf("invalid inbound message: %w", err)
+		}
+		var msg tlb.Message
+		if err := msg.LoadFromCell(msgCell.BeginParse()); err != nil {
+			return nil, fmt.Errorf("invalid inbound message: %w", err)
+		}
+		tx.IO.In = &msg
+	}
+	if len(item.OutMsgs) > 0 {
+		dict := cell.NewDict(15)
+		for i, out := range item.OutMsgs {
+			msgCell, err := out.toCell()
+			if err != nil {
+				return nil, fmt.Errorf("invalid outbound message %d: %w", i, err)
+			}
+			if err := dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreRef(msgCell).EndCell()); err != nil {
+				return nil, err
+			}
+		}
+		tx.IO.Out = &tlb.MessagesList{List: dict}
+	}
+	if item.Description.Type == "ord" {
+		tx.Description.Description = item.ordinaryDescription()
+	}
+	return tx, nil
+}
+
+func (item tonCenterV3Transaction) ordinaryDescription() tlb.TransactionDescriptionOrdinary {
+	description := tlb.TransactionDescriptionOrdinary{
+		CreditFirst: item.Description.CreditFirst,
+		Aborted:     item.Description.Aborted,
+		Destroyed:   item.Description.Destroyed,
+	}
+	compute := item.Description.ComputePh
+	if compute.Skipped {
+		description.ComputePhase.Phase = tlb.ComputePhaseSkipped{
+			Reason: tlb.ComputeSkipReason{Type: tlb.ComputeSkipReasonType(strings.ToUpper(compute.Reason))},
+		}
+	} else {
+		vm := tlb.ComputePhaseVM{
+			Success:          compute.Success,
+			MsgStateUsed:     compute.MsgStateUsed,
+			AccountActivated: compute.AccountActivated,
+			GasFees:          tlb.FromNanoTONU(uint64(compute.GasFees)),
+		}
+		vm.Details.GasUsed = new(big.Int).SetUint64(uint64(compute.GasUsed))
+		vm.Details.GasLimit = new(big.Int).SetUint64(uint64(compute.GasLimit))
+		vm.Details.Mode = compute.Mode
+		vm.Details.ExitCode = compute.ExitCode
+		vm.Details.VMSteps = compute.VMSteps
+		description.ComputePhase.Phase = vm
+	}
+	if action := item.Description.Action; action != nil {
+		description.ActionPhase = &tlb.ActionPhase{
+			Success:         action.Success,
+			Valid:           action.Valid,
+			NoFunds:         action.NoFunds,
+			ResultCode:      action.ResultCode,
+			TotalActions:    action.TotActions,
+			SpecActions:     action.SpecActions,
+			SkippedActions:  action.SkippedActions,
+			MessagesCreated: action.MsgsCreated,
+		}
+	}
+	return description
+}
+
+// toCell serializes the message, so it's loaded the same way as the messages of the transaction BOCs.
+// Messages without the source are external inbound ones, without the destination external outbound ones.
+func (m tonCenterV3Message) toCell() (*cell.Cell, error) {
+	body := cell.BeginCell().EndCell()
+	if m.MessageContent != nil && m.MessageContent.Body != "" {
+		var err error
+		if body, err = decodeCell(m.MessageContent.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg any
+	switch {
+	case m.Source == "":
+		dst, err := parseAddressV3(m.Destination)
+		if err != nil {
+			return nil, err
+		}
+		msg = &tlb.ExternalMessage{SrcAddr: address.NewAddressNone(), DstAddr: dst, Body: body}
+	case m.Destination == "":
+		src, err := parseAddressV3(m.Source)
+		if err != nil {
+			return nil, err
+		}
+		msg = &tlb.ExternalMessageOut{
+			SrcAddr:   src,
+			DstAddr:   address.NewAddressNone(),
+			CreatedLT: uint64(m.CreatedLT),
+			CreatedAt: uint32(m.CreatedAt),
+			Body:      body,
+		}
+	default:
+		src, err := parseAddressV3(m.Source)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := parseAddressV3(m.Destination)
+		if err != nil {
+			return nil, err
+		}
+		msg = &tlb.InternalMessage{
+			IHRDisabled: m.IHRDisabled,
+			Bounce:      m.Bounce,
+			Bounced:     m.Bounced,
+			SrcAddr:     src,
+			DstAddr:     dst,
+			Amount:      tlb.FromNanoTONU(uint64(m.Value)),
+			IHRFee:      tlb.FromNanoTONU(uint64(m.IHRFee)),
+			FwdFee:      tlb.FromNanoTONU(uint64(m.FwdFee)),
+			CreatedLT:   uint64(m.CreatedLT),
+			CreatedAt:   uint32(m.CreatedAt),
+			Body:        body,
+		}
+	}
+	return tlb.ToCell(msg)
+}
+
+// parseAddressV3 parses the address in the raw form the indexer uses, e.g. "0:ABCD...", or in the user-friendly one.
+func parseAddressV3(addr string) (*address.Address, error) {
+	if strings.Contains(addr, ":") {
+		return address.ParseRawAddr(addr)
+	}
+	return address.ParseAddr(addr)
+}
@@ -0,0 +1,309 @@
+package tonnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testRawAddress is testAddress in the raw form used by the indexer.
+const testRawAddress = "0:ED1691307050047117B998B561D8DE82D31FBF84910CED6EB5FC92E7485EF8A7"
+
+var testOther = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+
+func newTestTonCenterV3(t *testing.T, handler func(method string, r *http.Request) any) *TonCenterV3 {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			t.Errorf("API key is not sent")
+		}
+		result := handler(r.URL.Path[len("/api/v3/"):], r)
+		if err, ok := result.(error); ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return NewTonCenterV3(server.URL+"/", "key")
+}
+
+func TestTonCenterV3GetAccount(t *testing.T) {
+	tc := newTestTonCenterV3(t, func(method string, r *http.Request) any {
+		if method != "account" {
+			return errors.New("unexpected request")
+		}
+		if r.URL.Query().Get("address") != testAddress.String() {
+			return errors.New("account not found")
+		}
+		return map[string]any{
+			"balance":               "1500000000",
+			"status":                "active",
+			"last_transaction_lt":   "42",
+			"last_transaction_hash": base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		}
+	})
+
+	acc, err := tc.GetAccount(context.Background(), testAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !acc.IsActive || acc.Balance.String() != "1.5" || acc.LastTxLT != 42 || len(acc.LastTxHash) != 32 {
+		t.Errorf("Unexpected account: %+v", acc)
+	}
+
+	t.Run("Unknown account", func(t *testing.T) {
+		acc, err := tc.GetAccount(context.Background(), testOther)
+		if err != nil || acc.IsActive || acc.LastTxLT != 0 {
+			t.Errorf("Unexpected account: %+v, %v", acc, err)
+		}
+	})
+}
+
+func TestTonCenterV3RunGetMethod(t *testing.T) {
+	payload := cell.BeginCell().MustStoreUInt(7, 8).EndCell()
+	encoded := base64.StdEncoding.EncodeToString(payload.ToBOC())
+
+	tc := newTestTonCenterV3(t, func(method string, r *http.Request) any {
+		var request struct {
+			Method string                  `json:"method"`
+			Stack  []tonCenterV3StackEntry `json:"stack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return err
+		}
+		switch request.Method {
+		case "state":
+			if len(request.Stack) != 1 || request.Stack[0].Type != "num" || string(request.Stack[0].Value) != `"0x5"` {
+				return errors.New("unexpected stack")
+			}
+			return map[string]any{
+				"exit_code": 0,
+				"stack": []any{
+					map[string]any{"type": "num", "value": "-0x1f"},
+					map[string]any{"type": "cell", "value": encoded},
+					map[string]any{"type": "slice", "value": encoded},
+					map[string]any{"type": "tuple", "value": []any{
+						map[string]any{"type": "num", "value": "0xc"},
+						map[string]any{"type": "null"},
+					}},
+				},
+			}
+		default:
+			return map[string]any{"exit_code": -13, "stack": []any{}}
+		}
+	})
+
+	res, err := tc.RunGetMethod(context.Background(), testAddress, "state", big.NewInt(5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := res.MustInt(0); n.Int64() != -31 {
+		t.Errorf("Unexpected number: %v", n)
+	}
+	if c := res.MustCell(1); c.BeginParse().MustLoadUInt(8) != 7 {
+		t.Errorf("Unexpected cell: %v", c)
+	}
+	if s := res.MustSlice(2); s.MustLoadUInt(8) != 7 {
+		t.Errorf("Unexpected slice: %v", s)
+	}
+	if tuple := res.MustTuple(3); len(tuple) != 2 || tuple[0].(*big.Int).Int64() != 12 || tuple[1] != nil {
+		t.Errorf("Unexpected tuple: %v", tuple)
+	}
+
+	_, err = tc.RunGetMethod(context.Background(), testAddress, "seqno")
+	var execErr ton.ContractExecError
+	if !errors.As(err, &execErr) || execErr.Code != ton.ErrCodeContractNotInitialized {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTonCenterV3ListTransactions(t *testing.T) {
+	inBody := cell.BeginCell().MustStoreUInt(0xbb15fe7d, 32).EndCell()
+	outBody := cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("random()").EndCell()
+	hash := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(append(make([]byte, 31), b))
+	}
+	tc := newTestTonCenterV3(t, func(method string, r *http.Request) any {
+		query := r.URL.Query()
+		if method != "transactions" || query.Get("account") != testAddress.String() || query.Get("sort") != "desc" {
+			return errors.New("unexpected request")
+		}
+		if query.Get("end_lt") != "20" || query.Get("limit") != "2" {
+			return map[string]any{"transactions": []any{}}
+		}
+		return map[string]any{"transactions": []any{
+			map[string]any{
+				"account":         testRawAddress,
+				"hash":            hash(2),
+				"lt":              "20",
+				"now":             1700000020,
+				"prev_trans_hash": hash(1),
+				"prev_trans_lt":   "10",
+				"total_fees":      "2500000",
+				"description": map[string]any{
+					"type":       "ord",
+					"compute_ph": map[string]any{"skipped": false, "success": true, "exit_code": 0, "gas_used": "3308"},
+					"action":     map[string]any{"success": true, "valid": true, "result_code": 0, "tot_actions": 2},
+				},
+				"in_msg": map[string]any{
+					"source":          testOther.String(),
+					"destination":     testRawAddress,
+					"value":           "100000000",
+					"created_lt":      "19",
+					"bounce":          true,
+					"message_content": map[string]any{"body": base64.StdEncoding.EncodeToString(inBody.ToBOC())},
+				},
+				"out_msgs": []any{
+					map[string]any{
+						"source":          testRawAddress,
+						"destination":     nil,
+						"message_content": map[string]any{"body": base64.StdEncoding.EncodeToString(outBody.ToBOC())},
+					},
+					map[string]any{"source": testRawAddress, "destination": testOther.String(), "value": "50000000"},
+				},
+			},
+			map[string]any{
+				"account":         testRawAddress,
+				"hash":            hash(1),
+				"lt":              "10",
+				"now":             1700000010,
+				"prev_trans_hash": hash(0),
+				"prev_trans_lt":   "0",
+				"description": map[string]any{
+					"type":       "ord",
+					"compute_ph": map[string]any{"skipped": true, "reason": "no_state"},
+				},
+				"in_msg":   map[string]any{"source": nil, "destination": testRawAddress},
+				"out_msgs": []any{},
+			},
+		}}
+	})
+
+	transactions, err := tc.ListTransactions(context.Background(), testAddress, 2, 20, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(transactions) != 2 || transactions[0].LT != 10 || transactions[1].LT != 20 {
+		t.Fatalf("Unexpected transactions: %v", transactions)
+	}
+
+	tx := transactions[1]
+	if tx.Hash[31] != 2 || tx.PrevTxLT != 10 || tx.PrevTxHash[31] != 1 || tx.Now != 1700000020 ||
+		tx.TotalFees.Coins.String() != "0.0025" || string(tx.AccountAddr) != string(testAddress.Data()) {
+		t.Errorf("Unexpected transaction: %+v", tx)
+	}
+	description, ok := tx.Description.Description.(tlb.TransactionDescriptionOrdinary)
+	if !ok {
+		t.Fatalf("Unexpected description: %v", tx.Description.Description)
+	}
+	compute, ok := description.ComputePhase.Phase.(tlb.ComputePhaseVM)
+	if !ok || !compute.Success || compute.Details.ExitCode != 0 || compute.Details.GasUsed.Int64() != 3308 {
+		t.Errorf("Unexpected compute phase: %+v", description.ComputePhase.Phase)
+	}
+	if description.ActionPhase == nil || !description.ActionPhase.Success || description.ActionPhase.TotalActions != 2 {
+		t.Errorf("Unexpected action phase: %+v", description.ActionPhase)
+	}
+
+	in := tx.IO.In.AsInternal()
+	if in.SrcAddr.String() != testOther.String() || in.DstAddr.String() != testAddress.String() ||
+		in.Amount.String() != "0.1" || in.CreatedLT != 19 || string(in.Body.Hash()) != string(inBody.Hash()) {
+		t.Errorf("Unexpected inbound message: %v", in)
+	}
+	out, err := tx.IO.Out.ToSlice()
+	if err != nil || len(out) != 2 {
+		t.Fatalf("Unexpected outbound messages: %v, %v", out, err)
+	}
+	if ext := out[0].AsExternalOut(); ext.SrcAddr.String() != testAddress.String() || !ext.DstAddr.IsAddrNone() ||
+		string(ext.Body.Hash()) != string(outBody.Hash()) {
+		t.Errorf("Unexpected external message: %v", ext)
+	}
+	if internal := out[1].AsInternal(); internal.DstAddr.String() != testOther.String() || internal.Amount.String() != "0.05" {
+		t.Errorf("Unexpected internal message: %v", internal)
+	}
+
+	tx = transactions[0]
+	if tx.IO.In.MsgType != tlb.MsgTypeExternalIn || tx.IO.Out != nil {
+		t.Errorf("Unexpected messages: %v", tx.IO)
+	}
+	description = tx.Description.Description.(tlb.TransactionDescriptionOrdinary)
+	if skipped, ok := description.ComputePhase.Phase.(tlb.ComputePhaseSkipped); !ok || skipped.Reason.Type != tlb.ComputeSkipReasonNoState {
+		t.Errorf("Unexpected compute phase: %+v", description.ComputePhase.Phase)
+	}
+
+	_, err = tc.ListTransactions(context.Background(), testAddress, 10, 5, make([]byte, 32))
+	if !errors.Is(err, ton.ErrNoTransactionsWereFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTonCenterV3MasterchainSeqno(t *testing.T) {
+	tc := newTestTonCenterV3(t, func(method string, r *http.Request) any {
+		if method != "masterchainInfo" {
+			return errors.New("unexpected request")
+		}
+		return map[string]any{"first": map[string]any{"seqno": 1}, "last": map[string]any{"workchain": -1, "seqno": 38291046}}
+	})
+	seqno, err := tc.MasterchainSeqno(context.Background())
+	if err != nil || seqno != 38291046 {
+		t.Errorf("Unexpected seqno: %d, %v", seqno, err)
+	}
+}
+
+func TestTonCenterV3SendExternalMessage(t *testing.T) {
+	body := cell.BeginCell().MustStoreUInt(1, 32).EndCell()
+	var sent *cell.Cell
+	tc := newTestTonCenterV3(t, func(method string, r *http.Request) any {
+		var request struct {
+			Boc string `json:"boc"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || method != "message" {
+			return errors.New("unexpected request")
+		}
+		boc, err := base64.StdEncoding.DecodeString(request.Boc)
+		if err != nil {
+			return err
+		}
+		if sent, err = cell.FromBOC(boc); err != nil {
+			return err
+		}
+		return map[string]any{"message_hash": base64.StdEncoding.EncodeToString(sent.Hash())}
+	})
+
+	err := tc.SendExternalMessage(context.Background(), &tlb.ExternalMessage{DstAddr: testAddress, Body: body})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var msg tlb.ExternalMessage
+	if err := tlb.LoadFromCell(&msg, sent.BeginParse()); err != nil {
+		t.Fatalf("Unexpected message: %v", err)
+	}
+	if msg.DstAddr.String() != testAddress.String() || string(msg.Body.Hash()) != string(body.Hash()) {
+		t.Errorf("Unexpected message: %v", msg)
+	}
+}
+
+func TestTonCenterV3Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]any{"error": "rate limit exceeded"})
+	}))
+	t.Cleanup(server.Close)
+	tc := NewTonCenterV3(server.URL, "")
+
+	if _, err := tc.GetAccount(context.Background(), testAddress); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+	if _, err := tc.ListTransactions(context.Background(), testAddress, 10, 1, make([]byte, 32)); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+}
@@ -0,0 +1,190 @@
+// Package tonnet provides access to the TON network through liteservers or a toncenter-compatible HTTP API.
+// The network configuration can be loaded from a URL or a local file,
+// and a custom liteserver and trusted key block can be used instead of the global config ones,
+// e.g. to run against a private network or in offline CI.
+package tonnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"strings"
+)
+
+const (
+	APILiteServer  = "liteserver"
+	APITonCenter   = "toncenter"
+	APITonCenterV3 = "toncenter-v3"
+)
+
+// Config contains parameters for connecting to the TON network.
+type Config struct {
+	API           string                  // Network access backend: APILiteServer (default), APITonCenter or APITonCenterV3.
+	GlobalConfig  string                  // URL or local file path of the global network config, may be empty with LiteServer.
+	LiteServer    string                  // Liteserver address (host:port) to use instead of the global config liteservers.
+	LiteServerKey string                  // Base64-encoded public key of the LiteServer.
+	TrustedBlock  *liteclient.ConfigBlock // Key block to trust instead of the global config init block.
+	TonCenterURL  string                  // Base URL of the toncenter-compatible HTTP API.
+	TonCenterKey  string                  // Optional toncenter API key.
+}
+
+// Account represents the account state used by the enclave.
+type Account struct {
+	IsActive   bool
+	Balance    tlb.Coins
+	LastTxLT   uint64
+	LastTxHash []byte
+}
+
+// Chain provides the blockchain operations used by the enclave.
+type Chain interface {
+	// GetAccount returns the current state of the account.
+	GetAccount(ctx context.Context, addr *address.Address) (Account, error)
+	// RunGetMethod runs the get-method of the contract at the current state.
+	RunGetMethod(ctx context.Context, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error)
+	// ListTransactions returns up to num account transactions ending with the one with lt and txHash, from old to new.
+	ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error)
+	// MasterchainSeqno returns the seqno of the last masterchain block.
+	MasterchainSeqno(ctx context.Context) (uint32, error)
+	// SubscribeOnTransactions sends new account transactions after lastProcessedLT to the channel, from old to new.
+	// The channel is closed when the subscription stops.
+	SubscribeOnTransactions(ctx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction)
+	// SendExternalMessage sends the external message to the network.
+	SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error
+}
+
+// Connect returns the Chain selected by the config.
+func Connect(ctx context.Context, cfg Config) (Chain, error) {
+	switch cfg.API {
+	case APITonCenter:
+		return NewTonCenter(cfg.TonCenterURL, cfg.TonCenterKey), nil
+	case APITonCenterV3:
+		return NewTonCenterV3(cfg.TonCenterURL, cfg.TonCenterKey), nil
+	case APILiteServer, "":
+		api, err := ConnectLiteServers(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewLiteChain(api), nil
+	default:
+		return nil, fmt.Errorf("unknown network API: %s", cfg.API)
+	}
+}
+
+// LoadGlobalConfig loads the global network config from a URL or a local file.
+func LoadGlobalConfig(ctx context.Context, location string) (*liteclient.GlobalConfig, error) {
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		return liteclient.GetConfigFromUrl(ctx, location)
+	}
+	return liteclient.GetConfigFromFile(location)
+}
+
+// ConnectLiteServers creates a liteserver connection pool and an API client according to the config.
+func ConnectLiteServers(ctx context.Context, cfg Config) (ton.APIClientWrapped, error) {
+	var globalConfig *liteclient.GlobalConfig
+	if cfg.GlobalConfig != "" {
+		var err error
+		globalConfig, err = LoadGlobalConfig(ctx, cfg.GlobalConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := liteclient.NewConnectionPool()
+	switch {
+	case cfg.LiteServer != "":
+		if err := client.AddConnection(ctx, cfg.LiteServer, cfg.LiteServerKey); err != nil {
+			return nil, err
+		}
+	case globalConfig != nil:
+		if err := client.AddConnectionsFromConfig(ctx, globalConfig); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("neither global config nor liteserver is specified")
+	}
+
+	api := ton.NewAPIClient(client).WithRetry()
+	if block := TrustedBlock(cfg, globalConfig); block != nil {
+		api.SetTrustedBlock(block)
+	}
+	return api, nil
+}
+
+// TrustedBlock returns the block to start proof checks from:
+// the pinned block, or the global config init block.
+// It returns nil if neither is known, then the first received master block is trusted.
+func TrustedBlock(cfg Config, globalConfig *liteclient.GlobalConfig) *ton.BlockIDExt {
+	var block ton.BlockIDExt
+	switch {
+	case cfg.TrustedBlock != nil:
+		block = ton.BlockIDExt(*cfg.TrustedBlock)
+	case globalConfig != nil:
+		block = ton.BlockIDExt(globalConfig.Validator.InitBlock)
+	default:
+		return nil
+	}
+	return &block
+}
+
+// LiteChain implements Chain over liteservers.
+type LiteChain struct {
+	api ton.APIClientWrapped
+}
+
+// NewLiteChain creates a new LiteChain instance.
+func NewLiteChain(api ton.APIClientWrapped) *LiteChain {
+	return &LiteChain{api: api}
+}
+
+func (chain *LiteChain) GetAccount(ctx context.Context, addr *address.Address) (Account, error) {
+	master, err := chain.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	acc, err := chain.api.WaitForBlock(master.SeqNo).GetAccount(ctx, master, addr)
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{
+		IsActive:   acc.IsActive && acc.State.Status == tlb.AccountStatusActive,
+		LastTxLT:   acc.LastTxLT,
+		LastTxHash: acc.LastTxHash,
+	}
+	if acc.State != nil {
+		account.Balance = acc.State.Balance
+	}
+	return account, nil
+}
+
+func (chain *LiteChain) RunGetMethod(ctx context.Context, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	master, err := chain.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chain.api.WaitForBlock(master.SeqNo).RunGetMethod(ctx, master, addr, method, params...)
+}
+
+func (chain *LiteChain) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	return chain.api.ListTransactions(ctx, addr, num, lt, txHash)
+}
+
+func (chain *LiteChain) MasterchainSeqno(ctx context.Context) (uint32, error) {
+	master, err := chain.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return master.SeqNo, nil
+}
+
+func (chain *LiteChain) SubscribeOnTransactions(ctx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	chain.api.SubscribeOnTransactions(ctx, addr, lastProcessedLT, channel)
+}
+
+func (chain *LiteChain) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	return chain.api.SendExternalMessage(ctx, msg)
+}
@@ -0,0 +1,56 @@
+package tonnet
+
+import (
+	"context"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadGlobalConfig(t *testing.T) {
+	t.Run("Local file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "global.config.json")
+		data := `{"liteservers": [{"ip": 2130706433, "port": 4443, "id": {"key": "a2V5"}}],
+			"validator": {"init_block": {"workchain": -1, "seqno": 42}}}`
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadGlobalConfig(context.Background(), path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(cfg.Liteservers) != 1 || cfg.Validator.InitBlock.SeqNo != 42 {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := LoadGlobalConfig(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+		if err == nil {
+			t.Errorf("Expected error not raised")
+		}
+	})
+}
+
+func TestTrustedBlock(t *testing.T) {
+	globalConfig := &liteclient.GlobalConfig{}
+	globalConfig.Validator.InitBlock.SeqNo = 1
+
+	if block := TrustedBlock(Config{}, nil); block != nil {
+		t.Errorf("Unexpected block: %+v", block)
+	}
+	if block := TrustedBlock(Config{}, globalConfig); block == nil || block.SeqNo != 1 {
+		t.Errorf("Init block is expected: %+v", block)
+	}
+	pinned := Config{TrustedBlock: &liteclient.ConfigBlock{Workchain: -1, SeqNo: 2}}
+	if block := TrustedBlock(pinned, globalConfig); block == nil || block.SeqNo != 2 {
+		t.Errorf("Pinned block is expected: %+v", block)
+	}
+}
+
+func TestConnectWithoutConfig(t *testing.T) {
+	if _, err := Connect(context.Background(), Config{}); err == nil {
+		t.Errorf("Expected error not raised")
+	}
+}
@@ -0,0 +1,434 @@
+// Package tonnettest provides an in-process fake of the TON network implementing tonnet.Chain, for tests.
+// Transactions are scripted by tests, sent external messages are recorded and executed as account transactions.
+package tonnettest
+
+import (
+	"context"
+	"crypto/sha256"
+	"enclave/tonnet"
+	"encoding/binary"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// ErrCodeUnknownMethod is the exit code returned for get-methods which are not set.
+const ErrCodeUnknownMethod = 11
+
+var _ tonnet.Chain = (*FakeChain)(nil)
+
+// FakeChain implements tonnet.Chain in memory.
+type FakeChain struct {
+	mu       sync.Mutex
+	lt       uint64
+	now      uint32
+	accounts map[string]*fakeAccount
+	sent     []*tlb.ExternalMessage
+	watchers int
+	dropped  int           // subscriptions up to this number are closed
+	stalled  int           // subscriptions up to this number deliver nothing
+	master   uint32        // masterchain seqno, advanced with every transaction
+	drop     int           // number of the next external messages lost
+	changed  chan struct{} // closed and replaced on every change
+}
+
+type fakeAccount struct {
+	transactions []*tlb.Transaction
+	balance      tlb.Coins
+	getMethods   map[string][]any
+	seqno        uint64
+	exitCode     int32    // compute phase exit code of delivered messages
+	replies      []string // comments replied to the senders of delivered messages
+}
+
+// NewFakeChain creates a new empty FakeChain.
+func NewFakeChain() *FakeChain {
+	return &FakeChain{
+		lt:       1000,
+		now:      uint32(time.Now().Unix()),
+		accounts: map[string]*fakeAccount{},
+		changed:  make(chan struct{}),
+	}
+}
+
+// AddTransaction appends a transaction to the account,
+// with external-out messages carrying the comments, e.g. "random()".
+func (c *FakeChain) AddTransaction(addr *address.Address, comments ...string) *tlb.Transaction {
+	bodies := make([]*cell.Cell, 0, len(comments))
+	for _, comment := range comments {
+		bodies = append(bodies, cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake(comment).EndCell())
+	}
+	return c.AddEventTransaction(addr, bodies...)
+}
+
+// AddEventTransaction appends a transaction to the account, with external-out messages carrying the bodies.
+func (c *FakeChain) AddEventTransaction(addr *address.Address, bodies ...*cell.Cell) *tlb.Transaction {
+	out := make([]*tlb.Message, 0, len(bodies))
+	for _, body := range bodies {
+		out = append(out, &tlb.Message{
+			MsgType: tlb.MsgTypeExternalOut,
+			Msg: &tlb.ExternalMessageOut{
+				SrcAddr: addr,
+				DstAddr: address.NewAddressNone(),
+				Body:    body,
+			},
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addTransaction(addr, nil, out)
+}
+
+// SetBalance sets the account balance.
+func (c *FakeChain) SetBalance(addr *address.Address, balance tlb.Coins) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.account(addr).balance = balance
+}
+
+// SetResult sets the compute phase exit code of the account transactions processing the delivered messages,
+// and the comments replied to the message senders, e.g. a rejection.
+func (c *FakeChain) SetResult(addr *address.Address, exitCode int32, replies ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	acc := c.account(addr)
+	acc.exitCode = exitCode
+	acc.replies = replies
+}
+
+// DropExternalMessages makes the next count external messages recorded but lost, e.g. to test resubmissions.
+func (c *FakeChain) DropExternalMessages(count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop = count
+}
+
+// DropSubscriptions closes the running subscriptions, as a lost liteserver connection does.
+func (c *FakeChain) DropSubscriptions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped = c.watchers
+	c.notify()
+}
+
+// StallSubscriptions makes the running subscriptions deliver nothing without closing, the new ones are not affected.
+func (c *FakeChain) StallSubscriptions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stalled = c.watchers
+}
+
+// SetGetMethod sets the stack values returned by the account get-method.
+func (c *FakeChain) SetGetMethod(addr *address.Address, method string, values ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.account(addr).getMethods[method] = values
+}
+
+// SentMessages returns the external messages sent to the chain.
+func (c *FakeChain) SentMessages() []*tlb.ExternalMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tlb.ExternalMessage(nil), c.sent...)
+}
+
+// InternalMessages returns the internal messages carried by the sent wallet messages.
+func (c *FakeChain) InternalMessages() []*tlb.InternalMessage {
+	var messages []*tlb.InternalMessage
+	for _, ext := range c.SentMessages() {
+		messages = append(messages, walletMessages(ext)...)
+	}
+	return messages
+}
+
+// WaitSentMessages waits until at least count external messages are sent.
+func (c *FakeChain) WaitSentMessages(ctx context.Context, count int) error {
+	for {
+		c.mu.Lock()
+		sent, changed := len(c.sent), c.changed
+		c.mu.Unlock()
+		if sent >= count {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("%d of %d messages sent: %w", sent, count, ctx.Err())
+		}
+	}
+}
+
+// WaitSubscribed waits until at least count subscriptions are started.
+func (c *FakeChain) WaitSubscribed(ctx context.Context, count int) error {
+	for {
+		c.mu.Lock()
+		watchers, changed := c.watchers, c.changed
+		c.mu.Unlock()
+		if watchers >= count {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("%d of %d subscriptions started: %w", watchers, count, ctx.Err())
+		}
+	}
+}
+
+func (c *FakeChain) GetAccount(ctx context.Context, addr *address.Address) (tonnet.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	acc := c.account(addr)
+	result := tonnet.Account{IsActive: len(acc.transactions) > 0, Balance: acc.balance}
+	if last := acc.last(); last != nil {
+		result.LastTxLT = last.LT
+		result.LastTxHash = last.Hash
+	}
+	return result, nil
+}
+
+func (c *FakeChain) RunGetMethod(ctx context.Context, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	acc := c.account(addr)
+	if values, ok := acc.getMethods[method]; ok {
+		return ton.NewExecutionResult(values), nil
+	}
+	if method == "seqno" {
+		if len(acc.transactions) == 0 {
+			return nil, ton.ContractExecError{Code: ton.ErrCodeContractNotInitialized}
+		}
+		return ton.NewExecutionResult([]any{new(big.Int).SetUint64(acc.seqno)}), nil
+	}
+	return nil, ton.ContractExecError{Code: ErrCodeUnknownMethod}
+}
+
+func (c *FakeChain) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	transactions := c.account(addr).transactions
+	for i, tx := range transactions {
+		if tx.LT == lt {
+			start := max(0, i+1-int(num))
+			return append([]*tlb.Transaction(nil), transactions[start:i+1]...), nil
+		}
+	}
+	return nil, ton.ErrNoTransactionsWereFound
+}
+
+func (c *FakeChain) MasterchainSeqno(ctx context.Context) (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.master, nil
+}
+
+func (c *FakeChain) SubscribeOnTransactions(ctx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	defer close(channel)
+	c.mu.Lock()
+	c.watchers++
+	id := c.watchers
+	c.notify()
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		if id <= c.dropped {
+			c.mu.Unlock()
+			return
+		}
+		var transactions []*tlb.Transaction
+		if id > c.stalled {
+			for _, tx := range c.account(addr).transactions {
+				if tx.LT > lastProcessedLT {
+					transactions = append(transactions, tx)
+				}
+			}
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		for _, tx := range transactions {
+			select {
+			case channel <- tx:
+				lastProcessedLT = tx.LT
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SendExternalMessage records the message and executes it as a transaction of the destination account.
+// Wallet messages with another seqno are not executed, as the wallet rejects them.
+// The internal messages of the wallet are delivered to their destination accounts, see SetResult.
+func (c *FakeChain) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	internals := walletMessages(msg)
+	out := make([]*tlb.Message, 0, len(internals))
+	for _, internal := range internals {
+		out = append(out, &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: internal})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	c.notify()
+	acc := c.account(msg.DstAddr)
+	if c.drop > 0 {
+		c.drop--
+		return nil
+	}
+	if seqno, ok := walletSeqno(msg); ok && seqno != acc.seqno {
+		return nil
+	}
+	acc.seqno++
+	c.addTransaction(msg.DstAddr, &tlb.Message{MsgType: tlb.MsgTypeExternalIn, Msg: msg}, out)
+	for _, internal := range internals {
+		c.deliver(internal)
+	}
+	return nil
+}
+
+// deliver executes the internal message as an ordinary transaction of the destination account,
+// the replies are not delivered further. The caller must hold the lock.
+func (c *FakeChain) deliver(msg *tlb.InternalMessage) {
+	acc := c.account(msg.DstAddr)
+	out := make([]*tlb.Message, 0, len(acc.replies))
+	for _, reply := range acc.replies {
+		out = append(out, &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: &tlb.InternalMessage{
+			DstAddr: msg.SrcAddr,
+			Amount:  tlb.ZeroCoins,
+			Body:    cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake(reply).EndCell(),
+		}})
+	}
+	compute := tlb.ComputePhaseVM{Success: acc.exitCode == 0}
+	compute.Details.ExitCode = acc.exitCode
+	tx := c.addTransaction(msg.DstAddr, &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: msg}, out)
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: compute},
+		ActionPhase:  &tlb.ActionPhase{Success: acc.exitCode == 0},
+		Aborted:      acc.exitCode != 0,
+	}
+}
+
+// addTransaction appends the transaction to the account and advances LT, the caller must hold the lock.
+func (c *FakeChain) addTransaction(addr *address.Address, in *tlb.Message, out []*tlb.Message) *tlb.Transaction {
+	acc := c.account(addr)
+	c.lt += 10
+	c.now++
+	c.master++
+
+	tx := &tlb.Transaction{
+		AccountAddr: addr.Data(),
+		LT:          c.lt,
+		Now:         c.now,
+		OutMsgCount: uint16(len(out)),
+	}
+	if last := acc.last(); last != nil {
+		tx.PrevTxLT = last.LT
+		tx.PrevTxHash = last.Hash
+	}
+	tx.IO.In = in
+	for i, msg := range out {
+		if msg.MsgType == tlb.MsgTypeInternal {
+			// Outgoing internal messages are identified by the source address and the creation LT.
+			internal := msg.AsInternal()
+			internal.SrcAddr = addr
+			internal.CreatedLT = tx.LT + 1 + uint64(i)
+			internal.CreatedAt = tx.Now
+		}
+	}
+	if len(out) > 0 {
+		tx.IO.Out = messagesList(out)
+	}
+	hash := sha256.New()
+	hash.Write(addr.Data())
+	binary.Write(hash, binary.BigEndian, tx.LT)
+	tx.Hash = hash.Sum(nil)
+
+	acc.transactions = append(acc.transactions, tx)
+	c.notify()
+	return tx
+}
+
+// notify wakes up waiters of the chain changes, the caller must hold the lock.
+func (c *FakeChain) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *FakeChain) account(addr *address.Address) *fakeAccount {
+	key := fmt.Sprintf("%d:%x", addr.Workchain(), addr.Data())
+	acc, ok := c.accounts[key]
+	if !ok {
+		acc = &fakeAccount{getMethods: map[string][]any{}}
+		c.accounts[key] = acc
+	}
+	return acc
+}
+
+func (acc *fakeAccount) last() *tlb.Transaction {
+	if len(acc.transactions) == 0 {
+		return nil
+	}
+	return acc.transactions[len(acc.transactions)-1]
+}
+
+func messagesList(messages []*tlb.Message) *tlb.MessagesList {
+	dict := cell.NewDict(15)
+	for i, msg := range messages {
+		msgCell, err := tlb.ToCell(msg.Msg)
+		if err != nil {
+			panic(err)
+		}
+		value := cell.BeginCell().MustStoreRef(msgCell).EndCell()
+		if err := dict.SetIntKey(big.NewInt(int64(i)), value); err != nil {
+			panic(err)
+		}
+	}
+	return &tlb.MessagesList{List: dict}
+}
+
+// walletSeqno decodes the seqno of the V3 or V4 wallet external message, after the signature, subwallet ID and expiration.
+func walletSeqno(ext *tlb.ExternalMessage) (uint64, bool) {
+	if ext.Body == nil {
+		return 0, false
+	}
+	body := ext.Body.BeginParse()
+	if _, err := body.LoadSlice(512 + 32 + 32); err != nil {
+		return 0, false
+	}
+	seqno, err := body.LoadUInt(32)
+	return seqno, err == nil
+}
+
+// walletMessages decodes internal messages of the wallet external message, each stored in a body reference.
+func walletMessages(ext *tlb.ExternalMessage) []*tlb.InternalMessage {
+	if ext.Body == nil {
+		return nil
+	}
+	var messages []*tlb.InternalMessage
+	body := ext.Body.BeginParse()
+	for body.RefsNum() > 0 {
+		ref, err := body.LoadRef()
+		if err != nil {
+			break
+		}
+		var msg tlb.InternalMessage
+		if err := tlb.LoadFromCell(&msg, ref); err != nil {
+			continue
+		}
+		messages = append(messages, &msg)
+	}
+	return messages
+}
@@ -0,0 +1,99 @@
+package tonnet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"time"
+)
+
+// TraceMessage polls the destination account transactions until the one processing the internal message is found.
+// The message is identified by its source address and creation LT, the transaction LT is greater than the creation one.
+func TraceMessage(ctx context.Context, chain Chain, msg *tlb.InternalMessage, pollInterval time.Duration) (*tlb.Transaction, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		// fallback timeout to not stuck forever with background context
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 180*time.Second)
+		defer cancel()
+	}
+
+	for {
+		if tx := findInboundTransaction(ctx, chain, msg); tx != nil {
+			return tx, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("message to %s: %w", msg.DstAddr, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// findInboundTransaction returns the destination account transaction processing the internal message, if any.
+func findInboundTransaction(ctx context.Context, chain Chain, msg *tlb.InternalMessage) *tlb.Transaction {
+	acc, err := chain.GetAccount(ctx, msg.DstAddr)
+	if err != nil {
+		return nil
+	}
+
+	lt, hash := acc.LastTxLT, acc.LastTxHash
+	for lt > msg.CreatedLT {
+		txList, err := chain.ListTransactions(ctx, msg.DstAddr, 10, lt, hash)
+		if err != nil || len(txList) == 0 {
+			return nil
+		}
+		for _, tx := range txList {
+			if tx.IO.In == nil || tx.IO.In.MsgType != tlb.MsgTypeInternal {
+				continue
+			}
+			in := tx.IO.In.AsInternal()
+			if in.CreatedLT == msg.CreatedLT && sameAddress(in.SrcAddr, msg.SrcAddr) {
+				return tx
+			}
+		}
+		lt, hash = txList[0].PrevTxLT, txList[0].PrevTxHash
+	}
+	return nil
+}
+
+// outgoingMessage returns the internal message of the transaction with the destination address and the body of sent,
+// the wallet may send several messages to the same destination in one transaction.
+func outgoingMessage(tx *tlb.Transaction, sent *tlb.InternalMessage) (*tlb.InternalMessage, error) {
+	if tx.IO.Out != nil {
+		messages, err := tx.IO.Out.ToSlice()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			if msg.MsgType != tlb.MsgTypeInternal {
+				continue
+			}
+			out := msg.AsInternal()
+			if sameAddress(out.DstAddr, sent.DstAddr) && sameBody(out.Body, sent.Body) {
+				return out, nil
+			}
+		}
+	}
+	// E.g. the action phase failed, the wallet balance is not enough.
+	return nil, fmt.Errorf("transaction %x sent no message to %s", tx.Hash, sent.DstAddr)
+}
+
+// sameBody compares the message bodies, the missing body is loaded as an empty cell.
+func sameBody(a, b *cell.Cell) bool {
+	empty := cell.BeginCell().EndCell()
+	if a == nil {
+		a = empty
+	}
+	if b == nil {
+		b = empty
+	}
+	return bytes.Equal(a.Hash(), b.Hash())
+}
+
+// sameAddress compares the account addresses, ignoring the bounceable and testnet flags.
+func sameAddress(a, b *address.Address) bool {
+	return a.Workchain() == b.Workchain() && bytes.Equal(a.Data(), b.Data())
+}
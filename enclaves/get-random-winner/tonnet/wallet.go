@@ -0,0 +1,154 @@
+package tonnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"time"
+)
+
+// defaultMessageTTL is the period the wallet external messages are valid for, as in tonutils-go.
+const defaultMessageTTL = 3 * time.Minute
+
+// walletSpec is the spec of the supported wallet versions.
+type walletSpec interface {
+	SetSeqnoFetcher(func(ctx context.Context, subWallet uint32) (uint32, error))
+	SetMessagesTTL(ttl uint32)
+}
+
+// Wallet sends messages from the enclave wallet through any Chain.
+type Wallet struct {
+	chain        Chain
+	wallet       *wallet.Wallet
+	spec         walletSpec
+	pollInterval time.Duration
+	messageTTL   time.Duration
+}
+
+// NewWallet creates a new Wallet instance from the mnemonic.
+func NewWallet(chain Chain, mnemonic []string, version wallet.Version) (*Wallet, error) {
+	// The wallet is used offline: the seqno and the account state are fetched through the chain.
+	w, err := wallet.FromSeed(nil, mnemonic, version)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := w.GetSpec().(walletSpec)
+	if !ok {
+		return nil, fmt.Errorf("unsupported wallet version: %v", version)
+	}
+
+	result := &Wallet{chain: chain, wallet: w, spec: spec, pollInterval: 2 * time.Second}
+	spec.SetSeqnoFetcher(result.seqno)
+	result.SetMessageTTL(defaultMessageTTL)
+	return result, nil
+}
+
+// SetMessageTTL sets the period the external messages are valid for, in whole seconds.
+// The send queue waits it out before failing an unconfirmed batch.
+func (w *Wallet) SetMessageTTL(ttl time.Duration) {
+	w.messageTTL = ttl.Truncate(time.Second)
+	w.spec.SetMessagesTTL(uint32(ttl / time.Second))
+}
+
+// Address returns the wallet address.
+func (w *Wallet) Address() *address.Address {
+	return w.wallet.WalletAddress()
+}
+
+// SendWaitTransaction sends the message and waits for the wallet transaction processing it.
+func (w *Wallet) SendWaitTransaction(ctx context.Context, msg *wallet.Message) (*tlb.Transaction, error) {
+	acc, err := w.chain.GetAccount(ctx, w.wallet.WalletAddress())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account state: %w", err)
+	}
+
+	ext, err := w.wallet.PrepareExternalMessageForMany(ctx, !acc.IsActive, []*wallet.Message{msg})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.chain.SendExternalMessage(ctx, ext); err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	return w.waitConfirmation(ctx, acc, ext)
+}
+
+// seqnoKey is the context key of the seqno set by the send queue, the wallet seqno is fetched if it's not set.
+type seqnoKey struct{}
+
+func (w *Wallet) seqno(ctx context.Context, _ uint32) (uint32, error) {
+	if seqno, ok := ctx.Value(seqnoKey{}).(uint32); ok {
+		return seqno, nil
+	}
+	resp, err := w.chain.RunGetMethod(ctx, w.wallet.WalletAddress(), "seqno")
+	if err != nil {
+		var execErr ton.ContractExecError
+		if errors.As(err, &execErr) && execErr.Code == ton.ErrCodeContractNotInitialized {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("get seqno err: %w", err)
+	}
+	seqno, err := resp.Int(0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse seqno: %w", err)
+	}
+	return uint32(seqno.Uint64()), nil
+}
+
+// prepare builds the external message sending the messages with the seqno.
+func (w *Wallet) prepare(ctx context.Context, seqno uint32, withStateInit bool, messages []*wallet.Message) (*tlb.ExternalMessage, error) {
+	return w.wallet.PrepareExternalMessageForMany(context.WithValue(ctx, seqnoKey{}, seqno), withStateInit, messages)
+}
+
+// waitConfirmation polls the wallet transactions until the one with the external message is found.
+func (w *Wallet) waitConfirmation(ctx context.Context, acc Account, ext *tlb.ExternalMessage) (*tlb.Transaction, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		// fallback timeout to not stuck forever with background context
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 180*time.Second)
+		defer cancel()
+	}
+
+	addr := w.wallet.WalletAddress()
+	bodyHash := ext.Body.Hash()
+	for {
+		if tx := w.findTransaction(ctx, addr, acc, bodyHash); tx != nil {
+			return tx, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("transaction confirmation: %w", ctx.Err())
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// findTransaction returns the wallet transaction after acc processing the external message with bodyHash, if any.
+func (w *Wallet) findTransaction(ctx context.Context, addr *address.Address, acc Account, bodyHash []byte) *tlb.Transaction {
+	accNew, err := w.chain.GetAccount(ctx, addr)
+	if err != nil || accNew.LastTxLT == acc.LastTxLT {
+		return nil
+	}
+
+	lt, hash := accNew.LastTxLT, accNew.LastTxHash
+	for lt > acc.LastTxLT {
+		txList, err := w.chain.ListTransactions(ctx, addr, 5, lt, hash)
+		if err != nil {
+			return nil
+		}
+		for _, tx := range txList {
+			if tx.IO.In == nil || tx.IO.In.MsgType != tlb.MsgTypeExternalIn {
+				continue
+			}
+			if bytes.Equal(tx.IO.In.AsExternalIn().Body.Hash(), bodyHash) {
+				return tx
+			}
+		}
+		lt, hash = txList[0].PrevTxLT, txList[0].PrevTxHash
+	}
+	return nil
+}
@@ -48,3 +48,25 @@ func (parser TransactionParser) ParseComment(payload *cell.Cell) string {
 	}
 	return ""
 }
+
+// ParseExternalBodies returns bodies of external messages emitted by the contract, e.g. events with opcodes.
+func (parser TransactionParser) ParseExternalBodies(tx *tlb.Transaction) []*cell.Cell {
+	var bodies []*cell.Cell
+
+	if tx.IO.Out != nil {
+		messages, err := tx.IO.Out.ToSlice()
+		if err != nil {
+			return nil
+		}
+		for _, m := range messages {
+			if m.MsgType != tlb.MsgTypeExternalOut {
+				continue
+			}
+			externalOut := m.AsExternalOut()
+			if parser.hasAddress(externalOut.SrcAddr) && externalOut.DstAddr.IsAddrNone() && externalOut.Body != nil {
+				bodies = append(bodies, externalOut.Body)
+			}
+		}
+	}
+	return bodies
+}
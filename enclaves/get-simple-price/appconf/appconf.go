@@ -1,34 +1,108 @@
 // Package appconf provides application-specific configuration management by extending the base configuration provided by econf.
+// Application settings are loaded from defaults, then from the configuration file on the mount, then from env variables.
 package appconf
 
 import (
+	"bytes"
+	"enclave/elog"
+	"enclave/esecrets"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/tonteeton/golib/econf"
+	"github.com/xssnick/tonutils-go/address"
 	"os"
+	"strconv"
+	"time"
 )
 
 const (
-	TON_TICKER  = uint64(0x72716023)
-	APP_VERSION = "get-simple-price-v1r1"
+	TON_TICKER     = uint64(0x72716023)
+	TON_COIN_ID    = "the-open-network"
+	APP_VERSION    = "get-simple-price-v1r1"
+	CONFIG_PATH    = "mount/config.json"
+	TESTNET_CONFIG = "https://ton.org/testnet-global.config.json"
+	MAINNET_CONFIG = "https://ton.org/global.config.json"
 )
 
 // Config extends the econf.Config to include additional application-specific configurations.
 type Config struct {
 	econf.Config // Embedding main config from econf
 
-	// CoinGecko holds API keys for accessing CoinGecko services.
+	// Secrets holds paths for importing and storing sealed secrets.
+	Secrets esecrets.Config
+
+	// CoinGecko holds API keys and coin IDs for accessing CoinGecko services.
 	CoinGecko struct {
-		DemoKey string
-		ProKey  string
+		DemoKey   string
+		ProKey    string
+		TONCoinID string
 	}
 
 	// Tickers holds cryptocurrency ticker values.
 	Tickers struct {
 		TON uint64
 	}
+
+	// Validation holds thresholds for the price validation.
+	Validation struct {
+		MaxPriceAge      time.Duration
+		MaxChangePercent int64
+	}
+
+	// Network holds the price contract checked by the startup preflight, get-price fails if the address is not set.
+	Network struct {
+		TestNet         bool
+		GlobalConfig    string
+		ContractAddress *address.Address
+	}
+
+	// Log holds the log handler settings, see elog.
+	Log elog.Config
+}
+
+// fileConfig represents the configuration file.
+// JSON keys are used to name the settings in validation errors.
+type fileConfig struct {
+	Tickers struct {
+		TON uint64 `json:"ton"`
+	} `json:"tickers"`
+	CoinGecko struct {
+		TONCoinID string `json:"tonCoinId"`
+	} `json:"coingecko"`
+	Validation struct {
+		MaxPriceAgeSec   int64 `json:"maxPriceAgeSec"`
+		MaxChangePercent int64 `json:"maxChangePercent"`
+	} `json:"validation"`
+	Network struct {
+		TestNet         bool   `json:"testnet"`
+		GlobalConfig    string `json:"globalConfig"`
+		ContractAddress string `json:"contractAddress"`
+	} `json:"network"`
+	Log struct {
+		Format string `json:"format"`
+		Level  string `json:"level"`
+	} `json:"log"`
+}
+
+// defaultFileConfig returns settings used when they are set neither in the file nor in env.
+func defaultFileConfig() fileConfig {
+	var fc fileConfig
+	fc.Tickers.TON = TON_TICKER
+	fc.CoinGecko.TONCoinID = TON_COIN_ID
+	fc.Validation.MaxPriceAgeSec = 30 * 60
+	fc.Validation.MaxChangePercent = 1000
+	fc.Log.Format = elog.FormatText
+	fc.Log.Level = "info"
+	return fc
 }
 
 // LoadConfig loads the application configuration.
 func LoadConfig() (*Config, error) {
+	return loadConfig(CONFIG_PATH)
+}
+
+func loadConfig(path string) (*Config, error) {
 	cfg := Config{}
 
 	// Load econf.Config sections
@@ -38,10 +112,159 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.Config = *econfConfig
 
+	cfg.Secrets = esecrets.Config{
+		ImportPath: "mount/secrets_import.json",
+		SealedPath: "mount/secrets.enc",
+		Version:    APP_VERSION,
+	}
+
+	// Sealed secrets are preferred, env variables are a fallback for development.
+	secrets, err := esecrets.LoadSecrets(cfg.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load app-specific configurations
-	cfg.Tickers.TON = TON_TICKER
-	cfg.CoinGecko.DemoKey = os.Getenv("COINGECKO_API_KEY")
-	cfg.CoinGecko.ProKey = os.Getenv("COINGECKO_PRO_API_KEY")
+	fc := defaultFileConfig()
+	if err := fc.loadFile(path); err != nil {
+		return nil, err
+	}
+	if err := fc.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := fc.apply(&cfg); err != nil {
+		return nil, err
+	}
+	cfg.CoinGecko.DemoKey = secrets.GetOrEnv("COINGECKO_API_KEY")
+	cfg.CoinGecko.ProKey = secrets.GetOrEnv("COINGECKO_PRO_API_KEY")
 
 	return &cfg, nil
 }
+
+// loadFile overrides the settings with values from the configuration file, if it exists.
+func (fc *fileConfig) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(fc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return keyError(typeErr.Field, fmt.Errorf("expected %s value", typeErr.Type))
+		}
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the settings with values from env variables, every key of the file has one.
+func (fc *fileConfig) loadEnv() error {
+	if tickerEnv := os.Getenv("TON_TICKER"); tickerEnv != "" {
+		ticker, err := strconv.ParseUint(tickerEnv, 10, 64)
+		if err != nil {
+			return keyError("tickers.ton", fmt.Errorf("TON_TICKER env: %w", err))
+		}
+		fc.Tickers.TON = ticker
+	}
+	envString("COINGECKO_TON_COIN_ID", &fc.CoinGecko.TONCoinID)
+	if err := envInt("validation.maxPriceAgeSec", "MAX_PRICE_AGE_SEC", &fc.Validation.MaxPriceAgeSec); err != nil {
+		return err
+	}
+	if err := envInt("validation.maxChangePercent", "MAX_CHANGE_PERCENT", &fc.Validation.MaxChangePercent); err != nil {
+		return err
+	}
+	if testNetEnv := os.Getenv("TON_TESTNET"); testNetEnv != "" {
+		testNet, err := strconv.ParseBool(testNetEnv)
+		if err != nil {
+			return keyError("network.testnet", fmt.Errorf("TON_TESTNET env: %w", err))
+		}
+		fc.Network.TestNet = testNet
+	}
+	envString("TON_GLOBAL_CONFIG", &fc.Network.GlobalConfig)
+	envString("TON_CONTRACT_ADDRESS", &fc.Network.ContractAddress)
+	envString("LOG_FORMAT", &fc.Log.Format)
+	envString("LOG_LEVEL", &fc.Log.Level)
+	return nil
+}
+
+// envString overrides the setting with the env variable, if it is set.
+func envString(name string, value *string) {
+	if env := os.Getenv(name); env != "" {
+		*value = env
+	}
+}
+
+// envInt overrides the integer setting of the key with the env variable, if it is set.
+func envInt(key, name string, value *int64) error {
+	env := os.Getenv(name)
+	if env == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return keyError(key, fmt.Errorf("%s env: %w", name, err))
+	}
+	*value = n
+	return nil
+}
+
+// apply validates the settings and stores them in cfg.
+func (fc fileConfig) apply(cfg *Config) error {
+	if fc.Tickers.TON == 0 {
+		return keyError("tickers.ton", errors.New("must not be zero"))
+	}
+	cfg.Tickers.TON = fc.Tickers.TON
+
+	if fc.CoinGecko.TONCoinID == "" {
+		return keyError("coingecko.tonCoinId", errors.New("must not be empty"))
+	}
+	cfg.CoinGecko.TONCoinID = fc.CoinGecko.TONCoinID
+
+	if fc.Validation.MaxPriceAgeSec <= 0 {
+		return keyError("validation.maxPriceAgeSec", errors.New("must be positive"))
+	}
+	cfg.Validation.MaxPriceAge = time.Duration(fc.Validation.MaxPriceAgeSec) * time.Second
+
+	if fc.Validation.MaxChangePercent <= 0 {
+		return keyError("validation.maxChangePercent", errors.New("must be positive"))
+	}
+	cfg.Validation.MaxChangePercent = fc.Validation.MaxChangePercent
+
+	cfg.Network.TestNet = fc.Network.TestNet
+	switch {
+	case fc.Network.GlobalConfig != "":
+		cfg.Network.GlobalConfig = fc.Network.GlobalConfig
+	case fc.Network.TestNet:
+		cfg.Network.GlobalConfig = TESTNET_CONFIG
+	default:
+		cfg.Network.GlobalConfig = MAINNET_CONFIG
+	}
+	if fc.Network.ContractAddress != "" {
+		parsedAddress, err := address.ParseAddr(fc.Network.ContractAddress)
+		if err != nil {
+			return keyError("network.contractAddress", err)
+		}
+		cfg.Network.ContractAddress = parsedAddress
+	}
+
+	format, err := elog.ParseFormat(fc.Log.Format)
+	if err != nil {
+		return keyError("log.format", err)
+	}
+	cfg.Log.Format = format
+	if err := cfg.Log.Level.UnmarshalText([]byte(fc.Log.Level)); err != nil {
+		return keyError("log.level", err)
+	}
+
+	return nil
+}
+
+// keyError returns a validation error naming the configuration key.
+func keyError(key string, err error) error {
+	return fmt.Errorf("config key %q: %w", key, err)
+}
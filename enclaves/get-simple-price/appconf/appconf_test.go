@@ -1,7 +1,13 @@
 package appconf
 
 import (
+	"enclave/elog"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -18,3 +24,103 @@ func TestLoadConfig(t *testing.T) {
 		}
 	})
 }
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("File overrides defaults, env overrides file", func(t *testing.T) {
+		t.Setenv("COINGECKO_TON_COIN_ID", "toncoin")
+		path := writeConfigFile(t, `{
+			"tickers": {"ton": 1},
+			"coingecko": {"tonCoinId": "ton"},
+			"validation": {"maxPriceAgeSec": 60}
+		}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Tickers.TON != 1 || cfg.Validation.MaxPriceAge != time.Minute {
+			t.Errorf("File values are not applied: %+v", cfg)
+		}
+		if cfg.CoinGecko.TONCoinID != "toncoin" {
+			t.Errorf("Env value is not applied: %+v", cfg.CoinGecko)
+		}
+		if cfg.Validation.MaxChangePercent != 1000 {
+			t.Errorf("Unexpected default value: %+v", cfg.Validation)
+		}
+		if cfg.Network.ContractAddress != nil || cfg.Network.GlobalConfig != MAINNET_CONFIG {
+			t.Errorf("Unexpected default network: %+v", cfg.Network)
+		}
+		if cfg.Log.Format != elog.FormatText || cfg.Log.Level != slog.LevelInfo {
+			t.Errorf("Unexpected default log settings: %+v", cfg.Log)
+		}
+	})
+
+	t.Run("Network of the preflight", func(t *testing.T) {
+		t.Setenv("TON_TESTNET", "true")
+		path := writeConfigFile(t, `{"network": {"contractAddress": "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2"}}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Network.ContractAddress == nil || !cfg.Network.TestNet || cfg.Network.GlobalConfig != TESTNET_CONFIG {
+			t.Errorf("Unexpected network: %+v", cfg.Network)
+		}
+	})
+
+	t.Run("Env overrides file keys", func(t *testing.T) {
+		t.Setenv("TON_TICKER", "2")
+		t.Setenv("MAX_PRICE_AGE_SEC", "120")
+		t.Setenv("MAX_CHANGE_PERCENT", "50")
+		path := writeConfigFile(t, `{
+			"tickers": {"ton": 1},
+			"validation": {"maxPriceAgeSec": 60, "maxChangePercent": 10}
+		}`)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Tickers.TON != 2 || cfg.Validation.MaxPriceAge != 2*time.Minute || cfg.Validation.MaxChangePercent != 50 {
+			t.Errorf("Env values are not applied: %+v, %+v", cfg.Tickers, cfg.Validation)
+		}
+	})
+
+	t.Run("Invalid env value", func(t *testing.T) {
+		t.Setenv("MAX_PRICE_AGE_SEC", "1m")
+		_, err := loadConfig(writeConfigFile(t, `{}`))
+		if err == nil || !strings.Contains(err.Error(), `"validation.maxPriceAgeSec"`) || !strings.Contains(err.Error(), "MAX_PRICE_AGE_SEC") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	cases := []struct {
+		content     string
+		expectedErr string
+	}{
+		{`{"tickers": {"ton": "TON"}}`, `"tickers.ton"`},
+		{`{"tickers": {"ton": 0}}`, `"tickers.ton"`},
+		{`{"coingecko": {"tonCoinId": ""}}`, `"coingecko.tonCoinId"`},
+		{`{"validation": {"maxPriceAgeSec": 0}}`, `"validation.maxPriceAgeSec"`},
+		{`{"validation": {"maxChangePercent": -5}}`, `"validation.maxChangePercent"`},
+		{`{"coingecko": {"apiKey": "demo"}}`, `"apiKey"`},
+		{`{"network": {"contractAddress": "EQ..."}}`, `"network.contractAddress"`},
+		{`{"log": {"format": "xml"}}`, `"log.format"`},
+		{`{"log": {"level": "loud"}}`, `"log.level"`},
+	}
+	for _, tcase := range cases {
+		t.Run(tcase.content, func(t *testing.T) {
+			_, err := loadConfig(writeConfigFile(t, tcase.content))
+			if err == nil {
+				t.Errorf("Expected error not raised: %v", tcase.expectedErr)
+			} else if !strings.Contains(err.Error(), tcase.expectedErr) {
+				t.Errorf("Unexpected error: %v,\n expected key: %v", err, tcase.expectedErr)
+			}
+		})
+	}
+}
@@ -32,8 +32,25 @@ func convertFloatValueToInt(value float64, precision int) int64 {
 	return int64(roundedValue)
 }
 
-// ValidatePrice validates priceresp.Price struct fields.
+// Limits holds thresholds for the price validation.
+type Limits struct {
+	MaxAge    time.Duration // Maximum difference between LastUpdatedAt and the current time.
+	MaxChange int64         // Maximum absolute USD24HChange value (2 decimal places precision).
+}
+
+// DefaultLimits are the thresholds used by ValidatePrice.
+var DefaultLimits = Limits{
+	MaxAge:    30 * time.Minute,
+	MaxChange: 1000 * 1e2,
+}
+
+// ValidatePrice validates priceresp.Price struct fields within DefaultLimits.
 func ValidatePrice(price priceresp.Price) error {
+	return DefaultLimits.Validate(price)
+}
+
+// Validate validates priceresp.Price struct fields within the limits.
+func (limits Limits) Validate(price priceresp.Price) error {
 	currentTime := time.Now()
 	lastUpdatedAt := time.Unix(int64(price.LastUpdatedAt), 0)
 
@@ -45,9 +62,9 @@ func ValidatePrice(price priceresp.Price) error {
 		return errors.New("USD24HVol value is out of valid range")
 	}
 
-	if price.USD24HChange < -1000*1e2 ||
+	if price.USD24HChange < -limits.MaxChange ||
 		price.USD24HChange == 0 ||
-		price.USD24HChange > 1000*1e2 {
+		price.USD24HChange > limits.MaxChange {
 		return errors.New("USD24HChange value is out of valid range")
 	}
 
@@ -55,8 +72,8 @@ func ValidatePrice(price priceresp.Price) error {
 		return errors.New("BTC value is out of valid range")
 	}
 
-	if lastUpdatedAt.Before(currentTime.Add(-30*time.Minute)) ||
-		lastUpdatedAt.After(currentTime.Add(30*time.Minute)) {
+	if lastUpdatedAt.Before(currentTime.Add(-limits.MaxAge)) ||
+		lastUpdatedAt.After(currentTime.Add(limits.MaxAge)) {
 		return errors.New("LastUpdatedAt is not within the valid time range")
 	}
 
@@ -2,7 +2,7 @@ package coinconv
 
 import (
 	"enclave/coingecko"
-	"enclave/eresp"
+	"enclave/priceresp"
 	"fmt"
 	"math"
 	"strings"
@@ -13,35 +13,35 @@ import (
 func TestConvertPrice(t *testing.T) {
 	cases := []struct {
 		input    coingecko.SimplePrice
-		expected eresp.EnclavePrice
+		expected priceresp.Price
 	}{
 		{
 			coingecko.SimplePrice{},
-			eresp.EnclavePrice{},
+			priceresp.Price{},
 		},
 		{
 			coingecko.SimplePrice{USD: 1},
-			eresp.EnclavePrice{USD: 1_00},
+			priceresp.Price{USD: 1_00},
 		},
 		{
 			coingecko.SimplePrice{USD: 1},
-			eresp.EnclavePrice{USD: 1_00},
+			priceresp.Price{USD: 1_00},
 		},
 		{
 			coingecko.SimplePrice{USD: 5.792609218137362},
-			eresp.EnclavePrice{USD: 5_79},
+			priceresp.Price{USD: 5_79},
 		},
 		{
 			coingecko.SimplePrice{USD: 0.2697380},
-			eresp.EnclavePrice{USD: 27},
+			priceresp.Price{USD: 27},
 		},
 		{
 			coingecko.SimplePrice{USD: 0.00999},
-			eresp.EnclavePrice{USD: 1},
+			priceresp.Price{USD: 1},
 		},
 		{
 			coingecko.SimplePrice{BTC: 9.2687479721799e-05},
-			eresp.EnclavePrice{BTC: 9_269},
+			priceresp.Price{BTC: 9_269},
 		},
 		{
 			coingecko.SimplePrice{
@@ -51,7 +51,7 @@ func TestConvertPrice(t *testing.T) {
 				USD24HChange:  -5.178720470976301,
 				BTC:           0.92687479721799e-05,
 			},
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: 1715266741,
 				USD:           100_09,
 				USD24HChange:  -518,
@@ -104,8 +104,8 @@ func TestConvertFloatValueToInt(t *testing.T) {
 }
 
 func TestPriceIsValid(t *testing.T) {
-	cases := []eresp.EnclavePrice{
-		eresp.EnclavePrice{
+	cases := []priceresp.Price{
+		priceresp.Price{
 			LastUpdatedAt: uint64(time.Now().Unix()),
 			Ticker:        1,
 			USD:           100_09,
@@ -129,15 +129,15 @@ func TestPriceIsInvalid(t *testing.T) {
 	now := uint64(time.Now().Unix())
 
 	cases := []struct {
-		input       eresp.EnclavePrice
+		input       priceresp.Price
 		expectedErr string
 	}{
 		{
-			eresp.EnclavePrice{},
+			priceresp.Price{},
 			"",
 		},
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: uint64(
 					time.Now().Add(-24 * time.Hour).Unix(),
 				),
@@ -150,7 +150,7 @@ func TestPriceIsInvalid(t *testing.T) {
 			"LastUpdatedAt",
 		},
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: now,
 				Ticker:        1,
 				USD:           0,
@@ -161,7 +161,7 @@ func TestPriceIsInvalid(t *testing.T) {
 			"USD value",
 		},
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: uint64(time.Now().Unix()),
 				Ticker:        1,
 				USD:           1,
@@ -172,7 +172,7 @@ func TestPriceIsInvalid(t *testing.T) {
 			"USD24HChange",
 		},
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: uint64(time.Now().Unix()),
 				Ticker:        1,
 				USD:           1,
@@ -183,7 +183,7 @@ func TestPriceIsInvalid(t *testing.T) {
 			"USD24HChange",
 		},
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: now,
 				Ticker:        1,
 				USD:           1,
@@ -195,7 +195,7 @@ func TestPriceIsInvalid(t *testing.T) {
 		},
 
 		{
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: now,
 				Ticker:        1,
 				USD:           1,
@@ -207,7 +207,7 @@ func TestPriceIsInvalid(t *testing.T) {
 		},
 		{
 
-			eresp.EnclavePrice{
+			priceresp.Price{
 				LastUpdatedAt: uint64(time.Now().Unix()),
 				USD:           100_09,
 				USD24HChange:  -518,
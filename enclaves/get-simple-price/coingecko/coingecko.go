@@ -54,36 +54,49 @@ func NewGecko(demoAPIKey string, proAPIKey string) GeckoClient {
 }
 
 // GetTONPrice queries CoinGecko for the prices of TON.
-// Reference: https://docs.coingecko.com/reference/simple-price
 func (gecko GeckoClient) GetTONPrice() (SimplePriceResponse, error) {
+	price, err := gecko.GetPrice("the-open-network")
+	if err != nil {
+		return SimplePriceResponse{}, err
+	}
+	return SimplePriceResponse{TON: price}, nil
+}
+
+// GetPrice queries CoinGecko for the prices of the coin with the given API ID.
+// Reference: https://docs.coingecko.com/reference/simple-price
+func (gecko GeckoClient) GetPrice(coinID string) (SimplePrice, error) {
 	query := url.Values{
 		"include_24hr_vol":        {"true"},
 		"include_24hr_change":     {"true"},
 		"include_last_updated_at": {"true"},
 		"precision":               {"18"},
 	}
-	query.Set("ids", "the-open-network")
+	query.Set("ids", coinID)
 	query.Set("vs_currencies", "USD,BTC")
 
 	apiURL, err := gecko.buildURL(`/api/v3/simple/price`, query)
 	if err != nil {
-		return SimplePriceResponse{}, err
+		return SimplePrice{}, err
 	}
 
 	data, err := gecko.get(apiURL)
 	if err != nil {
-		return SimplePriceResponse{}, err
+		return SimplePrice{}, err
 	}
 	if len(data) == 0 {
-		return SimplePriceResponse{}, errors.New("Empty response body")
+		return SimplePrice{}, errors.New("Empty response body")
 	}
-	var prices SimplePriceResponse
+	var prices map[string]SimplePrice
 	err = json.Unmarshal(data, &prices)
 	if err != nil {
-		return SimplePriceResponse{}, err
+		return SimplePrice{}, err
+	}
+	price, ok := prices[coinID]
+	if !ok {
+		return SimplePrice{}, fmt.Errorf("No price for coin ID: %s", coinID)
 	}
 
-	return prices, nil
+	return price, nil
 }
 
 func (gecko GeckoClient) buildURL(path string, query url.Values) (string, error) {
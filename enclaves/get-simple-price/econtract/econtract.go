@@ -0,0 +1,254 @@
+// Package econtract provides access to the price contract get-methods and account state through liteservers.
+package econtract
+
+import (
+	"context"
+	"crypto/ed25519"
+	"enclave/priceresp"
+	"errors"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math"
+	"math/big"
+	"strings"
+)
+
+var (
+	errUnexpectedState = errors.New("state(): unexpected result")
+	errUnexpectedPrice = errors.New("price(): unexpected result")
+)
+
+// Contract reads the price contract with its get-methods through liteservers.
+type Contract struct {
+	API     ton.APIClientWrapped
+	Address *address.Address
+}
+
+// OracleState is the state() report of the contract, see contracts/oracleProtocol.tact.
+type OracleState struct {
+	Name        string
+	Version     uint32 // major << 24 | minor << 16 | patch
+	Owner       *address.Address
+	PrevAddress *address.Address // nil if the contract was not moved from another address.
+	NewAddress  *address.Address
+	Moved       bool
+	Stopped     bool
+}
+
+// VersionString returns the version as major.minor.patch.
+func (s OracleState) VersionString() string {
+	return fmt.Sprintf("%d.%d.%d", s.Version>>24, s.Version>>16&0xff, s.Version&0xffff)
+}
+
+// Connect connects to liteservers of the global network config, loaded from a URL or a local file.
+func Connect(ctx context.Context, globalConfig string, contractAddress *address.Address) (Contract, error) {
+	var config *liteclient.GlobalConfig
+	var err error
+	if strings.HasPrefix(globalConfig, "https://") || strings.HasPrefix(globalConfig, "http://") {
+		config, err = liteclient.GetConfigFromUrl(ctx, globalConfig)
+	} else {
+		config, err = liteclient.GetConfigFromFile(globalConfig)
+	}
+	if err != nil {
+		return Contract{}, err
+	}
+	client := liteclient.NewConnectionPool()
+	if err := client.AddConnectionsFromConfig(ctx, config); err != nil {
+		return Contract{}, err
+	}
+	api := ton.NewAPIClient(client).WithRetry()
+	api.SetTrustedBlock((*ton.BlockIDExt)(&config.Validator.InitBlock))
+	return Contract{API: api, Address: contractAddress}, nil
+}
+
+// EnclavePublicKey returns the public key the contract checks enclave signatures with.
+func (c Contract) EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	return c.getUint256(ctx, "enclavePublicKey")
+}
+
+// EnclaveMeasurement returns the measurement (UniqueID) of the enclave attested in the contract.
+func (c Contract) EnclaveMeasurement(ctx context.Context) ([]byte, error) {
+	return c.getUint256(ctx, "enclaveMeasurment")
+}
+
+// State returns the contract state() report.
+func (c Contract) State(ctx context.Context) (OracleState, error) {
+	res, err := c.runGetMethod(ctx, "state")
+	if err != nil {
+		return OracleState{}, err
+	}
+	tuple, err := res.Tuple(0)
+	if err != nil {
+		return OracleState{}, fmt.Errorf("state(): %w", err)
+	}
+	return ParseOracleState(tuple)
+}
+
+// Balance returns the contract balance with the balance get-method.
+func (c Contract) Balance(ctx context.Context) (tlb.Coins, error) {
+	res, err := c.runGetMethod(ctx, "balance")
+	if err != nil {
+		return tlb.Coins{}, err
+	}
+	balance, err := res.Int(0)
+	if err != nil {
+		return tlb.Coins{}, fmt.Errorf("balance(): %w", err)
+	}
+	return tlb.FromNanoTON(balance), nil
+}
+
+// Price returns the last price update of the ticker, nil if the contract has no price for it.
+func (c Contract) Price(ctx context.Context, ticker uint64) (*priceresp.Price, error) {
+	res, err := c.runGetMethod(ctx, "price", new(big.Int).SetUint64(ticker))
+	if err != nil {
+		return nil, err
+	}
+	values := res.AsTuple()
+	if len(values) == 0 {
+		return nil, errUnexpectedPrice
+	}
+	return ParsePrice(values[0])
+}
+
+// LastTxLT returns the logical time of the last contract transaction.
+func (c Contract) LastTxLT(ctx context.Context) (uint64, error) {
+	master, err := c.API.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	account, err := c.API.WaitForBlock(master.SeqNo).GetAccount(ctx, master, c.Address)
+	if err != nil {
+		return 0, fmt.Errorf("contract account: %w", err)
+	}
+	return account.LastTxLT, nil
+}
+
+// ParseOracleState parses the OracleState struct returned by a get-method as a tuple of its fields.
+func ParseOracleState(tuple []any) (OracleState, error) {
+	if len(tuple) != 7 {
+		return OracleState{}, errUnexpectedState
+	}
+	var state OracleState
+	var err error
+	if state.Name, err = loadString(tuple[0]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): name: %w", err)
+	}
+	version, ok := tuple[1].(*big.Int)
+	if !ok || !version.IsUint64() || version.Uint64() > math.MaxUint32 {
+		return OracleState{}, errUnexpectedState
+	}
+	state.Version = uint32(version.Uint64())
+	if state.Owner, err = loadAddress(tuple[2]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): owner: %w", err)
+	}
+	if tuple[3] != nil {
+		if state.PrevAddress, err = loadAddress(tuple[3]); err != nil {
+			return OracleState{}, fmt.Errorf("state(): prevAddress: %w", err)
+		}
+	}
+	if state.NewAddress, err = loadAddress(tuple[4]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): newAddress: %w", err)
+	}
+	if state.Moved, err = loadBool(tuple[5]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): moved: %w", err)
+	}
+	if state.Stopped, err = loadBool(tuple[6]); err != nil {
+		return OracleState{}, fmt.Errorf("state(): stopped: %w", err)
+	}
+	return state, nil
+}
+
+// ParsePrice parses the optional PriceUpdate struct returned by a get-method, nil if it's not set.
+func ParsePrice(value any) (*priceresp.Price, error) {
+	if value == nil {
+		return nil, nil
+	}
+	tuple, ok := value.([]any)
+	if !ok || len(tuple) != 6 {
+		return nil, errUnexpectedPrice
+	}
+	ints := make([]*big.Int, len(tuple))
+	for i, field := range tuple {
+		if ints[i], ok = field.(*big.Int); !ok {
+			return nil, errUnexpectedPrice
+		}
+	}
+	if !ints[4].IsInt64() {
+		return nil, errUnexpectedPrice
+	}
+	for _, i := range []int{0, 1, 2, 3, 5} {
+		if !ints[i].IsUint64() {
+			return nil, errUnexpectedPrice
+		}
+	}
+	return &priceresp.Price{
+		LastUpdatedAt: ints[0].Uint64(),
+		Ticker:        ints[1].Uint64(),
+		USD:           ints[2].Uint64(),
+		USD24HVol:     ints[3].Uint64(),
+		USD24HChange:  ints[4].Int64(),
+		BTC:           ints[5].Uint64(),
+	}, nil
+}
+
+// runGetMethod runs the get-method at the current masterchain block.
+func (c Contract) runGetMethod(ctx context.Context, method string, params ...any) (*ton.ExecutionResult, error) {
+	master, err := c.API.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.API.WaitForBlock(master.SeqNo).RunGetMethod(ctx, master, c.Address, method, params...)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", method, err)
+	}
+	return res, nil
+}
+
+// getUint256 returns the uint256 result of the get-method as 32 big-endian bytes.
+func (c Contract) getUint256(ctx context.Context, method string) ([]byte, error) {
+	res, err := c.runGetMethod(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	value, err := res.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", method, err)
+	}
+	if value.Sign() < 0 || value.BitLen() > 256 {
+		return nil, fmt.Errorf("%s(): unexpected value", method)
+	}
+	return value.FillBytes(make([]byte, 32)), nil
+}
+
+// loadString loads the Tact String, returned as a slice or a cell.
+func loadString(value any) (string, error) {
+	switch value := value.(type) {
+	case *cell.Slice:
+		return value.Copy().LoadStringSnake()
+	case *cell.Cell:
+		return value.BeginParse().LoadStringSnake()
+	}
+	return "", errors.New("unexpected string value")
+}
+
+// loadAddress loads the Tact Address, returned as a slice.
+func loadAddress(value any) (*address.Address, error) {
+	slice, ok := value.(*cell.Slice)
+	if !ok {
+		return nil, errors.New("unexpected address value")
+	}
+	return slice.Copy().LoadAddr()
+}
+
+// loadBool loads the Tact Bool, returned as -1 or 0.
+func loadBool(value any) (bool, error) {
+	number, ok := value.(*big.Int)
+	if !ok {
+		return false, errors.New("unexpected bool value")
+	}
+	return number.Sign() != 0, nil
+}
@@ -0,0 +1,79 @@
+package econtract
+
+import (
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+)
+
+var (
+	testOwner   = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	testAddress = address.MustParseAddr("EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2")
+)
+
+func addressSlice(addr *address.Address) *cell.Slice {
+	return cell.BeginCell().MustStoreAddr(addr).EndCell().BeginParse()
+}
+
+func TestParseOracleState(t *testing.T) {
+	name := cell.BeginCell().MustStoreStringSnake("get-simple-price").EndCell()
+	tests := []struct {
+		name        string
+		tuple       []any
+		expectedErr bool
+		prevAddress bool
+	}{
+		{"Not moved", []any{name, big.NewInt(1<<24 | 1<<16), addressSlice(testOwner), nil, addressSlice(testAddress), big.NewInt(0), big.NewInt(0)}, false, false},
+		{"Moved", []any{name, big.NewInt(1<<24 | 1<<16), addressSlice(testOwner), addressSlice(testOwner), addressSlice(testAddress), big.NewInt(-1), big.NewInt(-1)}, false, true},
+		{"Short tuple", []any{name, big.NewInt(1)}, true, false},
+		{"Invalid owner", []any{name, big.NewInt(1), big.NewInt(1), nil, addressSlice(testAddress), big.NewInt(0), big.NewInt(0)}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := ParseOracleState(tt.tuple)
+			if tt.expectedErr {
+				if err == nil {
+					t.Error("Expected error not raised")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if state.Name != "get-simple-price" || state.VersionString() != "1.1.0" || state.Owner.String() != testOwner.String() ||
+				state.NewAddress.String() != testAddress.String() || (state.PrevAddress != nil) != tt.prevAddress ||
+				state.Moved != tt.prevAddress || state.Stopped != tt.prevAddress {
+				t.Errorf("Unexpected state: %+v", state)
+			}
+		})
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	t.Run("Not set", func(t *testing.T) {
+		price, err := ParsePrice(nil)
+		if price != nil || err != nil {
+			t.Errorf("Unexpected price: %+v, %v", price, err)
+		}
+	})
+
+	t.Run("Price update", func(t *testing.T) {
+		price, err := ParsePrice([]any{
+			big.NewInt(1715092161), big.NewInt(0x72716023), big.NewInt(345),
+			big.NewInt(81968225604), big.NewInt(-1566), big.NewInt(10967),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if price.LastUpdatedAt != 1715092161 || price.USD != 345 || price.USD24HChange != -1566 || price.BTC != 10967 {
+			t.Errorf("Unexpected price: %+v", price)
+		}
+	})
+
+	t.Run("Unexpected result", func(t *testing.T) {
+		if _, err := ParsePrice([]any{big.NewInt(1)}); err == nil {
+			t.Error("Expected error not raised")
+		}
+	})
+}
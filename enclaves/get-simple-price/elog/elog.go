@@ -0,0 +1,104 @@
+// Package elog provides the structured logging of the enclave with log/slog.
+// The attributes tracing an update share the same keys in every package, so a single update
+// can be followed from the contract transaction through the signed response to its confirmation.
+// Attributes named after secrets are redacted, in case a secret is ever passed to the logger.
+package elog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the log handler.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Keys of the attributes tracing an update.
+const (
+	KeyTxHash    = "tx_hash"    // Hash of the contract transaction, hex.
+	KeyLT        = "lt"         // Logical time of the contract transaction.
+	KeyCommand   = "command"    // Command emitted by the contract, e.g. "random()".
+	KeyTicker    = "ticker"     // Ticker of the price.
+	KeyRequestID = "request_id" // Request the response answers, e.g. the hash of the draw transaction.
+	KeyOpcode    = "opcode"     // Opcode of the signed response, hex.
+)
+
+// redacted replaces the values of the attributes named after secrets.
+const redacted = "[REDACTED]"
+
+// secretKeys are the substrings of the attribute keys never logged.
+var secretKeys = []string{"mnemonic", "seed", "secret", "private", "password", "api_key", "apikey", "token"}
+
+// Config holds the log handler settings.
+type Config struct {
+	Format string     // FormatText or FormatJSON, the text format is used if not set.
+	Level  slog.Level // Records below the level are dropped.
+}
+
+// ParseFormat validates the log format name.
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case FormatText, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected %s or %s", format, FormatText, FormatJSON)
+}
+
+// NewHandler creates the handler writing records in the configured format.
+func NewHandler(w io.Writer, config Config) slog.Handler {
+	options := &slog.HandlerOptions{Level: config.Level, ReplaceAttr: redact}
+	if config.Format == FormatJSON {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// Setup makes the configured handler the default one, the log package writes through it too.
+func Setup(w io.Writer, config Config) {
+	slog.SetDefault(slog.New(NewHandler(w, config)))
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return attr
+}
+
+// TxHash is the attribute of the contract transaction hash.
+func TxHash(hash []byte) slog.Attr {
+	return slog.String(KeyTxHash, hex.EncodeToString(hash))
+}
+
+// LT is the attribute of the contract transaction logical time.
+func LT(lt uint64) slog.Attr {
+	return slog.Uint64(KeyLT, lt)
+}
+
+// Command is the attribute of the contract command.
+func Command(command string) slog.Attr {
+	return slog.String(KeyCommand, command)
+}
+
+// Ticker is the attribute of the price ticker.
+func Ticker(ticker uint64) slog.Attr {
+	return slog.Uint64(KeyTicker, ticker)
+}
+
+// RequestID is the attribute of the request the response answers.
+func RequestID(id string) slog.Attr {
+	return slog.String(KeyRequestID, id)
+}
+
+// Opcode is the attribute of the response opcode.
+func Opcode(opcode uint32) slog.Attr {
+	return slog.String(KeyOpcode, fmt.Sprintf("0x%08x", opcode))
+}
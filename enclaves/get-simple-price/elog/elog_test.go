@@ -0,0 +1,76 @@
+package elog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewHandler(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := slog.New(NewHandler(&b, Config{Format: FormatJSON}))
+		logger.Info("response signed", TxHash([]byte{0xab, 0xcd}), LT(42), Command("random()"),
+			RequestID("abcd"), Opcode(0xbb15fe7d))
+
+		var record map[string]any
+		if err := json.Unmarshal(b.Bytes(), &record); err != nil {
+			t.Fatalf("Unexpected record %q: %v", b.String(), err)
+		}
+		expected := map[string]any{
+			"msg":        "response signed",
+			KeyTxHash:    "abcd",
+			KeyLT:        float64(42),
+			KeyCommand:   "random()",
+			KeyRequestID: "abcd",
+			KeyOpcode:    "0xbb15fe7d",
+		}
+		for key, value := range expected {
+			if record[key] != value {
+				t.Errorf("Unexpected %s: %v", key, record[key])
+			}
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := slog.New(NewHandler(&b, Config{Format: FormatText}))
+		logger.Info("command detected", Command("reveal()"))
+		if !strings.Contains(b.String(), `msg="command detected" command=reveal()`) {
+			t.Errorf("Unexpected record: %s", b.String())
+		}
+	})
+
+	t.Run("Level", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := slog.New(NewHandler(&b, Config{Level: slog.LevelWarn}))
+		logger.Info("transaction received")
+		if b.Len() != 0 {
+			t.Errorf("Record below the level is logged: %s", b.String())
+		}
+	})
+
+	t.Run("Secrets are redacted", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := slog.New(NewHandler(&b, Config{Format: FormatJSON}))
+		logger.Info("config", "mnemonic", "word1 word2", slog.Group("coingecko", "proApiKey", "CG-secret"), "Token", "t0k3n")
+		for _, secret := range []string{"word1", "CG-secret", "t0k3n"} {
+			if strings.Contains(b.String(), secret) {
+				t.Errorf("Secret %q is logged: %s", secret, b.String())
+			}
+		}
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON} {
+		if _, err := ParseFormat(format); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Unknown format is accepted")
+	}
+}
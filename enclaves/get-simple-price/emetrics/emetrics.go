@@ -0,0 +1,64 @@
+// Package emetrics writes Prometheus metrics of the get-price command for the node exporter textfile collector.
+// Every run replaces the file, so the metrics are gauges of the price fetches of the last run,
+// rather than counters and histograms accumulated by a long-running process.
+package emetrics
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+// Price holds the price fetches of the run. The nil *Price records nothing, so metrics are optional.
+type Price struct {
+	registry  *prometheus.Registry
+	latency   *prometheus.GaugeVec
+	success   *prometheus.GaugeVec
+	fetchTime *prometheus.GaugeVec
+}
+
+// NewPrice creates a new Price.
+func NewPrice() *Price {
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+	return &Price{
+		registry: registry,
+		latency: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "enclave_price_fetch_latency_seconds",
+			Help: "Time of the last price request, by price source.",
+		}, []string{"source"}),
+		success: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "enclave_price_fetch_success",
+			Help: "Whether the last price request succeeded, by price source.",
+		}, []string{"source"}),
+		fetchTime: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "enclave_price_fetch_time_seconds",
+			Help: "Time of the last successful price request, as a Unix time, by price source.",
+		}, []string{"source"}),
+	}
+}
+
+// Fetched records the price request to the source started at start, err is its result.
+func (m *Price) Fetched(source string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.latency.WithLabelValues(source).Set(time.Since(start).Seconds())
+	if err != nil {
+		m.success.WithLabelValues(source).Set(0)
+		m.fetchTime.DeleteLabelValues(source)
+		return
+	}
+	m.success.WithLabelValues(source).Set(1)
+	m.fetchTime.WithLabelValues(source).SetToCurrentTime()
+}
+
+// WriteFile writes the metrics to the file for the node exporter textfile collector.
+// The file is replaced atomically, so the collector doesn't read it partially written.
+func (m *Price) WriteFile(path string) error {
+	if err := prometheus.WriteToTextfile(path, m.registry); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	return nil
+}
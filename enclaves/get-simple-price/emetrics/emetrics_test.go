@@ -0,0 +1,48 @@
+package emetrics
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	metrics := NewPrice()
+	metrics.Fetched("binance", time.Now(), nil)
+	metrics.Fetched("coingecko", time.Now(), nil)
+	metrics.Fetched("coingecko", time.Now(), errors.New("Unexpected status code: 429"))
+
+	path := filepath.Join(t.TempDir(), "price.prom")
+	if err := metrics.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE enclave_price_fetch_latency_seconds gauge",
+		`enclave_price_fetch_success{source="binance"} 1`,
+		`enclave_price_fetch_success{source="coingecko"} 0`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Missing %q in:\n%s", line, data)
+		}
+	}
+	// The last request to the source failed, its success time is not reported.
+	if !strings.Contains(string(data), `enclave_price_fetch_time_seconds{source="binance"}`) ||
+		strings.Contains(string(data), `enclave_price_fetch_time_seconds{source="coingecko"}`) {
+		t.Errorf("Unexpected fetch times:\n%s", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Temporary file is left: %v", entries)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var disabled *Price
+		disabled.Fetched("coingecko", time.Now(), nil)
+	})
+}
@@ -0,0 +1,71 @@
+// Package epreflight provides the startup check that the contract trusts the running enclave:
+// the contract must check signatures with the enclave signature key and attest the enclave measurement.
+// Otherwise every enclave update fails the contract signature check, so the enclave refuses to start.
+package epreflight
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/esign"
+)
+
+var (
+	ErrKeyMismatch         = errors.New("enclave public key mismatch")
+	ErrMeasurementMismatch = errors.New("enclave measurement mismatch")
+)
+
+// Contract reads the enclave identity trusted by the contract, e.g. econtract.Contract.
+type Contract interface {
+	EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error)
+	EnclaveMeasurement(ctx context.Context) ([]byte, error)
+}
+
+// Identity is the identity of the running enclave.
+type Identity struct {
+	PublicKey ed25519.PublicKey // The public key of the enclave signature key.
+	UniqueID  []byte            // The enclave measurement from the SGX report.
+}
+
+// SelfIdentity returns the identity of the running enclave: the sealed signature key and the self report UniqueID.
+func SelfIdentity(keys econf.KeysConfig, attest eattest.Attestation) (Identity, error) {
+	key, err := esign.GetSignatureKey(keys)
+	if err != nil {
+		return Identity{}, fmt.Errorf("can't load the signature key: %w", err)
+	}
+	report, err := attest.GetSelfReport()
+	if err != nil {
+		return Identity{}, fmt.Errorf("can't get the enclave report: %w", err)
+	}
+	return Identity{PublicKey: key.GetPublicKey(), UniqueID: report.UniqueID}, nil
+}
+
+// Check compares the identity trusted by the contract with the running enclave one.
+// The error explains the mismatch and wraps ErrKeyMismatch or ErrMeasurementMismatch.
+func Check(ctx context.Context, contract Contract, identity Identity) error {
+	contractKey, err := contract.EnclavePublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	measurement, err := contract.EnclaveMeasurement(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if !bytes.Equal(contractKey, identity.PublicKey) {
+		errs = append(errs, fmt.Errorf("%w: the contract checks signatures with %x, the enclave signs with %x; "+
+			"the sealed signature key is not the one the contract is deployed with, import the key or redeploy the contract",
+			ErrKeyMismatch, []byte(contractKey), []byte(identity.PublicKey)))
+	}
+	if !bytes.Equal(measurement, identity.UniqueID) {
+		errs = append(errs, fmt.Errorf("%w: the contract attests %x, the running enclave is %x; "+
+			"the enclave build differs from the attested one, run the attested build or redeploy the contract with a new report",
+			ErrMeasurementMismatch, measurement, identity.UniqueID))
+	}
+	return errors.Join(errs...)
+}
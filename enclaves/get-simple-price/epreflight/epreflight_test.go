@@ -0,0 +1,61 @@
+package epreflight
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+// fakeContract returns the enclave identity of the contract.
+type fakeContract struct {
+	key         ed25519.PublicKey
+	measurement []byte
+	err         error
+}
+
+func (c fakeContract) EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	return c.key, c.err
+}
+
+func (c fakeContract) EnclaveMeasurement(ctx context.Context) ([]byte, error) {
+	return c.measurement, c.err
+}
+
+func TestCheck(t *testing.T) {
+	key := ed25519.PublicKey(bytes.Repeat([]byte{1}, 32))
+	otherKey := ed25519.PublicKey(bytes.Repeat([]byte{2}, 32))
+	uniqueID := bytes.Repeat([]byte{3}, 32)
+	otherID := bytes.Repeat([]byte{4}, 32)
+	identity := Identity{PublicKey: key, UniqueID: uniqueID}
+	getterErr := errors.New("getter error")
+
+	tests := []struct {
+		name     string
+		contract fakeContract
+		expected []error
+	}{
+		{"Match", fakeContract{key: key, measurement: uniqueID}, nil},
+		{"Key mismatch", fakeContract{key: otherKey, measurement: uniqueID}, []error{ErrKeyMismatch}},
+		{"Measurement mismatch", fakeContract{key: key, measurement: otherID}, []error{ErrMeasurementMismatch}},
+		{"Both mismatch", fakeContract{key: otherKey, measurement: otherID}, []error{ErrKeyMismatch, ErrMeasurementMismatch}},
+		{"Getter error", fakeContract{err: getterErr}, []error{getterErr}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(context.Background(), tt.contract, identity)
+			if tt.expected == nil && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.expected != nil && err == nil {
+				t.Fatal("Expected error not raised")
+			}
+			for _, expected := range tt.expected {
+				if !errors.Is(err, expected) {
+					t.Errorf("Error %v is not %v", err, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package esecrets provides import and sealed storage of application secrets, such as API keys.
+// Secrets are delivered encrypted to the enclave's public encryption key
+// and stored on the mount sealed to the enclave.
+package esecrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tonteeton/golib/ekeys"
+	"os"
+)
+
+// Config represents configuration for importing and storing secrets.
+type Config struct {
+	ImportPath string
+	SealedPath string
+	Version    string
+}
+
+// Secrets maps secret names (e.g. COINGECKO_API_KEY) to their values.
+type Secrets map[string]string
+
+// Decrypter decrypts a message encrypted to the enclave's public encryption key.
+// It is implemented by ebox.BoxKey.
+type Decrypter interface {
+	Decrypt(encryptedMsg []byte, senderPublicKey []byte) ([]byte, error)
+}
+
+// ImportRequest represents the secrets import file.
+// Payload is a JSON-encoded Secrets object, encrypted with NaCl Box
+// to the enclave's public encryption key by the sender with PublicKey.
+type ImportRequest struct {
+	PublicKey string `json:"publicKey"`
+	Payload   string `json:"payload"`
+}
+
+// ImportSecrets decrypts secrets from the import file and seals them to the mount.
+// Previously sealed secrets are replaced.
+func ImportSecrets(cfg Config, box Decrypter, sealers ...ekeys.DataSealer) error {
+	data, err := os.ReadFile(cfg.ImportPath)
+	if err != nil {
+		return err
+	}
+	secrets, err := DecryptSecrets(data, box)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	return ekeys.WriteEncryptedFile(cfg.SealedPath, plaintext, []byte(cfg.Version), sealers...)
+}
+
+// DecryptSecrets decodes the import request and decrypts secrets from it.
+func DecryptSecrets(data []byte, box Decrypter) (Secrets, error) {
+	var req ImportRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid import request: %w", err)
+	}
+	senderKey, err := base64.StdEncoding.DecodeString(req.PublicKey)
+	if err != nil {
+		return nil, errors.New("invalid sender public key encoding")
+	}
+	payload, err := base64.StdEncoding.DecodeString(req.Payload)
+	if err != nil {
+		return nil, errors.New("invalid payload encoding")
+	}
+	plaintext, err := box.Decrypt(payload, senderKey)
+	if err != nil {
+		return nil, err
+	}
+	var secrets Secrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, errors.New("invalid secrets format")
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("no secrets to import")
+	}
+	return secrets, nil
+}
+
+// LoadSecrets unseals secrets from the mount.
+// It returns empty Secrets if no secrets have been imported.
+func LoadSecrets(cfg Config, unsealers ...ekeys.DataSealer) (Secrets, error) {
+	plaintext, err := ekeys.ReadEncryptedFile(cfg.SealedPath, []byte(cfg.Version), unsealers...)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Secrets{}, nil
+		}
+		return nil, err
+	}
+	var secrets Secrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, errors.New("invalid sealed secrets format")
+	}
+	return secrets, nil
+}
+
+// GetOrEnv returns the sealed secret value by name.
+// If the secret is not sealed, it falls back to the environment variable
+// with the same name, which is intended for development only.
+func (secrets Secrets) GetOrEnv(name string) string {
+	if value, ok := secrets[name]; ok {
+		return value
+	}
+	return os.Getenv(name)
+}
@@ -0,0 +1,101 @@
+package esecrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// plainBox is a test Decrypter that returns the message as is for the known sender.
+type plainBox struct {
+	senderKey []byte
+}
+
+func (box plainBox) Decrypt(encryptedMsg []byte, senderPublicKey []byte) ([]byte, error) {
+	if !bytes.Equal(box.senderKey, senderPublicKey) {
+		return nil, errors.New("decryption error")
+	}
+	return encryptedMsg, nil
+}
+
+func noSeal(data []byte, additionalData []byte) ([]byte, error) {
+	return data, nil
+}
+
+func newImportRequest(senderKey []byte, payload string) []byte {
+	return []byte(`{"publicKey":"` + base64.StdEncoding.EncodeToString(senderKey) +
+		`","payload":"` + base64.StdEncoding.EncodeToString([]byte(payload)) + `"}`)
+}
+
+func TestImportSecrets(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		ImportPath: filepath.Join(dir, "secrets_import.json"),
+		SealedPath: filepath.Join(dir, "secrets.enc"),
+		Version:    "test",
+	}
+	senderKey := bytes.Repeat([]byte{1}, 32)
+
+	t.Run("Not imported", func(t *testing.T) {
+		secrets, err := LoadSecrets(cfg, noSeal)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(secrets) != 0 {
+			t.Errorf("Unexpected secrets: %v", secrets)
+		}
+	})
+
+	t.Run("Import and load", func(t *testing.T) {
+		req := newImportRequest(senderKey, `{"COINGECKO_API_KEY":"demo"}`)
+		if err := os.WriteFile(cfg.ImportPath, req, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ImportSecrets(cfg, plainBox{senderKey}, noSeal); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		secrets, err := LoadSecrets(cfg, noSeal)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if secrets["COINGECKO_API_KEY"] != "demo" {
+			t.Errorf("Unexpected secrets: %v", secrets)
+		}
+	})
+
+	t.Run("Sealed value has priority over env", func(t *testing.T) {
+		t.Setenv("COINGECKO_API_KEY", "env")
+		t.Setenv("COINGECKO_PRO_API_KEY", "env-pro")
+		secrets := Secrets{"COINGECKO_API_KEY": "sealed"}
+		if got := secrets.GetOrEnv("COINGECKO_API_KEY"); got != "sealed" {
+			t.Errorf("Unexpected value: %v", got)
+		}
+		if got := secrets.GetOrEnv("COINGECKO_PRO_API_KEY"); got != "env-pro" {
+			t.Errorf("Unexpected fallback value: %v", got)
+		}
+	})
+}
+
+func TestDecryptSecretsInvalid(t *testing.T) {
+	senderKey := bytes.Repeat([]byte{1}, 32)
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"Not JSON", []byte("secrets")},
+		{"Invalid key encoding", []byte(`{"publicKey":"!","payload":""}`)},
+		{"Unknown sender", newImportRequest(bytes.Repeat([]byte{2}, 32), `{"A":"B"}`)},
+		{"Invalid payload", newImportRequest(senderKey, `["A"]`)},
+		{"Empty payload", newImportRequest(senderKey, `{}`)},
+	}
+	for _, tcase := range cases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if _, err := DecryptSecrets(tcase.data, plainBox{senderKey}); err == nil {
+				t.Errorf("Expected error not raised")
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package estatus provides the status report of the enclave deployment:
+// the price contract state, balance and last price, and the enclave identity trusted by the contract.
+// The report is collected on a best-effort basis, so a failing getter doesn't hide the rest of the status.
+package estatus
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"enclave/econtract"
+	"enclave/priceresp"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/esign"
+	"github.com/xssnick/tonutils-go/tlb"
+	"io"
+	"time"
+)
+
+// Contract reads the price contract, e.g. econtract.Contract.
+type Contract interface {
+	EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error)
+	EnclaveMeasurement(ctx context.Context) ([]byte, error)
+	State(ctx context.Context) (econtract.OracleState, error)
+	Balance(ctx context.Context) (tlb.Coins, error)
+	Price(ctx context.Context, ticker uint64) (*priceresp.Price, error)
+	LastTxLT(ctx context.Context) (uint64, error)
+}
+
+// Sources are the status report sources.
+type Sources struct {
+	Contract    Contract
+	Address     string              // The contract address.
+	Ticker      uint64              // The ticker of the reported price.
+	Keys        econf.KeysConfig    // The enclave signature keys.
+	Attestation eattest.Attestation // The enclave self report.
+}
+
+// Report is the status report. Balances are in nanotons in the JSON output.
+type Report struct {
+	Contract ContractStatus `json:"contract"`
+	Price    *PriceStatus   `json:"price,omitempty"`
+	Enclave  EnclaveStatus  `json:"enclave"`
+	Errors   []string       `json:"errors,omitempty"`
+}
+
+// ContractStatus is the contract state() report and account state.
+type ContractStatus struct {
+	Address     string     `json:"address"`
+	Name        string     `json:"name,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	PrevAddress string     `json:"prevAddress,omitempty"`
+	NewAddress  string     `json:"newAddress,omitempty"`
+	Moved       bool       `json:"moved"`
+	Stopped     bool       `json:"stopped"`
+	Balance     *tlb.Coins `json:"balance,omitempty"`
+	LastTxLT    uint64     `json:"lastTxLT"` // The last contract transaction, the last price update when it's the enclave one.
+}
+
+// PriceStatus is the last price update of the ticker in the contract, prices are in cents.
+type PriceStatus struct {
+	Ticker        uint64 `json:"ticker"`
+	LastUpdatedAt uint64 `json:"lastUpdatedAt"`
+	USD           uint64 `json:"usd"`
+	USD24HVol     uint64 `json:"usd24hVol"`
+	USD24HChange  int64  `json:"usd24hChange"`
+	BTC           uint64 `json:"btc"`
+}
+
+// EnclaveStatus is the running enclave identity and the one trusted by the contract.
+type EnclaveStatus struct {
+	PublicKey           string `json:"publicKey,omitempty"`
+	UniqueID            string `json:"uniqueId,omitempty"`
+	ContractPublicKey   string `json:"contractPublicKey,omitempty"`
+	ContractMeasurement string `json:"contractMeasurement,omitempty"`
+	KeyMatch            bool   `json:"keyMatch"`
+	MeasurementMatch    bool   `json:"measurementMatch"`
+}
+
+// Collect collects the status report, errors of the sources are reported in Report.Errors.
+func Collect(ctx context.Context, src Sources) Report {
+	var report Report
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	contract := src.Contract
+
+	report.Contract.Address = src.Address
+	if state, err := contract.State(ctx); err == nil {
+		report.Contract.Name = state.Name
+		report.Contract.Version = state.VersionString()
+		report.Contract.Owner = state.Owner.String()
+		if state.PrevAddress != nil {
+			report.Contract.PrevAddress = state.PrevAddress.String()
+		}
+		report.Contract.NewAddress = state.NewAddress.String()
+		report.Contract.Moved = state.Moved
+		report.Contract.Stopped = state.Stopped
+	} else {
+		fail(err)
+	}
+	if balance, err := contract.Balance(ctx); err == nil {
+		report.Contract.Balance = &balance
+	} else {
+		fail(err)
+	}
+	if lt, err := contract.LastTxLT(ctx); err == nil {
+		report.Contract.LastTxLT = lt
+	} else {
+		fail(err)
+	}
+	if price, err := contract.Price(ctx, src.Ticker); err != nil {
+		fail(err)
+	} else if price != nil {
+		report.Price = &PriceStatus{
+			Ticker:        price.Ticker,
+			LastUpdatedAt: price.LastUpdatedAt,
+			USD:           price.USD,
+			USD24HVol:     price.USD24HVol,
+			USD24HChange:  price.USD24HChange,
+			BTC:           price.BTC,
+		}
+	} else {
+		fail(fmt.Errorf("price(): no price for ticker %#x", src.Ticker))
+	}
+
+	// The key and the measurement are read separately, so the key is reported outside SGX as well.
+	var publicKey, uniqueID []byte
+	if key, err := esign.GetSignatureKey(src.Keys); err == nil {
+		publicKey = key.GetPublicKey()
+		report.Enclave.PublicKey = hex.EncodeToString(publicKey)
+	} else {
+		fail(fmt.Errorf("can't load the signature key: %w", err))
+	}
+	if selfReport, err := src.Attestation.GetSelfReport(); err == nil {
+		uniqueID = selfReport.UniqueID
+		report.Enclave.UniqueID = hex.EncodeToString(uniqueID)
+	} else {
+		fail(fmt.Errorf("can't get the enclave report: %w", err))
+	}
+	if key, err := contract.EnclavePublicKey(ctx); err == nil {
+		report.Enclave.ContractPublicKey = hex.EncodeToString(key)
+		report.Enclave.KeyMatch = publicKey != nil && bytes.Equal(key, publicKey)
+	} else {
+		fail(err)
+	}
+	if measurement, err := contract.EnclaveMeasurement(ctx); err == nil {
+		report.Enclave.ContractMeasurement = hex.EncodeToString(measurement)
+		report.Enclave.MeasurementMatch = uniqueID != nil && bytes.Equal(measurement, uniqueID)
+	} else {
+		fail(err)
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report for humans.
+func (r Report) WriteText(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Contract %s\n", r.Contract.Address)
+	fmt.Fprintf(&b, "  name:        %s %s\n", orUnknown(r.Contract.Name), r.Contract.Version)
+	fmt.Fprintf(&b, "  owner:       %s\n", orUnknown(r.Contract.Owner))
+	fmt.Fprintf(&b, "  moved:       %t, new address %s\n", r.Contract.Moved, orUnknown(r.Contract.NewAddress))
+	if r.Contract.PrevAddress != "" {
+		fmt.Fprintf(&b, "  moved from:  %s\n", r.Contract.PrevAddress)
+	}
+	fmt.Fprintf(&b, "  stopped:     %t\n", r.Contract.Stopped)
+	fmt.Fprintf(&b, "  balance:     %s\n", formatCoins(r.Contract.Balance))
+	fmt.Fprintf(&b, "  last tx LT:  %d\n", r.Contract.LastTxLT)
+	if p := r.Price; p != nil {
+		fmt.Fprintf(&b, "Price %#x\n", p.Ticker)
+		fmt.Fprintf(&b, "  updated:     %s\n", time.Unix(int64(p.LastUpdatedAt), 0).UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "  usd:         %d.%02d, 24h change %.2f%%\n", p.USD/100, p.USD%100, float64(p.USD24HChange)/100)
+		fmt.Fprintf(&b, "  btc:         %d\n", p.BTC)
+	}
+	fmt.Fprintf(&b, "Enclave\n")
+	fmt.Fprintf(&b, "  public key:  %s (%s)\n", orUnknown(r.Enclave.PublicKey),
+		formatMatch(r.Enclave.KeyMatch, r.Enclave.ContractPublicKey))
+	fmt.Fprintf(&b, "  unique ID:   %s (%s)\n", orUnknown(r.Enclave.UniqueID),
+		formatMatch(r.Enclave.MeasurementMatch, r.Enclave.ContractMeasurement))
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Errors\n")
+		for _, err := range r.Errors {
+			fmt.Fprintf(&b, "  %s\n", err)
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+func formatCoins(coins *tlb.Coins) string {
+	if coins == nil {
+		return "unknown"
+	}
+	return coins.String() + " TON"
+}
+
+func formatMatch(match bool, contractValue string) string {
+	switch {
+	case match:
+		return "trusted by the contract"
+	case contractValue == "":
+		return "contract value unknown"
+	}
+	return "MISMATCH, the contract trusts " + contractValue
+}
@@ -0,0 +1,146 @@
+package estatus
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"enclave/econtract"
+	"enclave/priceresp"
+	"encoding/json"
+	"errors"
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/econf"
+	"github.com/tonteeton/golib/esign"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testOwner = address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+
+// fakeContract returns the contract state, err fails all getters.
+type fakeContract struct {
+	key         ed25519.PublicKey
+	measurement []byte
+	price       *priceresp.Price
+	err         error
+}
+
+func (c fakeContract) EnclavePublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	return c.key, c.err
+}
+
+func (c fakeContract) EnclaveMeasurement(ctx context.Context) ([]byte, error) {
+	return c.measurement, c.err
+}
+
+func (c fakeContract) State(ctx context.Context) (econtract.OracleState, error) {
+	state := econtract.OracleState{Name: "get-simple-price", Version: 1<<24 | 1<<16, Owner: testOwner, NewAddress: testOwner, Stopped: true}
+	return state, c.err
+}
+
+func (c fakeContract) Balance(ctx context.Context) (tlb.Coins, error) {
+	return tlb.MustFromTON("1.5"), c.err
+}
+
+func (c fakeContract) Price(ctx context.Context, ticker uint64) (*priceresp.Price, error) {
+	return c.price, c.err
+}
+
+func (c fakeContract) LastTxLT(ctx context.Context) (uint64, error) {
+	return 42, c.err
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	keys := econf.KeysConfig{
+		PublicKeyPath:  filepath.Join(dir, "public.key"),
+		PrivateKeyPath: filepath.Join(dir, "private.key"),
+		SealedDatePath: filepath.Join(dir, "date.enc"),
+		Version:        "test",
+	}
+	key, err := esign.GetSignatureKey(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uniqueID := bytes.Repeat([]byte{3}, 32)
+	price := &priceresp.Price{LastUpdatedAt: 1715092161, Ticker: 0x72716023, USD: 345, USD24HChange: -1566, BTC: 10967}
+
+	src := Sources{
+		Contract: fakeContract{key: key.GetPublicKey(), measurement: bytes.Repeat([]byte{4}, 32), price: price},
+		Address:  "EQDtFpEwcFAEcRe5mLVh2N6C0x-_hJEM7W61_JLnSF74p4q2",
+		Ticker:   0x72716023,
+		Keys:     keys,
+		Attestation: eattest.Attestation{
+			GetSelfReport: func() (attestation.Report, error) {
+				return attestation.Report{UniqueID: uniqueID}, nil
+			},
+		},
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		report := Collect(context.Background(), src)
+		if len(report.Errors) != 0 {
+			t.Fatalf("Unexpected errors: %v", report.Errors)
+		}
+		if report.Contract.Version != "1.1.0" || !report.Contract.Stopped || report.Contract.LastTxLT != 42 {
+			t.Errorf("Unexpected contract status: %+v", report.Contract)
+		}
+		if report.Price == nil || report.Price.USD != 345 {
+			t.Errorf("Unexpected price status: %+v", report.Price)
+		}
+		if !report.Enclave.KeyMatch || report.Enclave.MeasurementMatch {
+			t.Errorf("Unexpected enclave status: %+v", report.Enclave)
+		}
+
+		var text bytes.Buffer
+		if err := report.WriteText(&text); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"stopped:     true", "1.5 TON", "3.45, 24h change -15.66%", "trusted by the contract", "MISMATCH"} {
+			if !strings.Contains(text.String(), expected) {
+				t.Errorf("%q is not in the text report:\n%s", expected, text.String())
+			}
+		}
+
+		var out bytes.Buffer
+		if err := report.WriteJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		var decoded Report
+		if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Contract.Balance.Nano().Int64() != 1_500_000_000 || *decoded.Price != *report.Price {
+			t.Errorf("Unexpected JSON report: %s", out.String())
+		}
+	})
+
+	t.Run("Errors are reported", func(t *testing.T) {
+		failing := src
+		failing.Contract = fakeContract{err: errors.New("liteserver error")}
+		failing.Attestation.GetSelfReport = func() (attestation.Report, error) {
+			return attestation.Report{}, errors.New("not in an enclave")
+		}
+		report := Collect(context.Background(), failing)
+		if report.Enclave.PublicKey == "" || report.Enclave.UniqueID != "" || report.Price != nil {
+			t.Errorf("Unexpected status: %+v", report)
+		}
+		// Six getters and the self report.
+		if len(report.Errors) != 7 {
+			t.Errorf("Unexpected errors: %v", report.Errors)
+		}
+	})
+
+	t.Run("No price", func(t *testing.T) {
+		noPrice := src
+		noPrice.Contract = fakeContract{key: key.GetPublicKey()}
+		report := Collect(context.Background(), noPrice)
+		if report.Price != nil || len(report.Errors) != 1 {
+			t.Errorf("Unexpected status: %+v", report)
+		}
+	})
+}
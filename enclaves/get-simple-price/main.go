@@ -1,43 +1,148 @@
 package main
 
 import (
+	"context"
 	"enclave/appconf"
 	"enclave/coinconv"
 	"enclave/coingecko"
+	"enclave/econtract"
+	"enclave/elog"
+	"enclave/emetrics"
+	"enclave/epreflight"
+	"enclave/esecrets"
+	"enclave/estatus"
 	"enclave/priceresp"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/tonteeton/golib/eattest"
+	"github.com/tonteeton/golib/ebox"
 	"github.com/tonteeton/golib/ereport"
 	"github.com/tonteeton/golib/eresp"
+	"log/slog"
 	"os"
+	"time"
 )
 
-func getPrice(cfg *appconf.Config) error {
+func getPrice(cfg *appconf.Config, args []string) error {
+	flags := flag.NewFlagSet("get-price", flag.ContinueOnError)
+	metricsFile := flags.String("metrics-file", "", "Write the price fetch metrics to the file for the node exporter textfile collector")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := preflight(cfg); err != nil {
+		return err
+	}
+
+	var metrics *emetrics.Price
+	if *metricsFile != "" {
+		metrics = emetrics.NewPrice()
+		defer func() {
+			if err := metrics.WriteFile(*metricsFile); err != nil {
+				slog.Warn("metrics are not written", "error", err)
+			}
+		}()
+	}
+
+	logger := slog.With(elog.Ticker(cfg.Tickers.TON))
 	gecko := coingecko.NewGecko(
 		cfg.CoinGecko.DemoKey,
 		cfg.CoinGecko.ProKey,
 	)
 
-	geckoPrice, err := gecko.GetTONPrice()
+	start := time.Now()
+	geckoPrice, err := gecko.GetPrice(cfg.CoinGecko.TONCoinID)
+	metrics.Fetched("coingecko", start, err)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%+v\n", geckoPrice)
+	logger.Info("price fetched", "source", "coingecko", "coin_id", cfg.CoinGecko.TONCoinID,
+		"usd", geckoPrice.USD, "btc", geckoPrice.BTC, "last_updated_at", geckoPrice.LastUpdatedAt,
+		"latency", time.Since(start))
 
 	var price priceresp.Price
-	price = coinconv.ConvertPrice(geckoPrice.TON, cfg.Tickers.TON)
-	if err := coinconv.ValidatePrice(price); err != nil {
+	price = coinconv.ConvertPrice(geckoPrice, cfg.Tickers.TON)
+	limits := coinconv.Limits{
+		MaxAge:    cfg.Validation.MaxPriceAge,
+		MaxChange: cfg.Validation.MaxChangePercent * 1e2,
+	}
+	if err := limits.Validate(price); err != nil {
 		return err
 	}
-	fmt.Printf("%+v\n", price)
+	logger.Info("price validated", "usd", price.USD, "usd_24h_vol", price.USD24HVol,
+		"usd_24h_change", price.USD24HChange, "btc", price.BTC, "last_updated_at", price.LastUpdatedAt)
 
 	responseCfg := eresp.Config{
 		Response:      cfg.Response,
 		SignatureKeys: cfg.SignatureKeys,
 	}
-	return eresp.SaveResponse(responseCfg, price.ToCell())
+	if err := eresp.SaveResponse(responseCfg, price.ToCell()); err != nil {
+		return err
+	}
+	logger.Info("response signed", "path", cfg.Response.ResponsePath)
+	return nil
+}
+
+// preflight refuses to start if the price contract doesn't trust the running enclave,
+// otherwise every price update would fail the contract signature check.
+func preflight(cfg *appconf.Config) error {
+	if cfg.Network.ContractAddress == nil {
+		return errors.New("preflight: network.contractAddress is not set (TON_CONTRACT_ADDRESS env), " +
+			"the price contract must be checked to trust the enclave")
+	}
+	ctx := context.Background()
+	identity, err := epreflight.SelfIdentity(cfg.SignatureKeys, eattest.NewAttestation())
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	contract, err := econtract.Connect(ctx, cfg.Network.GlobalConfig, cfg.Network.ContractAddress)
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	if err := epreflight.Check(ctx, contract, identity); err != nil {
+		return fmt.Errorf("preflight: contract %s doesn't trust this enclave: %w", cfg.Network.ContractAddress, err)
+	}
+	slog.Info("preflight: contract trusts the enclave",
+		"public_key", hex.EncodeToString(identity.PublicKey), "measurement", hex.EncodeToString(identity.UniqueID))
+	return nil
+}
+
+// status prints the price contract and enclave status, for humans or as JSON.
+func status(cfg *appconf.Config, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Print the status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Network.ContractAddress == nil {
+		return errors.New("status: network.contractAddress is not set")
+	}
+
+	ctx := context.Background()
+	contract, err := econtract.Connect(ctx, cfg.Network.GlobalConfig, cfg.Network.ContractAddress)
+	if err != nil {
+		return err
+	}
+	report := estatus.Collect(ctx, estatus.Sources{
+		Contract:    contract,
+		Address:     cfg.Network.ContractAddress.String(),
+		Ticker:      cfg.Tickers.TON,
+		Keys:        cfg.SignatureKeys,
+		Attestation: eattest.NewAttestation(),
+	})
+	if *jsonOutput {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteText(os.Stdout)
+}
+
+func importSecret(cfg *appconf.Config) error {
+	box, err := ebox.GetBoxKey(cfg.EncryptionKeys)
+	if err != nil {
+		return err
+	}
+	return esecrets.ImportSecrets(cfg.Secrets, box)
 }
 
 func executeReportFunc(fn func(ereport.Config, eattest.Attestation) error, cfg *appconf.Config) error {
@@ -56,21 +161,28 @@ func main() {
 		fmt.Println("Error loading config:", err)
 		os.Exit(1)
 	}
+	elog.Setup(os.Stderr, cfg.Log)
 
 	flag.Usage = func() {
 		fmt.Println("Usage: [command]")
 		fmt.Println("Commands:")
 		fmt.Println("  get-price        Get the TON price")
+		fmt.Println("    --metrics-file Write the price fetch metrics for the node exporter textfile collector")
+		fmt.Println("  status           Print the price contract and enclave status")
+		fmt.Println("    --json         Print the status as JSON")
 		fmt.Println("  report-key       Generate SGX-signed report with public keys")
 		fmt.Println("  import-key       Import encrypted signature Private key")
 		fmt.Println("  export-key       Export encrypted signature Private key")
+		fmt.Println("  import-secret    Import encrypted API keys and seal them to the mount")
 	}
 
 	cmds := map[string]func(cfg *appconf.Config) error{
-		"get-price":  getPrice,
-		"report-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPublicKeys, cfg) },
-		"import-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ImportPrivateSignature, cfg) },
-		"export-key": func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPrivateSignature, cfg) },
+		"get-price":     func(cfg *appconf.Config) error { return getPrice(cfg, os.Args[2:]) },
+		"status":        func(cfg *appconf.Config) error { return status(cfg, os.Args[2:]) },
+		"report-key":    func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPublicKeys, cfg) },
+		"import-key":    func(cfg *appconf.Config) error { return executeReportFunc(ereport.ImportPrivateSignature, cfg) },
+		"export-key":    func(cfg *appconf.Config) error { return executeReportFunc(ereport.ExportPrivateSignature, cfg) },
+		"import-secret": importSecret,
 	}
 
 	if len(os.Args) < 2 {
@@ -87,7 +199,7 @@ func main() {
 	}
 
 	if err != nil {
-		fmt.Println("Error:", err)
+		slog.Error("command failed", "cmd", os.Args[1], "error", err)
 		os.Exit(1)
 	}
 }
@@ -6,11 +6,14 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/edgelesssys/ego/attestation"
 	"github.com/edgelesssys/ego/attestation/tcbstatus"
@@ -34,6 +37,54 @@ func getContractMethod(api ton.APIClientWrapped, contractAddress *address.Addres
 	)
 }
 
+// networkConfig contains parameters for connecting to the TON network.
+type networkConfig struct {
+	globalConfig  string                  // URL or local file path of the global network config.
+	liteServer    string                  // Liteserver address (host:port) to use instead of the global config ones.
+	liteServerKey string                  // Base64-encoded public key of the liteServer.
+	trustedBlock  *liteclient.ConfigBlock // Key block to trust instead of the global config init block.
+}
+
+func loadGlobalConfig(ctx context.Context, location string) (*liteclient.GlobalConfig, error) {
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		return liteclient.GetConfigFromUrl(ctx, location)
+	}
+	return liteclient.GetConfigFromFile(location)
+}
+
+func connect(ctx context.Context, cfg networkConfig) (ton.APIClientWrapped, error) {
+	var globalConfig *liteclient.GlobalConfig
+	if cfg.globalConfig != "" {
+		var err error
+		globalConfig, err = loadGlobalConfig(ctx, cfg.globalConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error loading global config: %w", err)
+		}
+	}
+
+	client := liteclient.NewConnectionPool()
+	if cfg.liteServer != "" {
+		if err := client.AddConnection(ctx, cfg.liteServer, cfg.liteServerKey); err != nil {
+			return nil, fmt.Errorf("error adding liteserver connection: %w", err)
+		}
+	} else if globalConfig != nil {
+		if err := client.AddConnectionsFromConfig(ctx, globalConfig); err != nil {
+			return nil, fmt.Errorf("error adding connections from config: %w", err)
+		}
+	} else {
+		return nil, errors.New("neither global config nor liteserver is specified")
+	}
+
+	api := ton.NewAPIClient(client).WithRetry()
+	if cfg.trustedBlock != nil {
+		block := ton.BlockIDExt(*cfg.trustedBlock)
+		api.SetTrustedBlock(&block)
+	} else if globalConfig != nil {
+		api.SetTrustedBlockFromConfig(globalConfig)
+	}
+	return api, nil
+}
+
 func decodeReport(encodedData string) ([]byte, error) {
 	decodedBase64, err := base64.StdEncoding.DecodeString(encodedData)
 	if err != nil {
@@ -94,35 +145,68 @@ func verifyReportFields(report attestation.Report) error {
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		log.Fatalln("Usage: <contractAddress> <expectedMeasurement>")
-	}
-
-	attestationAddress := os.Args[1]
-	expectedMeasurement := os.Args[2]
+	var network networkConfig
+	var trustedBlock string
+	var tonCenterURL string
+	var tonCenterAPI string
+	flag.StringVar(&network.globalConfig, "config", "", "URL or local file path of the global network config")
+	flag.StringVar(&network.liteServer, "liteserver", "", "liteserver address (host:port) to use instead of the global config ones")
+	flag.StringVar(&network.liteServerKey, "liteserver-key", "", "base64-encoded public key of the liteserver")
+	flag.StringVar(&trustedBlock, "trusted-block", "", "key block to trust, JSON in the global config init_block format")
+	flag.StringVar(&tonCenterURL, "toncenter", "", "base URL of a toncenter-compatible HTTP API to use instead of liteservers (API key in TONCENTER_API_KEY env)")
+	flag.StringVar(&tonCenterAPI, "toncenter-api", tonCenterV2, "version of the toncenter HTTP API: v2 or v3")
+	flag.Usage = func() {
+		fmt.Println("Usage: [options] <contractAddress> <expectedMeasurement>")
+		fmt.Println("Options:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	attestationAddress := flag.Arg(0)
+	expectedMeasurement := flag.Arg(1)
 
 	parsedAddress := address.MustParseAddr(attestationAddress)
-	var configURL string
-	if parsedAddress.IsTestnetOnly() {
-		configURL = "https://ton-blockchain.github.io/testnet-global.config.json"
+	var getMethod func(methodName string) (*ton.ExecutionResult, error)
+	if tonCenterURL != "" {
+		apiKey := os.Getenv("TONCENTER_API_KEY")
+		getMethod = func(methodName string) (*ton.ExecutionResult, error) {
+			return getTonCenterMethod(context.Background(), tonCenterURL, apiKey, tonCenterAPI, parsedAddress, methodName)
+		}
 	} else {
-		configURL = "https://ton.org/global.config.json"
-	}
-
-	client := liteclient.NewConnectionPool()
-	err := client.AddConnectionsFromConfigUrl(context.Background(), configURL)
-	if err != nil {
-		log.Fatalf("error adding connections from config URL: %v", err)
-	}
-
-	api := ton.NewAPIClient(client).WithRetry()
-
-	attestationData, err := getContractMethod(api, parsedAddress, "enclaveAttestation")
+		if network.globalConfig == "" && network.liteServer == "" {
+			if parsedAddress.IsTestnetOnly() {
+				network.globalConfig = "https://ton-blockchain.github.io/testnet-global.config.json"
+			} else {
+				network.globalConfig = "https://ton.org/global.config.json"
+			}
+		}
+		if trustedBlock != "" {
+			network.trustedBlock = &liteclient.ConfigBlock{}
+			if err := json.Unmarshal([]byte(trustedBlock), network.trustedBlock); err != nil {
+				log.Fatalf("error parsing trusted block: %v", err)
+			}
+		}
+
+		api, err := connect(context.Background(), network)
+		if err != nil {
+			log.Fatalf("error connecting to the network: %v", err)
+		}
+		getMethod = func(methodName string) (*ton.ExecutionResult, error) {
+			return getContractMethod(api, parsedAddress, methodName)
+		}
+	}
+
+	attestationData, err := getMethod("enclaveAttestation")
 	if err != nil {
 		log.Fatalf("Error running contract Get method enclaveAttestation: %v", err)
 	}
 
-	publicKeyData, err := getContractMethod(api, parsedAddress, "enclavePublicKey")
+	publicKeyData, err := getMethod("enclavePublicKey")
 	if err != nil {
 		log.Fatalf("Error running contract Get method enclavePublicKey: %v", err)
 	}
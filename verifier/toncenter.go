@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Versions of the toncenter HTTP API.
+const (
+	tonCenterV2 = "v2"
+	tonCenterV3 = "v3"
+)
+
+// getTonCenterMethod runs the contract get-method through the toncenter v2 or v3 HTTP API.
+// Only number, cell and slice stack entries are supported, which is enough for the attestation getters.
+func getTonCenterMethod(ctx context.Context, baseURL string, apiKey string, version string, contractAddress *address.Address, methodName string) (*ton.ExecutionResult, error) {
+	body, err := json.Marshal(map[string]any{
+		"address": contractAddress.String(),
+		"method":  methodName,
+		"stack":   []any{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if version != tonCenterV2 && version != tonCenterV3 {
+		return nil, fmt.Errorf("unknown toncenter API version: %s", version)
+	}
+	url := strings.TrimRight(baseURL, "/") + "/api/" + version + "/runGetMethod"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if version == tonCenterV3 {
+		return decodeTonCenterV3Result(resp)
+	}
+	return decodeTonCenterV2Result(resp)
+}
+
+func decodeTonCenterV2Result(resp *http.Response) (*ton.ExecutionResult, error) {
+	var response struct {
+		OK     bool   `json:"ok"`
+		Error  string `json:"error"`
+		Result struct {
+			ExitCode int32               `json:"exit_code"`
+			Stack    [][]json.RawMessage `json:"stack"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("unexpected toncenter response, status code %d", resp.StatusCode)
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("toncenter error: %s", response.Error)
+	}
+	if code := response.Result.ExitCode; code != 0 && code != 1 {
+		return nil, ton.ContractExecError{Code: code}
+	}
+
+	values := make([]any, 0, len(response.Result.Stack))
+	for _, entry := range response.Result.Stack {
+		if len(entry) != 2 {
+			return nil, errors.New("invalid stack entry")
+		}
+		var entryType string
+		if err := json.Unmarshal(entry[0], &entryType); err != nil {
+			return nil, errors.New("invalid stack entry type")
+		}
+		var value string
+		switch entryType {
+		case "num":
+			if err := json.Unmarshal(entry[1], &value); err != nil {
+				return nil, errors.New("invalid number stack entry")
+			}
+		case "cell", "slice":
+			var boc struct {
+				Bytes string `json:"bytes"`
+			}
+			if err := json.Unmarshal(entry[1], &boc); err != nil {
+				return nil, errors.New("invalid cell stack entry")
+			}
+			value = boc.Bytes
+		}
+		v, err := decodeStackValue(entryType, value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return ton.NewExecutionResult(values), nil
+}
+
+// decodeTonCenterV3Result decodes the v3 result, where the stack entries are {"type": "num", "value": "0x1"}.
+func decodeTonCenterV3Result(resp *http.Response) (*ton.ExecutionResult, error) {
+	var response struct {
+		Error    string `json:"error"`
+		ExitCode int32  `json:"exit_code"`
+		Stack    []struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		} `json:"stack"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("unexpected toncenter response, status code %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("toncenter error: %s", response.Error)
+	}
+	if code := response.ExitCode; code != 0 && code != 1 {
+		return nil, ton.ContractExecError{Code: code}
+	}
+
+	values := make([]any, 0, len(response.Stack))
+	for _, entry := range response.Stack {
+		v, err := decodeStackValue(entry.Type, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return ton.NewExecutionResult(values), nil
+}
+
+// decodeStackValue decodes the hex number or the base64-encoded cell of the stack entry.
+func decodeStackValue(entryType string, value string) (any, error) {
+	switch entryType {
+	case "num":
+		n, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid number: %s", value)
+		}
+		return n, nil
+	case "cell", "slice":
+		boc, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cell encoding: %w", err)
+		}
+		c, err := cell.FromBOC(boc)
+		if err != nil {
+			return nil, err
+		}
+		return c.BeginParse(), nil
+	default:
+		return nil, fmt.Errorf("unsupported stack entry type: %s", entryType)
+	}
+}